@@ -1,4 +1,4 @@
-package main
+package hilbish
 
 import (
 	"regexp"
@@ -11,8 +11,6 @@ import (
 	rt "github.com/arnodel/golua/runtime"
 )
 
-var aliases *aliasModule
-
 type aliasModule struct {
 	aliases map[string]string
 	mu *sync.RWMutex
@@ -97,7 +95,7 @@ func (a *aliasModule) Resolve(cmdstr string) string {
 func (a *aliasModule) Loader(rtm *rt.Runtime) *rt.Table {
 	// create a lua module with our functions
 	hshaliasesLua := map[string]util.LuaExport{
-		"add": util.LuaExport{hlalias, 2, false},
+		"add": util.LuaExport{a.luaAdd, 2, false},
 		"list": util.LuaExport{a.luaList, 0, false},
 		"del": util.LuaExport{a.luaDelete, 1, false},
 		"resolve": util.LuaExport{a.luaResolve, 1, false},
@@ -116,6 +114,24 @@ func (a *aliasModule) Loader(rtm *rt.Runtime) *rt.Table {
 // --- @param cmd string
 func _hlalias() {}
 
+func (a *aliasModule) luaAdd(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+	if err := c.CheckNArgs(2); err != nil {
+		return nil, err
+	}
+	cmd, err := c.StringArg(0)
+	if err != nil {
+		return nil, err
+	}
+	orig, err := c.StringArg(1)
+	if err != nil {
+		return nil, err
+	}
+
+	a.Add(cmd, orig)
+
+	return c.Next(), nil
+}
+
 // #interface aliases
 // list() -> table[string, string]
 // Get a table of all aliases, with string keys as the alias and the value as the command.
@@ -10,7 +10,7 @@
 // #field login Is Hilbish the login shell?
 // #field vimMode Current Vim input mode of Hilbish (will be nil if not in Vim input mode)
 // #field exitCode Exit code of the last executed command
-package main
+package hilbish
 
 import (
 	"bytes"
@@ -27,44 +27,39 @@ import (
 	"hilbish/util"
 
 	rt "github.com/arnodel/golua/runtime"
-	"github.com/arnodel/golua/lib/packagelib"
-	"github.com/arnodel/golua/lib/iolib"
+		"github.com/arnodel/golua/lib/iolib"
 	"github.com/maxlandon/readline"
 	"mvdan.cc/sh/v3/interp"
 )
 
-var exports = map[string]util.LuaExport{
-	"alias": {hlalias, 2, false},
-	"appendPath": {hlappendPath, 1, false},
-	"complete": {hlcomplete, 2, false},
-	"cwd": {hlcwd, 0, false},
-	"exec": {hlexec, 1, false},
-	"runnerMode": {hlrunnerMode, 1, false},
-	"goro": {hlgoro, 1, true},
-	"highlighter": {hlhighlighter, 1, false},
-	"hinter": {hlhinter, 1, false},
-	"multiprompt": {hlmultiprompt, 1, false},
-	"prependPath": {hlprependPath, 1, false},
-	"prompt": {hlprompt, 1, true},
-	"inputMode": {hlinputMode, 1, false},
-	"interval": {hlinterval, 2, false},
-	"read": {hlread, 1, false},
-	"run": {hlrun, 1, true},
-	"timeout": {hltimeout, 2, false},
-	"which": {hlwhich, 1, false},
-}
-
-var hshMod *rt.Table
-var hilbishLoader = packagelib.Loader{
-	Load: hilbishLoad,
-	Name: "hilbish",
-}
+func (sh *Shell) hilbishLoad(rtm *rt.Runtime) (rt.Value, func()) {
+	exports := map[string]util.LuaExport{
+		"alias": {sh.hlalias, 2, false},
+		"appendPath": {hlappendPath, 1, false},
+		"complete": {sh.hlcomplete, 2, false},
+		"cwd": {hlcwd, 0, false},
+		"exec": {hlexec, 1, false},
+		"runnerMode": {sh.hlrunnerMode, 1, false},
+		"goro": {sh.hlgoro, 1, true},
+		"highlighter": {hlhighlighter, 1, false},
+		"hinter": {sh.hlhinter, 2, false},
+		"signature": {sh.hlsignature, 2, false},
+		"multiprompt": {sh.hlmultiprompt, 1, false},
+		"pick": {sh.hlpick, 1, true},
+		"prependPath": {hlprependPath, 1, false},
+		"prompt": {sh.hlprompt, 1, true},
+		"inputMode": {sh.hlinputMode, 1, false},
+		"interval": {sh.hlinterval, 2, false},
+		"read": {sh.hlread, 1, false},
+		"run": {sh.hlrun, 1, true},
+		"timeout": {sh.hltimeout, 2, false},
+		"which": {sh.hlwhich, 1, false},
+	}
 
-func hilbishLoad(rtm *rt.Runtime) (rt.Value, func()) {
 	mod := rt.NewTable()
 
 	util.SetExports(rtm, mod, exports)
-	hshMod = mod
+	sh.hshMod = mod
 
 	host, _ := os.Hostname()
 	username := curuser.Username
@@ -73,14 +68,14 @@ func hilbishLoad(rtm *rt.Runtime) (rt.Value, func()) {
 		username = strings.Split(username, "\\")[1] // for some reason Username includes the hostname on windows
 	}
 
-	util.SetField(rtm, mod, "ver", rt.StringValue(getVersion()))
+	util.SetField(rtm, mod, "ver", rt.StringValue(Version()))
 	util.SetField(rtm, mod, "goVersion", rt.StringValue(runtime.Version()))
 	util.SetField(rtm, mod, "user", rt.StringValue(username))
 	util.SetField(rtm, mod, "host", rt.StringValue(host))
 	util.SetField(rtm, mod, "home", rt.StringValue(curuser.HomeDir))
 	util.SetField(rtm, mod, "dataDir", rt.StringValue(dataDir))
-	util.SetField(rtm, mod, "interactive", rt.BoolValue(interactive))
-	util.SetField(rtm, mod, "login", rt.BoolValue(login))
+	util.SetField(rtm, mod, "interactive", rt.BoolValue(sh.interactive))
+	util.SetField(rtm, mod, "login", rt.BoolValue(sh.login))
 	util.SetField(rtm, mod, "vimMode", rt.NilValue)
 	util.SetField(rtm, mod, "exitCode", rt.IntValue(0))
 
@@ -93,47 +88,50 @@ func hilbishLoad(rtm *rt.Runtime) (rt.Value, func()) {
 	mod.Set(rt.StringValue("os"), rt.TableValue(hshos))
 
 	// hilbish.aliases table
-	aliases = newAliases()
-	aliasesModule := aliases.Loader(rtm)
+	sh.aliases = newAliases()
+	aliasesModule := sh.aliases.Loader(rtm)
 	mod.Set(rt.StringValue("aliases"), rt.TableValue(aliasesModule))
 
 	// hilbish.history table
-	historyModule := lr.Loader(rtm)
+	historyModule := sh.lr.Loader(rtm)
 	mod.Set(rt.StringValue("history"), rt.TableValue(historyModule))
 
 	// hilbish.completion table
-	hshcomp := completionLoader(rtm)
+	hshcomp := sh.completionLoader(rtm)
 	// TODO: REMOVE "completion" AND ONLY USE "completions" WITH AN S
 	mod.Set(rt.StringValue("completion"), rt.TableValue(hshcomp))
 	mod.Set(rt.StringValue("completions"), rt.TableValue(hshcomp))
 
 	// hilbish.runner table
-	runnerModule := runnerModeLoader(rtm)
+	runnerModule := sh.runnerModeLoader(rtm)
 	mod.Set(rt.StringValue("runner"), rt.TableValue(runnerModule))
 
 	// hilbish.jobs table
-	jobs = newJobHandler()
-	jobModule := jobs.loader(rtm)
+	sh.jobs = newJobHandler(sh)
+	jobModule := sh.jobs.loader(rtm)
 	mod.Set(rt.StringValue("jobs"), rt.TableValue(jobModule))
 
 	// hilbish.timers table
-	timers = newTimersModule()
-	timersModule := timers.loader(rtm)
+	sh.timers = newTimersModule(rtm)
+	timersModule := sh.timers.loader(rtm)
 	mod.Set(rt.StringValue("timers"), rt.TableValue(timersModule))
 
-	editorModule := editorLoader(rtm)
+	editorModule := sh.editorLoader(rtm)
 	mod.Set(rt.StringValue("editor"), rt.TableValue(editorModule))
 
 	versionModule := rt.NewTable()
 	util.SetField(rtm, versionModule, "branch", rt.StringValue(gitBranch))
-	util.SetField(rtm, versionModule, "full", rt.StringValue(getVersion()))
+	util.SetField(rtm, versionModule, "full", rt.StringValue(Version()))
 	util.SetField(rtm, versionModule, "commit", rt.StringValue(gitCommit))
 	util.SetField(rtm, versionModule, "release", rt.StringValue(releaseName))
 	mod.Set(rt.StringValue("version"), rt.TableValue(versionModule))
 
-	pluginModule := moduleLoader(rtm)
+	pluginModule := sh.moduleLoader(rtm)
 	mod.Set(rt.StringValue("module"), rt.TableValue(pluginModule))
 
+	dataModule := dataLoader(rtm)
+	mod.Set(rt.StringValue("data"), rt.TableValue(dataModule))
+
 	return rt.TableValue(mod), nil
 }
 
@@ -145,13 +143,13 @@ func getenv(key, fallback string) string {
     return value
 }
 
-func setVimMode(mode string) {
-	util.SetField(l, hshMod, "vimMode", rt.StringValue(mode))
-	hooks.Emit("hilbish.vimMode", mode)
+func (sh *Shell) setVimMode(mode string) {
+	util.SetField(sh.runtime, sh.hshMod, "vimMode", rt.StringValue(mode))
+	sh.hooks.Emit("hilbish.vimMode", mode)
 }
 
-func unsetVimMode() {
-	util.SetField(l, hshMod, "vimMode", rt.NilValue)
+func (sh *Shell) unsetVimMode() {
+	util.SetField(sh.runtime, sh.hshMod, "vimMode", rt.NilValue)
 }
 
 func handleStream(v rt.Value, strms *streams, errStream bool) error {
@@ -210,7 +208,7 @@ hilbish.run('wc -l', {
 })
 */
 // #example
-func hlrun(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+func (sh *Shell) hlrun(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	// TODO: ON BREAKING RELEASE, DO NOT ACCEPT `streams` AS A BOOLEAN.
 	if err := c.Check1Arg(); err != nil {
 		return nil, err
@@ -270,7 +268,7 @@ func hlrun(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	}
 
 	var exitcode uint8
-	stdout, stderr, err := execCommand(cmd, strms)
+	stdout, stderr, err := sh.execCommand(cmd, strms)
 
 	if code, ok := interp.IsExitStatus(err); ok {
 		exitcode = code
@@ -305,7 +303,7 @@ func hlcwd(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 // Returns `input`, will be nil if Ctrl-D is pressed, or an error occurs.
 // #param prompt? string Text to print before input, can be empty.
 // #returns string|nil
-func hlread(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+func (sh *Shell) hlread(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	luaprompt := c.Arg(0)
 	if typ := luaprompt.Type(); typ != rt.StringType && typ != rt.NilType {
 		return nil, errors.New("expected #1 to be a string")
@@ -319,6 +317,7 @@ func hlread(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	
 	lualr := &lineReader{
 		rl: readline.NewInstance(),
+		sh: sh,
 	}
 	lualr.SetPrompt(prompt)
 
@@ -348,7 +347,7 @@ hilbish.prompt '%u@%h :%d $'
 -- prompt: user@hostname: ~/directory $
 #example
 */
-func hlprompt(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+func (sh *Shell) hlprompt(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	err := c.Check1Arg()
 	if err != nil {
 		return nil, err
@@ -370,9 +369,9 @@ func hlprompt(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 
 	switch typ {
 		case "left":
-			prompt = p
-			lr.SetPrompt(fmtPrompt(prompt))
-		case "right": lr.SetRightPrompt(fmtPrompt(p))
+			sh.prompt = p
+			sh.lr.SetPrompt(fmtPrompt(sh.prompt))
+		case "right": sh.lr.SetRightPrompt(fmtPrompt(p))
 		default: return nil, errors.New("expected prompt type to be right or left, got " + typ)
 	}
 
@@ -381,7 +380,8 @@ func hlprompt(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 
 // multiprompt(str)
 // Changes the text prompt when Hilbish asks for more input.
-// This will show up when text is incomplete, like a missing quote
+// This will show up when text is incomplete, like a missing quote,
+// before each line after the first one of the command.
 // #param str string
 /*
 #example
@@ -402,7 +402,7 @@ hey ...!
 hilbish.multiprompt '-->'
 #example
 */
-func hlmultiprompt(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+func (sh *Shell) hlmultiprompt(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	if err := c.Check1Arg(); err != nil {
 		return nil, err
 	}
@@ -410,7 +410,10 @@ func hlmultiprompt(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	if err != nil {
 		return nil, err
 	}
-	multilinePrompt = prompt
+	sh.multilinePrompt = prompt
+	if sh.lr != nil {
+		sh.lr.rl.ContinuePrompt = prompt
+	}
 
 	return c.Next(), nil
 }
@@ -429,22 +432,8 @@ hilbish.alias('dircount', 'ls %1 | wc -l')
 -- "dircount ~" would count how many files are in ~ (home directory).
 #example
 */
-func hlalias(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
-	if err := c.CheckNArgs(2); err != nil {
-		return nil, err
-	}
-	cmd, err := c.StringArg(0)
-	if err != nil {
-		return nil, err
-	}
-	orig, err := c.StringArg(1)
-	if err != nil {
-		return nil, err
-	}
-
-	aliases.Add(cmd, orig)
-
-	return c.Next(), nil
+func (sh *Shell) hlalias(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+	return sh.aliases.luaAdd(t, c)
 }
 
 // appendPath(dir)
@@ -536,7 +525,7 @@ func hlexec(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 // **NOTE: THIS FUNCTION MAY CRASH HILBISH IF OUTSIDE VARIABLES ARE ACCESSED.**
 // **This is a limitation of the Lua runtime.**
 // #param fn function
-func hlgoro(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+func (sh *Shell) hlgoro(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	if err := c.Check1Arg(); err != nil {
 		return nil, err
 	}
@@ -553,7 +542,7 @@ func hlgoro(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 			}
 		}()
 
-		_, err := rt.Call1(l.MainThread(), rt.FunctionValue(fn), c.Etc()...)
+		_, err := rt.Call1(sh.runtime.MainThread(), rt.FunctionValue(fn), c.Etc()...)
 		if err != nil {
 			fmt.Fprintln(os.Stderr, "Error in goro function:\n\n", err)
 		}
@@ -568,7 +557,7 @@ func hlgoro(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 // #param cb function
 // #param time number Time to run in milliseconds.
 // #returns Timer
-func hltimeout(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+func (sh *Shell) hltimeout(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	if err := c.CheckNArgs(2); err != nil {
 		return nil, err
 	}
@@ -582,7 +571,7 @@ func hltimeout(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	}
 
 	interval := time.Duration(ms) * time.Millisecond
-	timer := timers.create(timerTimeout, interval, cb)
+	timer := sh.timers.create(timerTimeout, interval, cb)
 	timer.start()
 	
 	return c.PushingNext1(t.Runtime, rt.UserDataValue(timer.ud)), nil
@@ -594,7 +583,7 @@ func hltimeout(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 // #param cb function
 // #param time number Time in milliseconds.
 // #return Timer
-func hlinterval(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+func (sh *Shell) hlinterval(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	if err := c.CheckNArgs(2); err != nil {
 		return nil, err
 	}
@@ -608,7 +597,7 @@ func hlinterval(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	}
 
 	interval := time.Duration(ms) * time.Millisecond
-	timer := timers.create(timerInterval, interval, cb)
+	timer := sh.timers.create(timerInterval, interval, cb)
 	timer.start()
 
 	return c.PushingNext1(t.Runtime, rt.UserDataValue(timer.ud)), nil
@@ -649,12 +638,12 @@ hilbish.complete('command.sudo', function(query, ctx, fields)
 end)
 #example
 */
-func hlcomplete(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+func (sh *Shell) hlcomplete(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	scope, cb, err := util.HandleStrCallback(t, c)
 	if err != nil {
 		return nil, err
 	}
-	luaCompletions[scope] = cb
+	sh.luaCompletions[scope] = cb
 
 	return c.Next(), nil
 }
@@ -686,7 +675,7 @@ func hlprependPath(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 // Will return the path of the binary, or a basename if it's a commander.
 // #param name string
 // #returns string
-func hlwhich(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+func (sh *Shell) hlwhich(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	if err := c.Check1Arg(); err != nil {
 		return nil, err
 	}
@@ -697,11 +686,11 @@ func hlwhich(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 
 	// itll return either the original command or what was passed
 	// if name isnt empty its not an issue
-	alias := aliases.Resolve(name)
+	alias := sh.aliases.Resolve(name)
 	cmd := strings.Split(alias, " ")[0]
 
 	// check for commander
-	if cmds.Commands[cmd] != nil {
+	if sh.cmds.Commands[cmd] != nil {
 		// they dont resolve to a path, so just send the cmd
 		return c.PushingNext1(t.Runtime, rt.StringValue(cmd)), nil
 	}
@@ -719,7 +708,7 @@ func hlwhich(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 // `emacs` is the default. Setting it to `vim` changes behavior of input to be
 // Vim-like with modes and Vim keybinds.
 // #param mode string Can be set to either `emacs` or `vim`
-func hlinputMode(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+func (sh *Shell) hlinputMode(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	if err := c.Check1Arg(); err != nil {
 		return nil, err
 	}
@@ -730,11 +719,11 @@ func hlinputMode(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 
 	switch mode {
 		case "emacs":
-			unsetVimMode()
-			lr.rl.InputMode = readline.Emacs
+			sh.unsetVimMode()
+			sh.lr.rl.InputMode = readline.Emacs
 		case "vim":
-			setVimMode("insert")
-			lr.rl.InputMode = readline.Vim
+			sh.setVimMode("insert")
+			sh.lr.rl.InputMode = readline.Vim
 		default:
 			return nil, errors.New("inputMode: expected vim or emacs, received " + mode)
 	}
@@ -751,7 +740,7 @@ func hlinputMode(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 // will call it to execute user input instead.
 // Read [about runner mode](../features/runner-mode) for more information.
 // #param mode string|function
-func hlrunnerMode(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+func (sh *Shell) hlrunnerMode(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	if err := c.Check1Arg(); err != nil {
 		return nil, err
 	}
@@ -760,10 +749,10 @@ func hlrunnerMode(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	switch mode.Type() {
 		case rt.StringType:
 			switch mode.AsString() {
-				case "hybrid", "hybridRev", "lua", "sh": runnerMode = mode
+				case "hybrid", "hybridRev", "lua", "sh": sh.runnerMode = mode
 				default: return nil, errors.New("execMode: expected either a function or hybrid, hybridRev, lua, sh. Received " + mode.AsString())
 			}
-		case rt.FunctionType: runnerMode = mode
+		case rt.FunctionType: sh.runnerMode = mode
 		default: return nil, errors.New("execMode: expected either a function or hybrid, hybridRev, lua, sh. Received " + mode.TypeName())
 	}
 
@@ -774,8 +763,17 @@ func hlrunnerMode(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 // The command line hint handler. It gets called on every key insert to
 // determine what text to use as an inline hint. It is passed the current
 // line and cursor position. It is expected to return a string which is used
-// as the text for the hint. This is by default a shim. To set hints,
-// override this function with your custom handler.
+// as the text for the hint. The hint can be accepted with the right arrow
+// or End key (or the next word of it with Alt-F) when the cursor is at the
+// end of the line.
+// If a second value of false is returned, the hint is only shown and
+// can't be accepted.
+// By default, this suggests a command from history that starts with the line,
+// unless the `autosuggest` opt is disabled. Commands run in the current
+// directory and ones that succeeded are preferred. When the cursor is in a
+// call to a Lua function with a [signature](#signature), like `hilbish.run(`,
+// the signature is shown instead (unless the `signatureHints` opt is disabled).
+// To set other hints, override this function with your custom handler.
 // #param line string
 // #param pos number Position of cursor in line. Usually equals string.len(line)
 /*
@@ -786,8 +784,39 @@ function hilbish.hinter(line, pos)
 end
 #example
 */
-func hlhinter(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
-	return c.Next(), nil
+func (sh *Shell) hlhinter(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+	if err := c.CheckNArgs(2); err != nil {
+		return nil, err
+	}
+	line, err := c.StringArg(0)
+	if err != nil {
+		return nil, err
+	}
+	pos, err := c.IntArg(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if int(pos) != len([]rune(line)) {
+		return c.Next(), nil
+	}
+
+	if sh.opt("signatureHints") != rt.BoolValue(false) && sh.luaInputMaybe(line) {
+		if hint := sh.luaSignatureHint(line); hint != "" {
+			return c.PushingNext(t.Runtime, rt.StringValue(hint), rt.BoolValue(false)), nil
+		}
+	}
+
+	if sh.opt("autosuggest") == rt.BoolValue(false) {
+		return c.Next(), nil
+	}
+
+	sugg := sh.lr.suggest(line)
+	if sugg == "" {
+		return c.Next(), nil
+	}
+
+	return c.PushingNext1(t.Runtime, rt.StringValue(strings.TrimPrefix(sugg, line))), nil
 }
 
 // highlighter(line)
@@ -796,6 +825,9 @@ func hlhinter(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 // of the prompt to *display* anything. The callback is passed the current line
 // and is expected to return a line that will be used as the input display.
 // Note that to set a highlighter, one has to override this function.
+// Lines can be routed to other runners with `hilbish.runner.route`, so
+// to highlight each language its own way, `hilbish.runner.resolve(line)`
+// returns the name of the runner the line goes to.
 // #example
 // --This code will highlight all double quoted strings in green.
 // function hilbish.highlighter(line)
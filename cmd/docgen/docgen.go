@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"path/filepath"
 	"go/ast"
@@ -8,6 +9,7 @@ import (
 	"go/parser"
 	"go/token"
 	"regexp"
+	"sort"
 	"strings"
 	"os"
 	"sync"
@@ -51,6 +53,20 @@ type param struct{
 	Doc []string
 }
 
+// signature is a function's entry in emmyLuaDocs/signatures.json,
+// which Hilbish uses to hint at the arguments of a call being typed.
+type signature struct {
+	Signature string `json:"signature"`
+	Description string `json:"description"`
+	Params []signatureParam `json:"params"`
+}
+
+type signatureParam struct {
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"`
+	Doc string `json:"doc,omitempty"`
+}
+
 type docPiece struct {
 	Doc []string
 	FuncSig string
@@ -76,6 +92,9 @@ type tag struct {
 var docs = make(map[string]module)
 var interfaceDocs = make(map[string]module)
 var emmyDocs = make(map[string][]emmyPiece)
+var signatures = make(map[string]signature)
+var signaturesMu sync.Mutex
+var natureFunc = regexp.MustCompile(`^function ([\w.:]+)\(([^)]*)\)`)
 var typeTable = make(map[string][]string) // [0] = parentMod, [1] = interfaces
 var prefix = map[string]string{
 	"main": "hl",
@@ -329,9 +348,6 @@ func main() {
 		pieces := []docPiece{}
 		typePieces := []docPiece{}
 		mod := l
-		if mod == "main" {
-			mod = "hilbish"
-		}
 		var hasInterfaces bool
 		for _, t := range p.Funcs {
 			piece := setupDoc(mod, t)
@@ -366,6 +382,12 @@ func main() {
 			}
 		}
 
+		// functions and methods are collected separately,
+		// so sort them to keep them in order of their names
+		sort.SliceStable(pieces, func(i, j int) bool {
+			return pieces[i].GoFuncName < pieces[j].GoFuncName
+		})
+
 		tags, descParts := getTagsAndDocs(strings.TrimSpace(p.Doc))
 		shortDesc := descParts[0]
 		desc := descParts[1:]
@@ -670,9 +692,132 @@ func main() {
 					intrface = "." + dps.Interfacing
 				}
 				ff.WriteString("function " + modname + intrface + accessor + signature + " end\n\n")
+
+				fullName := modname + intrface + accessor + strings.Split(signature, "(")[0]
+				signaturesMu.Lock()
+				signatures[fullName] = signatureFor(modname + intrface + accessor + signature, em)
+				signaturesMu.Unlock()
 			}
 			ff.WriteString("return " + modname + "\n")
 		}(mod, mod, v)
 	}
 	wg.Wait()
+	natureSignatures()
+
+	sigs, err := json.MarshalIndent(signatures, "", "\t")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "docgen: could not encode signatures:", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile("emmyLuaDocs/signatures.json", append(sigs, '\n'), 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "docgen: could not write signatures:", err)
+		os.Exit(1)
+	}
+}
+
+// natureSignatures adds the signatures of the functions defined in
+// nature, from the emmyLua docs above them. Functions documented in Go
+// keep their signatures.
+func natureSignatures() {
+	files, _ := filepath.Glob("nature/*.lua")
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+
+		lines := strings.Split(string(data), "\n")
+		for i, line := range lines {
+			m := natureFunc.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			if _, ok := signatures[m[1]]; ok {
+				continue
+			}
+
+			start := i
+			for start > 0 && strings.HasPrefix(lines[start - 1], "---") {
+				start--
+			}
+			dps := &docPiece{}
+			for _, d := range lines[start:i] {
+				d = strings.TrimSpace(strings.TrimPrefix(d, "---"))
+				if strings.HasPrefix(d, "@param ") {
+					dps.Params = append(dps.Params, natureParam(strings.Fields(d)[1:]))
+				} else if !strings.HasPrefix(d, "@") {
+					dps.Doc = append(dps.Doc, d)
+				}
+			}
+
+			em := emmyPiece{DocPiece: dps}
+			for _, p := range strings.Split(m[2], ",") {
+				if p = strings.TrimSpace(p); p != "" {
+					em.Params = append(em.Params, p)
+				}
+			}
+			signatures[m[1]] = signatureFor(m[1] + "(" + m[2] + ")", em)
+		}
+	}
+}
+
+// natureParam makes a param from the fields of an emmyLua @param,
+// where the type can be a union like `function | table`.
+func natureParam(fields []string) param {
+	p := param{Name: fields[0]}
+	if len(fields) < 2 {
+		return p
+	}
+
+	typ := fields[1]
+	rest := fields[2:]
+	for len(rest) >= 2 && rest[0] == "|" {
+		typ += " | " + rest[1]
+		rest = rest[2:]
+	}
+	p.Type = typ
+	if len(rest) != 0 {
+		p.Doc = []string{strings.Join(rest, " ")}
+	}
+
+	return p
+}
+
+func signatureFor(sig string, em emmyPiece) signature {
+	s := signature{Signature: sig, Params: []signatureParam{}}
+	// the description is the first sentence of the docs
+	var summary []string
+	for _, d := range em.DocPiece.Doc {
+		d = strings.TrimSpace(d)
+		if d == "" {
+			if len(summary) != 0 {
+				break
+			}
+			continue
+		}
+		summary = append(summary, d)
+		if strings.HasSuffix(d, ".") || strings.Contains(d, ". ") {
+			break
+		}
+	}
+	s.Description = strings.Join(summary, " ")
+	if i := strings.Index(s.Description, ". "); i != -1 {
+		s.Description = s.Description[:i + 1]
+	}
+
+	if len(em.DocPiece.Params) != 0 {
+		for _, p := range em.DocPiece.Params {
+			s.Params = append(s.Params, signatureParam{
+				Name: p.Name,
+				Type: p.Type,
+				Doc: strings.Join(p.Doc, " "),
+			})
+		}
+	} else {
+		for _, p := range em.Params {
+			s.Params = append(s.Params, signatureParam{Name: p})
+		}
+	}
+
+	return s
 }
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+
+	"hilbish"
+
+	"github.com/pborman/getopt"
+	"golang.org/x/term"
+)
+
+func main() {
+	var interactive, login, noexecute bool
+
+	helpflag := getopt.BoolLong("help", 'h', "Prints Hilbish flags")
+	verflag := getopt.BoolLong("version", 'v', "Prints Hilbish version")
+	setshflag := getopt.BoolLong("setshellenv", 'S', "Sets $SHELL to Hilbish's executed path")
+	cmdflag := getopt.StringLong("command", 'c', "", "Executes a command on startup")
+	configflag := getopt.StringLong("config", 'C', hilbish.DefaultConfigPath(), "Sets the path to Hilbish's config")
+	getopt.BoolLong("login", 'l', "Force Hilbish to be a login shell")
+	getopt.BoolLong("interactive", 'i', "Force Hilbish to be an interactive shell")
+	getopt.BoolLong("noexec", 'n', "Don't execute and only report Lua syntax errors")
+	profileflag := getopt.BoolLong("profile-startup", 0, "Reports the time spent loading each Lua module on startup")
+
+	getopt.Parse()
+	loginshflag := getopt.Lookup('l').Seen()
+	interactiveflag := getopt.Lookup('i').Seen()
+	noexecflag := getopt.Lookup('n').Seen()
+
+	if *helpflag {
+		getopt.PrintUsage(os.Stdout)
+		os.Exit(0)
+	}
+
+	if *cmdflag == "" || interactiveflag {
+		interactive = true
+	}
+
+	if fileInfo, _ := os.Stdin.Stat(); (fileInfo.Mode() & os.ModeCharDevice) == 0 || !term.IsTerminal(int(os.Stdin.Fd())) {
+		interactive = false
+	}
+
+	if getopt.NArgs() > 0 {
+		interactive = false
+	}
+
+	if noexecflag {
+		noexecute = true
+	}
+
+	// first arg, first character
+	if loginshflag || os.Args[0][0] == '-' {
+		login = true
+	}
+
+	if *verflag {
+		fmt.Printf("Hilbish %s\nCompiled with %s\n", hilbish.Version(), runtime.Version())
+		os.Exit(0)
+	}
+
+	// Set $SHELL if the user wants to
+	if *setshflag {
+		os.Setenv("SHELL", os.Args[0])
+	}
+
+	opts := []hilbish.Option{
+		hilbish.WithInteractive(interactive),
+		hilbish.WithLogin(login),
+		hilbish.WithNoExec(noexecute),
+		hilbish.WithChunkCache(hilbish.DefaultChunkCacheDir()),
+	}
+	if *profileflag {
+		opts = append(opts, hilbish.WithStartupProfile(os.Stderr))
+	}
+
+	sh := hilbish.New(opts...)
+
+	go sh.HandleSignals()
+	go exitOnSignals(sh, interactive)
+
+	sh.RunConfig(*configflag)
+
+	if fileInfo, _ := os.Stdin.Stat(); (fileInfo.Mode() & os.ModeCharDevice) == 0 {
+		scanner := bufio.NewScanner(bufio.NewReader(os.Stdin))
+		for scanner.Scan() {
+			text := scanner.Text()
+			sh.RunInput(text, true)
+			exitIfExited(sh)
+		}
+		exit(sh, 0)
+	}
+
+	if *cmdflag != "" {
+		sh.RunInput(*cmdflag, true)
+		exitIfExited(sh)
+	}
+
+	if getopt.NArgs() > 0 {
+		err := sh.RunFile(getopt.Arg(0), getopt.Args()[1:]...)
+		exitIfExited(sh)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exit(sh, 1)
+		}
+		exit(sh, 0)
+	}
+
+	if interactive {
+		err := sh.Interactive(context.Background())
+		var exitErr hilbish.ExitError
+		if errors.As(err, &exitErr) {
+			exit(sh, int(exitErr.Code))
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exit(sh, 1)
+		}
+	}
+
+	exit(sh, 0)
+}
+
+func exit(sh *hilbish.Shell, code int) {
+	sh.Close()
+	os.Exit(code)
+}
+
+// exitOnSignals exits when Hilbish is terminated, or interrupted when it
+// isn't interactive. The shell itself only throws the signal hooks.
+func exitOnSignals(sh *hilbish.Shell, interactive bool) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+
+	for s := range c {
+		switch {
+		case s == syscall.SIGTERM: exit(sh, 0)
+		case !interactive: os.Exit(0)
+		}
+	}
+}
+
+// exitIfExited exits with the code passed to the exit command, if it was run.
+func exitIfExited(sh *hilbish.Shell) {
+	if code, ok := sh.Exited(); ok {
+		exit(sh, int(code))
+	}
+}
@@ -1,8 +1,9 @@
-package main
+package hilbish
 
 import (
 	"errors"
 	"path/filepath"
+	"sort"
 	"strings"
 	"os"
 
@@ -43,51 +44,12 @@ func invert(m []string) []string {
 	return newM
 }
 
-func splitForFile(str string) []string {
-	split := []string{}
-	sb := &strings.Builder{}
-	quoted := false
-
-	for i, r := range str {
-		if r == '"' {
-			quoted = !quoted
-			sb.WriteRune(r)
-		} else if r == ' ' && str[i - 1] == '\\' {
-			sb.WriteRune(r)
-		} else if !quoted && r == ' ' {
-			split = append(split, sb.String())
-			sb.Reset()
-		} else {
-			sb.WriteRune(r)
-		}
-	}
-	if strings.HasSuffix(str, " ") {
-		split = append(split, "")
-	}
-
-	if sb.Len() > 0 {
-		split = append(split, sb.String())
-	}
-
-	return split
-}
-
 func fileComplete(query, ctx string, fields []string) ([]string, string) {
-	q := splitForFile(ctx)
-	path := ""
-	if len(q) != 0 {
-		path = q[len(q) - 1]
-	}
-
-	return matchPath(path)
+	return matchPath(contextFor(ctx).word)
 }
 
-func binaryComplete(query, ctx string, fields []string) ([]string, string) {
-	q := splitForFile(ctx)
-	query = ""
-	if len(q) != 0 {
-		query = q[len(q) - 1]
-	}
+func (sh *Shell) binaryComplete(query, ctx string, fields []string) ([]string, string) {
+	query = contextFor(ctx).word
 
 	var completions []string
 
@@ -128,7 +90,7 @@ func binaryComplete(query, ctx string, fields []string) ([]string, string) {
 	}
 
 	// add lua registered commands to completions
-	for cmdName := range cmds.Commands {
+	for cmdName := range sh.cmds.Commands {
 		if strings.HasPrefix(cmdName, query) {
 			completions = append(completions, cmdName)
 		}
@@ -140,12 +102,17 @@ func binaryComplete(query, ctx string, fields []string) ([]string, string) {
 }
 
 func matchPath(query string) ([]string, string) {
-	oldQuery := query
-	query = strings.TrimPrefix(query, "\"")
+	// a quoted path keeps its quote, and isn't escaped
+	quoted := strings.HasPrefix(query, "\"") || strings.HasPrefix(query, "'")
+	if quoted {
+		query = query[1:]
+	}
 	var entries []string
 	var baseName string
 
-	query = escapeInvertReplaer.Replace(query)
+	if !quoted {
+		query = escapeInvertReplaer.Replace(query)
+	}
 	path, _ := filepath.Abs(util.ExpandHome(filepath.Dir(query)))
 	if string(query) == "" {
 		// filepath base below would give us "."
@@ -171,13 +138,13 @@ func matchPath(query string) ([]string, string) {
 			if file.IsDir() {
 				entry = entry + string(os.PathSeparator)
 			}
-			if !strings.HasPrefix(oldQuery, "\"") {
+			if !quoted {
 				entry = escapeFilename(entry)
 			}
 			entries = append(entries, entry)
 		}
 	}
-	if !strings.HasPrefix(oldQuery, "\"") {
+	if !quoted {
 		baseName = escapeFilename(baseName)
 	}
 
@@ -191,12 +158,15 @@ func escapeFilename(fname string) string {
 // #interface completion
 // tab completions
 // The completions interface deals with tab completions.
-func completionLoader(rtm *rt.Runtime) *rt.Table {
+func (sh *Shell) completionLoader(rtm *rt.Runtime) *rt.Table {
 	exports := map[string]util.LuaExport{
-		"bins": {hcmpBins, 3, false},
-		"call": {hcmpCall, 4, false},
+		"bins": {sh.hcmpBins, 3, false},
+		"call": {sh.hcmpCall, 5, false},
+		"context": {hcmpContext, 2, false},
 		"files": {hcmpFiles, 3, false},
 		"handler": {hcmpHandler, 2, false},
+		"lua": {sh.hcmpLua, 1, false},
+		"vars": {hcmpVars, 3, false},
 	}
 
 	mod := rt.NewTable()
@@ -231,13 +201,13 @@ hilbish.complete('command.sudo', function(query, ctx, fields)
 end)
 #example
 */
-func hcmpBins(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+func (sh *Shell) hcmpBins(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	query, ctx, fds, err := getCompleteParams(t, c)
 	if err != nil {
 		return nil, err
 	}
 
-	completions, pfx := binaryComplete(query, ctx, fds)
+	completions, pfx := sh.binaryComplete(query, ctx, fds)
 	luaComps := rt.NewTable()
 
 	for i, comp := range completions {
@@ -248,7 +218,7 @@ func hcmpBins(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 }
 
 // #interface completion
-// call(name, query, ctx, fields) -> completionGroups (table), prefix (string)
+// call(name, query, ctx, fields, context) -> completionGroups (table), prefix (string)
 // Calls a completer function. This is mainly used to call a command completer, which will have a `name`
 // in the form of `command.name`, example: `command.git`.
 // You can check the Completions doc or `doc completions` for info on the `completionGroups` return value.
@@ -256,7 +226,8 @@ func hcmpBins(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 // #param query string
 // #param ctx string
 // #param fields table
-func hcmpCall(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+// #param context table|nil The table from [context](#completion.context), passed on to the completer.
+func (sh *Shell) hcmpCall(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	if err := c.CheckNArgs(4); err != nil {
 		return nil, err
 	}
@@ -276,24 +247,29 @@ func hcmpCall(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	if err != nil {
 		return nil, err
 	}
+	context := rt.NilValue
+	if c.NArgs() > 4 {
+		context = c.Arg(4)
+	}
 
-	var completecb *rt.Closure
+	var completecb rt.Callable
 	var ok bool
-	if completecb, ok = luaCompletions[completer]; !ok {
+	if completecb, ok = sh.luaCompletions[completer]; !ok {
 		return nil, errors.New("completer " + completer + " does not exist")
 	}
 
-	// we must keep the holy 80 cols
-	cont := c.Next()
-	err = rt.Call(l.MainThread(), rt.FunctionValue(completecb),
-	[]rt.Value{rt.StringValue(query), rt.StringValue(ctx), rt.TableValue(fields)},
-	cont)
+	// the completer is run to the end before its results are passed on,
+	// so it can be called from Lua as a plain function
+	term := rt.NewTerminationWith(t.CurrentCont(), 2, false)
+	err = rt.Call(t, rt.FunctionValue(completecb),
+	[]rt.Value{rt.StringValue(query), rt.StringValue(ctx), rt.TableValue(fields), context},
+	term)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return cont, nil
+	return c.PushingNext(t.Runtime, term.Get(0), term.Get(1)), nil
 }
 
 // #interface completion
@@ -319,13 +295,43 @@ func hcmpFiles(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	return c.PushingNext(t.Runtime, rt.TableValue(luaComps), rt.StringValue(pfx)), nil
 }
 
+// #interface completion
+// vars(query, ctx, fields) -> entries (table), prefix (string)
+// Returns the names of environment variables that start with `query`.
+// This is used to complete the name of a variable typed after a `$`.
+// #param query string
+// #param ctx string
+// #param fields table
+func hcmpVars(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+	query, _, _, err := getCompleteParams(t, c)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, kv := range os.Environ() {
+		name := strings.SplitN(kv, "=", 2)[0]
+		if name != "" && strings.HasPrefix(name, query) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	luaComps := rt.NewTable()
+	for i, name := range names {
+		luaComps.Set(rt.IntValue(int64(i + 1)), rt.StringValue(name))
+	}
+
+	return c.PushingNext(t.Runtime, rt.TableValue(luaComps), rt.StringValue(query)), nil
+}
+
 // #interface completion
 // handler(line, pos)
 // This function contains the general completion handler for Hilbish. This function handles
 // completion of everything, which includes calling other command handlers, binaries, and files.
 // This function can be overriden to supply a custom handler. Note that alias resolution is required to be done in this function.
 // #param line string The current Hilbish command line
-// #param pos number Numerical position of the cursor
+// #param pos number Position of the cursor in `line`, as a byte offset
 /*
 #example
 -- stripped down version of the default implementation
@@ -345,6 +351,56 @@ func hcmpHandler(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 }
 
 
+// #interface completion
+// lua(ctx) -> completionGroups (table), prefix (string)
+// Returns completions for the Lua code at the end of `ctx`.
+// These are global names, or the keys of a value after a `.`, `:` or `["`,
+// like `hilbish.jo` or `require 'fs'.re`. Keys from the `__index` tables of
+// metatables are included, so methods of strings and objects are completed
+// too. Documented functions are described by their signature.
+// It returns nil if `ctx` doesn't end in Lua that can be completed, like
+// a name that isn't a global, or if nothing completes it. Calls in `ctx` aren't run, but `require`
+// of a module that is already loaded gives that module.
+// #param ctx string
+// #returns table
+/*
+#example
+-- complete Lua in a runner for it
+local groups, pfx = hilbish.completion.lua 'hilbish.jobs.'
+#example
+*/
+func (sh *Shell) hcmpLua(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+	if err := c.Check1Arg(); err != nil {
+		return nil, err
+	}
+	ctx, err := c.StringArg(0)
+	if err != nil {
+		return nil, err
+	}
+
+	items, descriptions, pfx, ok := sh.luaComplete(ctx)
+	// other completions are tried if there are no Lua ones,
+	// like files for `cat package.j`
+	if !ok || len(items) == 0 {
+		return c.PushingNext1(t.Runtime, rt.NilValue), nil
+	}
+
+	luaItems := rt.NewTable()
+	for _, item := range items {
+		desc := rt.NewTable()
+		desc.Set(rt.IntValue(1), rt.StringValue(descriptions[item]))
+		luaItems.Set(rt.StringValue(item), rt.TableValue(desc))
+	}
+
+	group := rt.NewTable()
+	group.Set(rt.StringValue("type"), rt.StringValue("list"))
+	group.Set(rt.StringValue("items"), rt.TableValue(luaItems))
+	groups := rt.NewTable()
+	groups.Set(rt.IntValue(1), rt.TableValue(group))
+
+	return c.PushingNext(t.Runtime, rt.TableValue(groups), rt.StringValue(pfx)), nil
+}
+
 func getCompleteParams(t *rt.Thread, c *rt.GoCont) (string, string, []string, error) {
 	if err := c.CheckNArgs(3); err != nil {
 		return "", "", []string{}, err
@@ -0,0 +1,340 @@
+package hilbish
+
+import (
+	"regexp"
+	"strings"
+
+	"hilbish/moduleapi"
+
+	rt "github.com/arnodel/golua/runtime"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// put at the cursor to find the word it's in, after parsing
+const cursorMark = "__hilbish_cursor__"
+
+// what's needed to finish partial input, from the parse error
+var rxUnclosedQuote = regexp.MustCompile(`without closing quote (.+)$`)
+var rxUnmatched = regexp.MustCompile(`without matching (\S+) with (\S+)$`)
+var rxMustEnd = regexp.MustCompile(`must end with "(\w+)"$`)
+var rxMustFollow = regexp.MustCompile(`must be followed by "(then|do)"$`)
+
+// commands that run the command after them, with their options
+// that take an argument
+var cmdWrappers = map[string][]string{
+	"sudo": {"-u", "-g", "-C", "-D", "-h", "-p", "-r", "-t", "-U"},
+	"doas": {"-u", "-C"},
+	"env": {"-u", "-C", "-S"},
+	"nice": {"-n"},
+	"nohup": {},
+	"time": {"-f", "-o"},
+	"command": {},
+	"exec": {"-a"},
+}
+
+// completionContext is what the cursor is in when completing shell input.
+type completionContext struct {
+	command string // command of the simple command the cursor is in
+	index int // index of the word in args, or -1 if it isn't an arg
+	args []string // unquoted words of the command, up to the cursor
+	word string // the word as typed
+	value string // the word unquoted, or the name after `$`
+	quote byte
+	variable bool
+	redirect bool
+	assignment string // name of the variable whose value is being typed
+}
+
+// parsePartial parses shell input that may be unfinished, like an
+// unclosed quote or substitution, by adding what it needs to the end.
+func parsePartial(src string) (*syntax.File, string, bool) {
+	for i := 0; i < 8; i++ {
+		file, err := syntax.NewParser().Parse(strings.NewReader(src), "")
+		if err == nil {
+			return file, src, true
+		}
+		perr, ok := err.(syntax.ParseError)
+		if !ok {
+			return nil, "", false
+		}
+
+		if m := rxUnclosedQuote.FindStringSubmatch(perr.Text); m != nil {
+			src += m[1]
+		} else if m := rxUnmatched.FindStringSubmatch(perr.Text); m != nil {
+			if m[1] == "${" {
+				src += m[2]
+			} else {
+				src += "\n" + m[2]
+			}
+		} else if m := rxMustEnd.FindStringSubmatch(perr.Text); m != nil {
+			src += "\n" + m[1]
+		} else if m := rxMustFollow.FindStringSubmatch(perr.Text); m != nil {
+			src += "\n" + m[1] + " :"
+		} else {
+			return nil, "", false
+		}
+	}
+
+	return nil, "", false
+}
+
+// contextFor finds what the end of ctx is in, by parsing it as shell.
+// If ctx can't be parsed, the last word is the one being completed.
+func contextFor(ctx string) completionContext {
+	c := completionContext{index: -1}
+
+	file, src, ok := parsePartial(ctx + cursorMark)
+	if !ok {
+		c.word = ctx[strings.LastIndexAny(ctx, " \t\n") + 1:]
+		c.value = c.word
+		return c
+	}
+
+	off := uint(len(ctx))
+	contains := func(n syntax.Node) bool {
+		return n.Pos().Offset() <= off && off < n.End().Offset()
+	}
+
+	var stmt *syntax.Stmt
+	var word *syntax.Word
+	var param *syntax.ParamExp
+	var quote syntax.Node
+	syntax.Walk(file, func(n syntax.Node) bool {
+		if n == nil || !contains(n) {
+			return true
+		}
+		// the innermost nodes start last
+		switch n := n.(type) {
+		case *syntax.Stmt:
+			stmt = n
+		case *syntax.Word:
+			word = n
+			param = nil
+			quote = nil
+		case *syntax.ParamExp:
+			if n.Param != nil && contains(n.Param) {
+				param = n
+			}
+		case *syntax.SglQuoted, *syntax.DblQuoted:
+			quote = n
+		}
+		return true
+	})
+
+	if word == nil {
+		return c
+	}
+	c.word = ctx[word.Pos().Offset():]
+	c.value = cutAtCursor(wordValue(src, word))
+	value := c.value
+	switch quote.(type) {
+	case *syntax.SglQuoted:
+		c.quote = '\''
+	case *syntax.DblQuoted:
+		c.quote = '"'
+	}
+	if param != nil {
+		c.variable = true
+		c.value = cutAtCursor(param.Param.Value)
+	}
+
+	if stmt == nil {
+		return c
+	}
+	for _, r := range stmt.Redirs {
+		if r.Word == word {
+			c.redirect = true
+		}
+	}
+	call, ok := stmt.Cmd.(*syntax.CallExpr)
+	if !ok {
+		return c
+	}
+	for _, as := range call.Assigns {
+		if as.Value == word && as.Name != nil {
+			c.assignment = as.Name.Value
+		}
+	}
+
+	var vals []string
+	index := len(call.Args)
+	for i, arg := range call.Args {
+		if arg == word {
+			index = i
+			vals = append(vals, value)
+			break
+		}
+		vals = append(vals, wordValue(src, arg))
+	}
+
+	cmd := 0
+	for cmd < len(vals) && cmd != index {
+		opts, ok := cmdWrappers[vals[cmd]]
+		if !ok {
+			break
+		}
+
+		j := cmd + 1
+		for j < len(vals) && j != index && (strings.HasPrefix(vals[j], "-") || (vals[cmd] == "env" && strings.Contains(vals[j], "="))) {
+			for _, opt := range opts {
+				if vals[j] == opt {
+					// skip the option's argument too
+					j++
+					break
+				}
+			}
+			j++
+		}
+		// the cursor is in the wrapper's options
+		if j > index || j >= len(vals) || (j == index && strings.HasPrefix(vals[j], "-")) {
+			break
+		}
+		cmd = j
+	}
+
+	if cmd < len(vals) {
+		c.command = vals[cmd]
+		c.args = vals[cmd:]
+	}
+	if index < len(call.Args) {
+		c.index = index - cmd
+	}
+
+	return c
+}
+
+// module returns the context for Go completers.
+func (c completionContext) module() moduleapi.CompletionContext {
+	comp := moduleapi.CompletionContext{
+		Command: c.command,
+		Index: c.index,
+		Args: c.args,
+		Word: c.word,
+		Value: c.value,
+		Variable: c.variable,
+		Redirect: c.redirect,
+		Assignment: c.assignment,
+	}
+	if c.quote != 0 {
+		comp.Quote = string(c.quote)
+	}
+
+	return comp
+}
+
+func cutAtCursor(s string) string {
+	if i := strings.Index(s, cursorMark); i != -1 {
+		return s[:i]
+	}
+
+	return s
+}
+
+// wordValue returns the value of a word with its quotes and escapes
+// removed. Expansions are left as they were typed.
+func wordValue(src string, w *syntax.Word) string {
+	sb := &strings.Builder{}
+	for _, part := range w.Parts {
+		writeWordPart(sb, src, part, false)
+	}
+
+	return sb.String()
+}
+
+func writeWordPart(sb *strings.Builder, src string, part syntax.WordPart, quoted bool) {
+	switch p := part.(type) {
+	case *syntax.Lit:
+		for i := 0; i < len(p.Value); i++ {
+			// in double quotes, only some characters are escaped
+			if p.Value[i] == '\\' && i + 1 < len(p.Value) && (!quoted || strings.IndexByte("\"\\$`", p.Value[i + 1]) != -1) {
+				i++
+			}
+			sb.WriteByte(p.Value[i])
+		}
+	case *syntax.SglQuoted:
+		sb.WriteString(p.Value)
+	case *syntax.DblQuoted:
+		for _, pp := range p.Parts {
+			writeWordPart(sb, src, pp, true)
+		}
+	default:
+		sb.WriteString(src[part.Pos().Offset():part.End().Offset()])
+	}
+}
+
+// #interface completion
+// context(line, pos) -> table
+// Returns what the cursor is in on the `line` of shell input, which is
+// parsed as shell script (unfinished input included), so quotes,
+// pipelines and other syntax are understood.
+// The returned table has these keys:
+// - `command` (string): The command of the simple command the cursor
+// is in. Commands that run other commands, like `sudo` and `env`, and
+// their options are skipped, so this is `git` for `sudo git che`.
+// It is nil if the cursor isn't in a simple command.
+// - `index` (number): The index of the word being typed in the command,
+// where 0 is the command itself (so the word is `args[index + 1]`).
+// It is nil if the word isn't an argument, like a redirection target.
+// - `args` (table): The words of the command from `command` up to the
+// cursor, with their quotes removed.
+// - `word` (string): The word being typed, as it is typed.
+// - `value` (string): The word with its quotes removed, or the name
+// of the variable being typed after a `$`.
+// - `quote` (string): The quote the cursor is in (`'` or `"`), or nil.
+// - `variable` (boolean): Whether a variable name is being typed after `$`.
+// - `redirect` (boolean): Whether the word is the target of a redirection.
+// - `assignment` (string): The name of the variable whose value is
+// being typed, for `NAME=value`, or nil.
+// #param line string
+// #param pos number Position of the cursor in `line`, as a byte offset (like the `pos` passed to the completion handler). Defaults to the end of it.
+/*
+#example
+local ctx = hilbish.completion.context('ls | grep "foo b')
+-- ctx.command is 'grep', ctx.index is 1, ctx.quote is '"',
+-- ctx.word is '"foo b' and ctx.value is 'foo b'
+#example
+*/
+func hcmpContext(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+	if err := c.Check1Arg(); err != nil {
+		return nil, err
+	}
+	line, err := c.StringArg(0)
+	if err != nil {
+		return nil, err
+	}
+	if c.NArgs() > 1 {
+		pos, err := c.IntArg(1)
+		if err != nil {
+			return nil, err
+		}
+		if pos >= 0 && int(pos) < len(line) {
+			line = line[:pos]
+		}
+	}
+
+	ctx := contextFor(line)
+	tbl := rt.NewTable()
+	if len(ctx.args) != 0 {
+		tbl.Set(rt.StringValue("command"), rt.StringValue(ctx.command))
+	}
+	if ctx.index != -1 {
+		tbl.Set(rt.StringValue("index"), rt.IntValue(int64(ctx.index)))
+	}
+	args := rt.NewTable()
+	for i, arg := range ctx.args {
+		args.Set(rt.IntValue(int64(i + 1)), rt.StringValue(arg))
+	}
+	tbl.Set(rt.StringValue("args"), rt.TableValue(args))
+	tbl.Set(rt.StringValue("word"), rt.StringValue(ctx.word))
+	tbl.Set(rt.StringValue("value"), rt.StringValue(ctx.value))
+	if ctx.quote != 0 {
+		tbl.Set(rt.StringValue("quote"), rt.StringValue(string(ctx.quote)))
+	}
+	tbl.Set(rt.StringValue("variable"), rt.BoolValue(ctx.variable))
+	tbl.Set(rt.StringValue("redirect"), rt.BoolValue(ctx.redirect))
+	if ctx.assignment != "" {
+		tbl.Set(rt.StringValue("assignment"), rt.StringValue(ctx.assignment))
+	}
+
+	return c.PushingNext1(t.Runtime, rt.TableValue(tbl)), nil
+}
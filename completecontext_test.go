@@ -0,0 +1,100 @@
+package hilbish
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParsePartial(t *testing.T) {
+	type TestParsePartialT struct {
+		Src string
+		Expected string
+		Fail bool
+	}
+
+	tests := []TestParsePartialT{
+		{Src: "ls -la", Expected: "ls -la"},
+		{Src: `echo "foo`, Expected: `echo "foo"`},
+		{Src: "echo 'foo", Expected: "echo 'foo'"},
+		{Src: "echo $(ls", Expected: "echo $(ls\n)"},
+		{Src: "echo ${HO", Expected: "echo ${HO}"},
+		{Src: "echo \"$(ls 'a", Expected: "echo \"$(ls 'a'\n)\""},
+		{Src: "if true; then echo", Expected: "if true; then echo\nfi"},
+		{Src: "for f in a b", Expected: "for f in a b\ndo :\ndone"},
+		{Src: "while true", Expected: "while true\ndo :\ndone"},
+		{Src: "echo ;; x", Fail: true},
+	}
+
+	for _, test := range tests {
+		file, src, ok := parsePartial(test.Src)
+		if test.Fail {
+			if ok {
+				t.Errorf("%q: expected it not to parse, got %q", test.Src, src)
+			}
+			continue
+		}
+		if !ok || file == nil {
+			t.Errorf("%q: expected it to parse", test.Src)
+			continue
+		}
+		if src != test.Expected {
+			t.Errorf("%q: expected it to be finished as %q, got %q", test.Src, test.Expected, src)
+		}
+	}
+}
+
+func TestContextFor(t *testing.T) {
+	type TestContextForT struct {
+		Ctx string
+		Expected completionContext
+	}
+
+	tests := []TestContextForT{
+		{Ctx: "", Expected: completionContext{index: 0, args: []string{""}}},
+		{Ctx: "l", Expected: completionContext{command: "l", index: 0, args: []string{"l"}, word: "l", value: "l"}},
+		{Ctx: "ls -l", Expected: completionContext{command: "ls", index: 1, args: []string{"ls", "-l"}, word: "-l", value: "-l"}},
+		{Ctx: "ls ", Expected: completionContext{command: "ls", index: 1, args: []string{"ls", ""}}},
+		{Ctx: "ls a b", Expected: completionContext{command: "ls", index: 2, args: []string{"ls", "a", "b"}, word: "b", value: "b"}},
+		// pipelines and lists
+		{Ctx: `ls | grep "foo b`, Expected: completionContext{command: "grep", index: 1, args: []string{"grep", "foo b"}, word: `"foo b`, value: "foo b", quote: '"'}},
+		{Ctx: "make && ./run te", Expected: completionContext{command: "./run", index: 1, args: []string{"./run", "te"}, word: "te", value: "te"}},
+		{Ctx: "cd /tmp; git ", Expected: completionContext{command: "git", index: 1, args: []string{"git", ""}}},
+		// quotes and escapes
+		{Ctx: "cat 'my fi", Expected: completionContext{command: "cat", index: 1, args: []string{"cat", "my fi"}, word: "'my fi", value: "my fi", quote: '\''}},
+		{Ctx: `cat my\ fi`, Expected: completionContext{command: "cat", index: 1, args: []string{"cat", "my fi"}, word: `my\ fi`, value: "my fi"}},
+		{Ctx: `cat "a b" 'it'"s`, Expected: completionContext{command: "cat", index: 2, args: []string{"cat", "a b", "its"}, word: `'it'"s`, value: "its", quote: '"'}},
+		{Ctx: `echo "a \"b`, Expected: completionContext{command: "echo", index: 1, args: []string{"echo", `a "b`}, word: `"a \"b`, value: `a "b`, quote: '"'}},
+		// commands that run other commands
+		{Ctx: "sudo git che", Expected: completionContext{command: "git", index: 1, args: []string{"git", "che"}, word: "che", value: "che"}},
+		{Ctx: "sudo -u root git che", Expected: completionContext{command: "git", index: 1, args: []string{"git", "che"}, word: "che", value: "che"}},
+		{Ctx: "sudo -E nice -n 5 make ", Expected: completionContext{command: "make", index: 1, args: []string{"make", ""}}},
+		{Ctx: "env FOO=1 BAR=2 git st", Expected: completionContext{command: "git", index: 1, args: []string{"git", "st"}, word: "st", value: "st"}},
+		{Ctx: "sudo gi", Expected: completionContext{command: "gi", index: 0, args: []string{"gi"}, word: "gi", value: "gi"}},
+		{Ctx: "sudo -", Expected: completionContext{command: "sudo", index: 1, args: []string{"sudo", "-"}, word: "-", value: "-"}},
+		{Ctx: "sudo -u ro", Expected: completionContext{command: "sudo", index: 2, args: []string{"sudo", "-u", "ro"}, word: "ro", value: "ro"}},
+		// variables
+		{Ctx: "echo $HO", Expected: completionContext{command: "echo", index: 1, args: []string{"echo", "$HO"}, word: "$HO", value: "HO", variable: true}},
+		{Ctx: `echo "$HO`, Expected: completionContext{command: "echo", index: 1, args: []string{"echo", "$HO"}, word: `"$HO`, value: "HO", quote: '"', variable: true}},
+		{Ctx: "echo ${HO", Expected: completionContext{command: "echo", index: 1, args: []string{"echo", "${HO"}, word: "${HO", value: "HO", variable: true}},
+		{Ctx: "echo '$HO", Expected: completionContext{command: "echo", index: 1, args: []string{"echo", "$HO"}, word: "'$HO", value: "$HO", quote: '\''}},
+		// redirections and assignments
+		{Ctx: "cat < fi", Expected: completionContext{command: "cat", index: -1, args: []string{"cat"}, word: "fi", value: "fi", redirect: true}},
+		{Ctx: "ls -l > ou", Expected: completionContext{command: "ls", index: -1, args: []string{"ls", "-l"}, word: "ou", value: "ou", redirect: true}},
+		{Ctx: "FOO=ba", Expected: completionContext{index: -1, word: "ba", value: "ba", assignment: "FOO"}},
+		{Ctx: "FOO=1 make ta", Expected: completionContext{command: "make", index: 1, args: []string{"make", "ta"}, word: "ta", value: "ta"}},
+		// unclosed substitutions and blocks
+		{Ctx: "echo $(ls su", Expected: completionContext{command: "ls", index: 1, args: []string{"ls", "su"}, word: "su", value: "su"}},
+		{Ctx: "if true; then ec", Expected: completionContext{command: "ec", index: 0, args: []string{"ec"}, word: "ec", value: "ec"}},
+		{Ctx: "for f in *; do cat ", Expected: completionContext{command: "cat", index: 1, args: []string{"cat", ""}}},
+		// input that can't be parsed uses the last word
+		{Ctx: "echo ;; fo", Expected: completionContext{index: -1, word: "fo", value: "fo"}},
+	}
+
+	for _, test := range tests {
+		c := contextFor(test.Ctx)
+		got, expected := fmt.Sprintf("%+v", c), fmt.Sprintf("%+v", test.Expected)
+		if got != expected {
+			t.Errorf("%q:\nexpected %s\ngot      %s", test.Ctx, expected, got)
+		}
+	}
+}
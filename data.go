@@ -0,0 +1,236 @@
+package hilbish
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"hilbish/util"
+
+	rt "github.com/arnodel/golua/runtime"
+)
+
+// The API docs (and the function signatures used for hints) are made by
+// docgen from the doc comments of the Go code.
+//go:generate go run ./cmd/docgen
+
+// The nature prelude, Lua libraries, docs and sample config are built into
+// the binary, so Hilbish still works fully if its data dir is missing.
+// Files in the data dir on disk take priority over these.
+//go:embed .hilbishrc.lua nature libs all:docs emmyLuaDocs
+var dataFS embed.FS
+
+// templates used to find a module in the embedded files,
+// the same as the ones used for the data dir in package.path
+var dataSearchPaths = []string{
+	"?.lua",
+	"?/init.lua",
+	"?/?.lua",
+	"libs/?/init.lua",
+	"libs/?/?.lua",
+	"libs/?.lua",
+}
+
+// readData reads a file in Hilbish's data dir, relative to it.
+// If it doesn't exist on disk, the embedded file is used.
+func readData(path string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(dataDir, filepath.FromSlash(path)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return dataFS.ReadFile(embedPath(path))
+	}
+
+	return data, err
+}
+
+// readDataDir returns the names of the entries of a directory in
+// Hilbish's data dir, with the same fallback as readData.
+func readDataDir(path string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(dataDir, filepath.FromSlash(path)))
+	if errors.Is(err, fs.ErrNotExist) {
+		entries, err = dataFS.ReadDir(embedPath(path))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+
+	return names, nil
+}
+
+// embedPath cleans a path to be used with the embedded files,
+// which can't have leading slashes or dots.
+func embedPath(path string) string {
+	path = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(path)), "/")
+	if path == "" {
+		return "."
+	}
+
+	return path
+}
+
+// doDataFile runs the Lua file at diskPath, or the embedded file at
+// path if there is nothing on disk.
+func (sh *Shell) doDataFile(diskPath, path string) error {
+	err := sh.doFile(diskPath)
+	if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	done := sh.profile.track("[embedded] " + path)
+	chunk, cached, err := loadDataChunk(sh.runtime, path)
+	if err != nil {
+		done(false)
+		return err
+	}
+
+	_, err = rt.Call1(sh.runtime.MainThread(), rt.FunctionValue(chunk))
+	done(cached)
+
+	return err
+}
+
+// loadDataChunk loads the embedded Lua file at path, through the chunk cache.
+func loadDataChunk(rtm *rt.Runtime, path string) (*rt.Closure, bool, error) {
+	code, err := dataFS.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// embedded files have no modification time, so they're keyed by their
+	// contents. it only gets hashed along with the name.
+	return util.LoadChunk(rtm, path, string(code), code)
+}
+
+// dataSearcher is a package searcher for modules embedded in the binary.
+func (sh *Shell) dataSearcher(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+	if err := c.Check1Arg(); err != nil {
+		return nil, err
+	}
+	name, err := c.StringArg(0)
+	if err != nil {
+		return nil, err
+	}
+
+	namePath := strings.ReplaceAll(name, ".", "/")
+	tried := make([]string, len(dataSearchPaths))
+	for i, template := range dataSearchPaths {
+		path := strings.ReplaceAll(template, "?", namePath)
+		if _, err := fs.Stat(dataFS, path); err == nil {
+			loader := rt.NewGoFunction(sh.dataChunkLoader, "dataChunkLoader", 2, false)
+			return c.PushingNext(t.Runtime, rt.FunctionValue(loader), rt.StringValue(path)), nil
+		}
+		tried[i] = "[embedded] " + path
+	}
+
+	return c.PushingNext1(t.Runtime, rt.StringValue(strings.Join(tried, "\n"))), nil
+}
+
+func (sh *Shell) dataChunkLoader(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+	if err := c.CheckNArgs(2); err != nil {
+		return nil, err
+	}
+	name, err := c.StringArg(0)
+	if err != nil {
+		return nil, err
+	}
+	path, err := c.StringArg(1)
+	if err != nil {
+		return nil, err
+	}
+
+	done := sh.profile.track(name)
+	chunk, cached, err := loadDataChunk(t.Runtime, path)
+	if err != nil {
+		done(false)
+		return nil, err
+	}
+
+	mod, err := rt.Call1(t, rt.FunctionValue(chunk), c.Arg(0), c.Arg(1))
+	done(cached)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.PushingNext1(t.Runtime, mod), nil
+}
+
+// #interface data
+// builtin data files
+// The data interface reads the files Hilbish ships with,
+// like the docs and nature modules. Paths are relative to `hilbish.dataDir`.
+// If a file is not in the data dir on disk, the copy built into
+// Hilbish is used instead.
+func dataLoader(rtm *rt.Runtime) *rt.Table {
+	exports := map[string]util.LuaExport{
+		"read": {dataRead, 1, false},
+		"readdir": {dataReaddir, 1, false},
+	}
+
+	mod := rt.NewTable()
+	util.SetExports(rtm, mod, exports)
+
+	return mod
+}
+
+// #interface data
+// read(path) -> string
+// Returns the contents of the data file at `path`.
+// It will throw if the file does not exist.
+// #param path string
+// #returns string
+/*
+#example
+local index = hilbish.data.read 'docs/api/_index.md'
+#example
+*/
+func dataRead(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+	if err := c.Check1Arg(); err != nil {
+		return nil, err
+	}
+	path, err := c.StringArg(0)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := readData(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.PushingNext1(t.Runtime, rt.StringValue(string(data))), nil
+}
+
+// #interface data
+// readdir(path) -> table[string]
+// Returns a list of the names of the entries in the data directory at `path`.
+// It will throw if the directory does not exist.
+// #param path string
+// #returns table
+func dataReaddir(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+	if err := c.Check1Arg(); err != nil {
+		return nil, err
+	}
+	path, err := c.StringArg(0)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := readDataDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := rt.NewTable()
+	for i, name := range names {
+		entries.Set(rt.IntValue(int64(i + 1)), rt.StringValue(name))
+	}
+
+	return c.PushingNext1(t.Runtime, rt.TableValue(entries)), nil
+}
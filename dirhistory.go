@@ -0,0 +1,106 @@
+package hilbish
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// dirHistory is the history of commands run in a directory, or in the
+// git repository it is in. It is used as the history for Alt-R.
+type dirHistory struct {
+	hist *fileHistory
+	root string
+	cmds []string
+
+	// how many history entries have been looked at, and at which generation
+	indexed int
+	gen int
+}
+
+func newDirHistory(hist *fileHistory) *dirHistory {
+	return &dirHistory{
+		hist: hist,
+	}
+}
+
+// setDir scopes the history to dir, or the root of the git repository it is in.
+// It returns the directory the history is scoped to.
+func (d *dirHistory) setDir(dir string) string {
+	root := historyRoot(dir)
+	if root != d.root {
+		d.root = root
+		d.cmds = nil
+		d.indexed = 0
+	}
+
+	return root
+}
+
+// update adds the commands run in the directory since it was last called.
+func (d *dirHistory) update() {
+	entries, reset, gen := d.hist.since(d.indexed, d.gen)
+	if reset {
+		d.cmds = nil
+		d.indexed = 0
+	}
+	d.gen = gen
+
+	for _, entry := range entries {
+		if inDir(entry.Cwd, d.root) {
+			d.cmds = append(d.cmds, entry.Cmd)
+		}
+	}
+	d.indexed += len(entries)
+}
+
+func (d *dirHistory) Write(line string) (int, error) {
+	// commands are added to the main history
+	return d.Len(), nil
+}
+
+func (d *dirHistory) GetLine(idx int) (string, error) {
+	d.update()
+	if idx < 0 || idx >= len(d.cmds) {
+		return "", nil
+	}
+
+	return d.cmds[idx], nil
+}
+
+func (d *dirHistory) Len() int {
+	d.update()
+	return len(d.cmds)
+}
+
+func (d *dirHistory) Dump() interface{} {
+	return d.cmds
+}
+
+// historyRoot returns the root of the git repository dir is in,
+// or dir itself if it isn't in one.
+func historyRoot(dir string) string {
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			return d
+		}
+
+		parent := filepath.Dir(d)
+		if parent == d {
+			return dir
+		}
+		d = parent
+	}
+}
+
+// inDir returns whether path is dir or inside of it.
+func inDir(path, dir string) bool {
+	if path == "" || dir == "" {
+		return false
+	}
+	if path == dir {
+		return true
+	}
+
+	return strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator)) + string(filepath.Separator))
+}
@@ -0,0 +1,161 @@
+package hilbish
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"hilbish/util"
+
+	rt "github.com/arnodel/golua/runtime"
+)
+
+// makeRepo makes a directory with a git repository at repo/ in it,
+// and returns the directory.
+func makeRepo(t *testing.T) string {
+	dir := t.TempDir()
+	for _, sub := range []string{"repo/.git", "repo/src/lib", "other"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestHistoryRoot(t *testing.T) {
+	dir := makeRepo(t)
+	// a worktree has a .git file instead
+	os.MkdirAll(filepath.Join(dir, "worktree", "src"), 0755)
+	os.WriteFile(filepath.Join(dir, "worktree", ".git"), []byte("gitdir: ../repo/.git\n"), 0644)
+
+	type TestHistoryRootT struct {
+		Dir string
+		Expected string
+	}
+
+	tests := []TestHistoryRootT{
+		{Dir: "repo", Expected: "repo"},
+		{Dir: "repo/src/lib", Expected: "repo"},
+		{Dir: "worktree/src", Expected: "worktree"},
+		// outside of a repository, it's the directory itself
+		{Dir: "other", Expected: "other"},
+		{Dir: ".", Expected: "."},
+	}
+
+	for _, test := range tests {
+		root := historyRoot(filepath.Join(dir, test.Dir))
+		if expected := filepath.Join(dir, test.Expected); root != expected {
+			t.Errorf("%q: expected %q, got %q", test.Dir, expected, root)
+		}
+	}
+}
+
+func TestInDir(t *testing.T) {
+	type TestInDirT struct {
+		Path string
+		Dir string
+		Expected bool
+	}
+
+	tests := []TestInDirT{
+		{Path: "/src/hilbish", Dir: "/src/hilbish", Expected: true},
+		{Path: "/src/hilbish/golibs", Dir: "/src/hilbish", Expected: true},
+		{Path: "/src/hilbish/golibs", Dir: "/src/hilbish/", Expected: true},
+		{Path: "/src/hilbish-old", Dir: "/src/hilbish", Expected: false},
+		{Path: "/src", Dir: "/src/hilbish", Expected: false},
+		{Path: "", Dir: "/src", Expected: false},
+		{Path: "/src", Dir: "", Expected: false},
+	}
+
+	for _, test := range tests {
+		if in := inDir(test.Path, test.Dir); in != test.Expected {
+			t.Errorf("%q in %q: expected %v, got %v", test.Path, test.Dir, test.Expected, in)
+		}
+	}
+}
+
+func dirHistoryCmds(d *dirHistory) []string {
+	cmds := []string{}
+	for i := 0; i < d.Len(); i++ {
+		cmd, _ := d.GetLine(i)
+		cmds = append(cmds, cmd)
+	}
+
+	return cmds
+}
+
+func TestDirHistory(t *testing.T) {
+	dir := makeRepo(t)
+	repo, lib, other := filepath.Join(dir, "repo"), filepath.Join(dir, "repo", "src", "lib"), filepath.Join(dir, "other")
+
+	h := newFileHistory(filepath.Join(dir, ".hilbish-history"))
+	defer h.close()
+	h.add(historyEntry{Cmd: "make", Cwd: repo})
+	h.add(historyEntry{Cmd: "ls", Cwd: other})
+	h.add(historyEntry{Cmd: "go test", Cwd: lib})
+	h.add(historyEntry{Cmd: "echo no dir"})
+
+	d := newDirHistory(h)
+	if root := d.setDir(lib); root != repo {
+		t.Errorf("expected the history to be scoped to %q, got %q", repo, root)
+	}
+	expected := []string{"make", "go test"}
+	if cmds := dirHistoryCmds(d); !reflect.DeepEqual(cmds, expected) {
+		t.Errorf("expected %q in the repository, got %q", expected, cmds)
+	}
+
+	// commands added later are included
+	h.add(historyEntry{Cmd: "git status", Cwd: repo})
+	expected = append(expected, "git status")
+	if cmds := dirHistoryCmds(d); !reflect.DeepEqual(cmds, expected) {
+		t.Errorf("expected %q after adding to history, got %q", expected, cmds)
+	}
+
+	d.setDir(other)
+	if cmds := dirHistoryCmds(d); !reflect.DeepEqual(cmds, []string{"ls"}) {
+		t.Errorf("expected %q in another directory, got %q", []string{"ls"}, cmds)
+	}
+}
+
+func TestHistoryForDir(t *testing.T) {
+	dir := makeRepo(t)
+	repo, lib, other := filepath.Join(dir, "repo"), filepath.Join(dir, "repo", "src", "lib"), filepath.Join(dir, "other")
+
+	sh := New(WithHistory(filepath.Join(dir, ".hilbish-history")))
+	defer sh.Close()
+	sh.lr.fileHist.add(historyEntry{Cmd: "make", Cwd: repo})
+	sh.lr.fileHist.add(historyEntry{Cmd: "ls", Cwd: other})
+	sh.lr.fileHist.add(historyEntry{Cmd: "go test", Cwd: lib})
+
+	type TestHistoryForDirT struct {
+		Path string
+		Expected []string
+	}
+
+	tests := []TestHistoryForDirT{
+		// the whole repository's history, from anywhere in it
+		{Path: lib, Expected: []string{"make", "go test"}},
+		{Path: repo, Expected: []string{"make", "go test"}},
+		{Path: other, Expected: []string{"ls"}},
+		{Path: filepath.Join(dir, "nothing"), Expected: []string{}},
+	}
+
+	for _, test := range tests {
+		sh.runtime.GlobalEnv().Set(rt.StringValue("path"), rt.StringValue(test.Path))
+		entries, err := util.DoString(sh.runtime, "return hilbish.history.forDir(path)")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cmds := []string{}
+		for i := int64(1); i <= entries.AsTable().Len(); i++ {
+			entry := entries.AsTable().Get(rt.IntValue(i)).AsTable()
+			cmds = append(cmds, entry.Get(rt.StringValue("cmd")).AsString())
+		}
+		if !reflect.DeepEqual(cmds, test.Expected) {
+			t.Errorf("%q: expected %q, got %q", test.Path, test.Expected, cmds)
+		}
+	}
+}
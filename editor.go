@@ -1,22 +1,29 @@
-package main
+package hilbish
 
 import (
+	"errors"
+	"fmt"
+
 	"hilbish/util"
 
 	rt "github.com/arnodel/golua/runtime"
+	"github.com/maxlandon/readline"
 )
 
 // #interface editor
 // interactions for Hilbish's line reader
 // The hilbish.editor interface provides functions to
 // directly interact with the line editor in use.
-func editorLoader(rtm *rt.Runtime) *rt.Table {
+func (sh *Shell) editorLoader(rtm *rt.Runtime) *rt.Table {
 	exports := map[string]util.LuaExport{
-		"insert": {editorInsert, 1, false},
-		"setVimRegister": {editorSetRegister, 1, false},
-		"getVimRegister": {editorGetRegister, 2, false},
-		"getLine": {editorGetLine, 0, false},
-		"readChar": {editorReadChar, 0, false},
+		"bind": {sh.editorBind, 2, true},
+		"insert": {sh.editorInsert, 1, false},
+		"setVimRegister": {sh.editorSetRegister, 1, false},
+		"getVimRegister": {sh.editorGetRegister, 2, false},
+		"getLine": {sh.editorGetLine, 0, false},
+		"paste": {sh.editorPaste, 1, false},
+		"readChar": {sh.editorReadChar, 0, false},
+		"unbind": {sh.editorUnbind, 1, true},
 	}
 
 	mod := rt.NewTable()
@@ -29,7 +36,7 @@ func editorLoader(rtm *rt.Runtime) *rt.Table {
 // insert(text)
 // Inserts text into the Hilbish command line.
 // #param text string
-func editorInsert(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+func (sh *Shell) editorInsert(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	if err := c.Check1Arg(); err != nil {
 		return nil, err
 	}
@@ -39,7 +46,7 @@ func editorInsert(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 		return nil, err
 	}
 
-	lr.rl.Insert(text)
+	sh.lr.rl.Insert(text)
 
 	return c.Next(), nil
 }
@@ -49,7 +56,7 @@ func editorInsert(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 // Sets the vim register at `register` to hold the passed text.
 // #aram register string
 // #param text string
-func editorSetRegister(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+func (sh *Shell) editorSetRegister(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	if err := c.Check1Arg(); err != nil {
 		return nil, err
 	}
@@ -64,7 +71,7 @@ func editorSetRegister(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 		return nil, err
 	}
 
-	lr.rl.SetRegisterBuf(register, []rune(text))
+	sh.lr.rl.SetRegisterBuf(register, []rune(text))
 
 	return c.Next(), nil
 }
@@ -73,7 +80,7 @@ func editorSetRegister(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 // getVimRegister(register) -> string
 // Returns the text that is at the register.
 // #param register string
-func editorGetRegister(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+func (sh *Shell) editorGetRegister(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	if err := c.Check1Arg(); err != nil {
 		return nil, err
 	}
@@ -83,7 +90,7 @@ func editorGetRegister(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 		return nil, err
 	}
 
-	buf := lr.rl.GetFromRegister(register)
+	buf := sh.lr.rl.GetFromRegister(register)
 
 	return c.PushingNext1(t.Runtime, rt.StringValue(string(buf))), nil
 }
@@ -92,17 +99,187 @@ func editorGetRegister(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 // getLine() -> string
 // Returns the current input line.
 // #returns string
-func editorGetLine(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
-	buf := lr.rl.GetLine()
+func (sh *Shell) editorGetLine(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+	buf := sh.lr.rl.GetLine()
 
 	return c.PushingNext1(t.Runtime, rt.StringValue(string(buf))), nil
 }
 
+// #interface editor
+// paste(text) -> string
+// Paste handler, which returns the text to insert for a paste.
+// It is called with text that was pasted into the terminal, before it is
+// inserted at the cursor. If it returns nothing, the paste is dropped.
+// Pasted text is inserted as it is instead of being handled like typed keys,
+// so tabs don't start completion, and a multi-line paste stays in the line
+// until Enter is pressed. Line endings have already been made newlines and
+// other control characters removed from the text. By default, it is
+// returned unchanged. To change or refuse pastes, override this function.
+// #param text string
+// #returns string
+/*
+#example
+-- remove the prompts from commands copied off a web page,
+-- and refuse to paste more than 100 lines.
+function hilbish.editor.paste(text)
+	local _, lines = text:gsub('\n', '')
+	if lines >= 100 then return end
+
+	return (text:gsub('^%$ ', ''):gsub('\n%$ ', '\n'))
+end
+#example
+*/
+func (sh *Shell) editorPaste(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+	if err := c.Check1Arg(); err != nil {
+		return nil, err
+	}
+
+	text, err := c.StringArg(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.PushingNext1(t.Runtime, rt.StringValue(text)), nil
+}
+
 // #interface editor
 // getChar() -> string
 // Reads a keystroke from the user. This is in a format of something like Ctrl-L.
-func editorReadChar(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
-	buf := lr.rl.ReadChar()
+func (sh *Shell) editorReadChar(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+	buf := sh.lr.rl.ReadChar()
 
 	return c.PushingNext1(t.Runtime, rt.StringValue(string(buf))), nil
 }
+
+// #interface editor
+// bind(keys, action, opts)
+// Binds a key sequence to `action`, which is either a function to call
+// or the name of an editor action. Keys in the sequence are separated
+// by spaces, like `Ctrl-X Ctrl-E`. A key can be a character, or a name
+// like `Enter`, `Tab`, `Escape`, `Backspace`, `Space`, `Up`, `Down`,
+// `Left`, `Right`, `Home`, `End`, `Delete`, `Page-Up` or `Page-Down`,
+// with a `Ctrl-` or `Alt-` modifier.
+// The `mode` in `opts` is the input mode to bind the keys in: `emacs`,
+// or `insert` or `normal` for Vim mode. If it isn't set, the keys are
+// bound in both `emacs` and `insert` modes.
+// The editor actions are `accept-hint`, `accept-line`, `alt-history-search`,
+// `backward-char`, `backward-delete-char`, `backward-kill-line`,
+// `backward-kill-word`, `backward-word`, `beginning-of-line`,
+// `capitalize-word`, `clear-screen`, `complete`, `delete-char`,
+// `digit-argument`, `downcase-word`, `edit-and-execute-command`,
+// `edit-command-line`, `end-of-line`, `finder-cd`, `finder-files`,
+// `finder-history`, `forward-char`, `forward-word`, `history-search`,
+// `kill-line`, `kill-word`, `next-history`, `previous-history`, `redo`,
+// `transpose-chars`, `transpose-words`, `undo`, `upcase-word`,
+// `vi-insert-mode`, `vi-normal-mode`, `yank`, `yank-last-arg`,
+// `yank-nth-arg` and `yank-pop`.
+// #param keys string
+// #param action string|function
+// #param opts table|nil
+// #example
+/*
+-- Ctrl-X Ctrl-T inserts the date
+hilbish.editor.bind('Ctrl-X Ctrl-T', function()
+	hilbish.editor.insert(os.date '%Y-%m-%d')
+end)
+
+-- Ctrl-X Ctrl-K kills to the end of the line in Vim normal mode too
+hilbish.editor.bind('Ctrl-X Ctrl-K', 'kill-line', {mode = 'normal'})
+*/
+// #example
+func (sh *Shell) editorBind(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+	if err := c.CheckNArgs(2); err != nil {
+		return nil, err
+	}
+	keys, err := c.StringArg(0)
+	if err != nil {
+		return nil, err
+	}
+	modes, err := keymapModes(c, "bind")
+	if err != nil {
+		return nil, err
+	}
+
+	action := c.Arg(1)
+	if name, ok := action.TryString(); ok {
+		for _, mode := range modes {
+			if err := sh.lr.rl.BindAction(mode, keys, name); err != nil {
+				return nil, err
+			}
+		}
+		return c.Next(), nil
+	}
+
+	if _, ok := action.TryCallable(); !ok {
+		return nil, errors.New("bad argument #2 to bind (expected string or function, got " + action.TypeName() + ")")
+	}
+	for _, mode := range modes {
+		err := sh.lr.rl.Bind(mode, keys, func() {
+			_, err := rt.Call1(sh.runtime.MainThread(), action)
+			if err != nil {
+				fmt.Println(err)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c.Next(), nil
+}
+
+// #interface editor
+// unbind(keys, opts)
+// Removes the binding for a key sequence, so the keys go back to
+// what they do by default. `opts` takes the same `mode` as `bind`.
+// #param keys string
+// #param opts table|nil
+func (sh *Shell) editorUnbind(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+	if err := c.Check1Arg(); err != nil {
+		return nil, err
+	}
+	keys, err := c.StringArg(0)
+	if err != nil {
+		return nil, err
+	}
+	modes, err := keymapModes(c, "unbind")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, mode := range modes {
+		if err := sh.lr.rl.Unbind(mode, keys); err != nil {
+			return nil, err
+		}
+	}
+
+	return c.Next(), nil
+}
+
+// keymapModes returns the modes to bind keys in from
+// the opts table passed after the other arguments.
+func keymapModes(c *rt.GoCont, fn string) ([]string, error) {
+	var opts rt.Value
+	if len(c.Etc()) != 0 {
+		opts = c.Etc()[0]
+	}
+	if opts.IsNil() {
+		return []string{readline.KeymapEmacs, readline.KeymapInsert}, nil
+	}
+
+	tbl, ok := opts.TryTable()
+	if !ok {
+		return nil, fmt.Errorf("bad opts to %s (expected table, got %s)", fn, opts.TypeName())
+	}
+
+	mode := tbl.Get(rt.StringValue("mode"))
+	if mode.IsNil() {
+		return []string{readline.KeymapEmacs, readline.KeymapInsert}, nil
+	}
+	modeStr, ok := mode.TryString()
+	if !ok {
+		return nil, fmt.Errorf("bad mode in opts to %s (expected string, got %s)", fn, mode.TypeName())
+	}
+
+	return []string{modeStr}, nil
+}
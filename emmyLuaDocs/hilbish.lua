@@ -13,6 +13,146 @@ function hilbish.aliases.add(alias, cmd) end
 --- as the higher level functions listed below this will handle it.
 function hilbish.runner.setMode(cb) end
 
+--- Returns the contents of the data file at `path`.
+--- It will throw if the file does not exist.
+--- 
+--- 
+function hilbish.data.read(path) end
+
+--- Returns a list of the names of the entries in the data directory at `path`.
+--- It will throw if the directory does not exist.
+function hilbish.data.readdir(path) end
+
+--- Returns what the cursor is in on the `line` of shell input, which is
+--- parsed as shell script (unfinished input included), so quotes,
+--- pipelines and other syntax are understood.
+--- The returned table has these keys:
+--- - `command` (string): The command of the simple command the cursor
+--- is in. Commands that run other commands, like `sudo` and `env`, and
+--- their options are skipped, so this is `git` for `sudo git che`.
+--- It is nil if the cursor isn't in a simple command.
+--- - `index` (number): The index of the word being typed in the command,
+--- where 0 is the command itself (so the word is `args[index + 1]`).
+--- It is nil if the word isn't an argument, like a redirection target.
+--- - `args` (table): The words of the command from `command` up to the
+--- cursor, with their quotes removed.
+--- - `word` (string): The word being typed, as it is typed.
+--- - `value` (string): The word with its quotes removed, or the name
+--- of the variable being typed after a `$`.
+--- - `quote` (string): The quote the cursor is in (`'` or `"`), or nil.
+--- - `variable` (boolean): Whether a variable name is being typed after `$`.
+--- - `redirect` (boolean): Whether the word is the target of a redirection.
+--- - `assignment` (string): The name of the variable whose value is
+--- being typed, for `NAME=value`, or nil.
+--- 
+--- 
+function hilbish.completion.context(line, pos) end
+
+--- Returns file matches based on the provided parameters.
+--- This function is meant to be used as a helper in a command completion handler.
+function hilbish.completion.files(query, ctx, fields) end
+
+--- This function contains the general completion handler for Hilbish. This function handles
+--- completion of everything, which includes calling other command handlers, binaries, and files.
+--- This function can be overriden to supply a custom handler. Note that alias resolution is required to be done in this function.
+--- 
+--- 
+function hilbish.completion.handler(line, pos) end
+
+--- Returns the names of environment variables that start with `query`.
+--- This is used to complete the name of a variable typed after a `$`.
+function hilbish.completion.vars(query, ctx, fields) end
+
+--- Appends the provided dir to the command path (`$PATH`)
+--- 
+--- 
+function hilbish.appendPath(dir) end
+
+--- Returns the current directory of the shell.
+function hilbish.cwd() end
+
+--- Replaces the currently running Hilbish instance with the supplied command.
+--- This can be used to do an in-place restart.
+function hilbish.exec(cmd) end
+
+--- Line highlighter handler.
+--- This is mainly for syntax highlighting, but in reality could set the input
+--- of the prompt to *display* anything. The callback is passed the current line
+--- and is expected to return a line that will be used as the input display.
+--- Note that to set a highlighter, one has to override this function.
+--- Lines can be routed to other runners with `hilbish.runner.route`, so
+--- to highlight each language its own way, `hilbish.runner.resolve(line)`
+--- returns the name of the runner the line goes to.
+--- 
+function hilbish.highlighter(line) end
+
+--- Prepends `dir` to $PATH.
+function hilbish.prependPath(dir) end
+
+--- Puts a job in the background. This acts the same as initially running a job.
+function hilbish.jobs:background() end
+
+--- Puts a job in the foreground. This will cause it to run like it was
+--- executed normally and wait for it to complete.
+function hilbish.jobs:foreground() end
+
+--- Sets/toggles the option of automatically flushing output.
+--- A call with no argument will toggle the value.
+--- @param auto boolean|nil
+function hilbish:autoFlush(auto) end
+
+--- Flush writes all buffered input to the sink.
+function hilbish:flush() end
+
+--- Reads a liine of input from the sink.
+--- @returns string
+function hilbish:read() end
+
+--- Reads all input from the sink.
+--- @returns string
+function hilbish:readAll() end
+
+--- Writes data to a sink.
+function hilbish:write(str) end
+
+--- Writes data to a sink with a newline at the end.
+function hilbish:writeln(str) end
+
+--- Starts running the job.
+function hilbish.jobs:start() end
+
+--- Stops the job from running.
+function hilbish.jobs:stop() end
+
+--- Starts a timer.
+function hilbish.timers:start() end
+
+--- Stops a timer.
+function hilbish.timers:stop() end
+
+--- Binds a key sequence to `action`, which is either a function to call
+--- or the name of an editor action. Keys in the sequence are separated
+--- by spaces, like `Ctrl-X Ctrl-E`. A key can be a character, or a name
+--- like `Enter`, `Tab`, `Escape`, `Backspace`, `Space`, `Up`, `Down`,
+--- `Left`, `Right`, `Home`, `End`, `Delete`, `Page-Up` or `Page-Down`,
+--- with a `Ctrl-` or `Alt-` modifier.
+--- The `mode` in `opts` is the input mode to bind the keys in: `emacs`,
+--- or `insert` or `normal` for Vim mode. If it isn't set, the keys are
+--- bound in both `emacs` and `insert` modes.
+--- The editor actions are `accept-hint`, `accept-line`, `alt-history-search`,
+--- `backward-char`, `backward-delete-char`, `backward-kill-line`,
+--- `backward-kill-word`, `backward-word`, `beginning-of-line`,
+--- `capitalize-word`, `clear-screen`, `complete`, `delete-char`,
+--- `digit-argument`, `downcase-word`, `edit-and-execute-command`,
+--- `edit-command-line`, `end-of-line`, `finder-cd`, `finder-files`,
+--- `finder-history`, `forward-char`, `forward-word`, `history-search`,
+--- `kill-line`, `kill-word`, `next-history`, `previous-history`, `redo`,
+--- `transpose-chars`, `transpose-words`, `undo`, `upcase-word`,
+--- `vi-insert-mode`, `vi-normal-mode`, `yank`, `yank-last-arg`,
+--- `yank-nth-arg` and `yank-pop`.
+--- 
+function hilbish.editor.bind(keys, action, opts) end
+
 --- Returns the current input line.
 function hilbish.editor.getLine() end
 
@@ -22,12 +162,28 @@ function hilbish.editor.getVimRegister(register) end
 --- Inserts text into the Hilbish command line.
 function hilbish.editor.insert(text) end
 
+--- Paste handler, which returns the text to insert for a paste.
+--- It is called with text that was pasted into the terminal, before it is
+--- inserted at the cursor. If it returns nothing, the paste is dropped.
+--- Pasted text is inserted as it is instead of being handled like typed keys,
+--- so tabs don't start completion, and a multi-line paste stays in the line
+--- until Enter is pressed. Line endings have already been made newlines and
+--- other control characters removed from the text. By default, it is
+--- returned unchanged. To change or refuse pastes, override this function.
+--- 
+--- 
+function hilbish.editor.paste(text) end
+
 --- Reads a keystroke from the user. This is in a format of something like Ctrl-L.
 function hilbish.editor.getChar() end
 
 --- Sets the vim register at `register` to hold the passed text.
 function hilbish.editor.setVimRegister(register, text) end
 
+--- Removes the binding for a key sequence, so the keys go back to
+--- what they do by default. `opts` takes the same `mode` as `bind`.
+function hilbish.editor.unbind(keys, opts) end
+
 --- Return binaries/executables based on the provided parameters.
 --- This function is meant to be used as a helper in a command completion handler.
 --- 
@@ -37,29 +193,25 @@ function hilbish.completion.bins(query, ctx, fields) end
 --- Calls a completer function. This is mainly used to call a command completer, which will have a `name`
 --- in the form of `command.name`, example: `command.git`.
 --- You can check the Completions doc or `doc completions` for info on the `completionGroups` return value.
-function hilbish.completion.call(name, query, ctx, fields) end
-
---- Returns file matches based on the provided parameters.
---- This function is meant to be used as a helper in a command completion handler.
-function hilbish.completion.files(query, ctx, fields) end
-
---- This function contains the general completion handler for Hilbish. This function handles
---- completion of everything, which includes calling other command handlers, binaries, and files.
---- This function can be overriden to supply a custom handler. Note that alias resolution is required to be done in this function.
+function hilbish.completion.call(name, query, ctx, fields, context) end
+
+--- Returns completions for the Lua code at the end of `ctx`.
+--- These are global names, or the keys of a value after a `.`, `:` or `["`,
+--- like `hilbish.jo` or `require 'fs'.re`. Keys from the `__index` tables of
+--- metatables are included, so methods of strings and objects are completed
+--- too. Documented functions are described by their signature.
+--- It returns nil if `ctx` doesn't end in Lua that can be completed, like
+--- a name that isn't a global, or if nothing completes it. Calls in `ctx` aren't run, but `require`
+--- of a module that is already loaded gives that module.
 --- 
 --- 
-function hilbish.completion.handler(line, pos) end
+function hilbish.completion.lua(ctx) end
 
 --- Sets an alias, with a name of `cmd` to another command.
 --- 
 --- 
 function hilbish.alias(cmd, orig) end
 
---- Appends the provided dir to the command path (`$PATH`)
---- 
---- 
-function hilbish.appendPath(dir) end
-
 --- Registers a completion handler for the specified scope.
 --- A `scope` is expected to be `command.<cmd>`,
 --- replacing <cmd> with the name of the command (for example `command.git`).
@@ -69,32 +221,26 @@ function hilbish.appendPath(dir) end
 --- 
 function hilbish.complete(scope, cb) end
 
---- Returns the current directory of the shell.
-function hilbish.cwd() end
-
---- Replaces the currently running Hilbish instance with the supplied command.
---- This can be used to do an in-place restart.
-function hilbish.exec(cmd) end
-
 --- Puts `fn` in a Goroutine.
 --- This can be used to run any function in another thread at the same time as other Lua code.
 --- **NOTE: THIS FUNCTION MAY CRASH HILBISH IF OUTSIDE VARIABLES ARE ACCESSED.**
 --- **This is a limitation of the Lua runtime.**
 function hilbish.goro(fn) end
 
---- Line highlighter handler.
---- This is mainly for syntax highlighting, but in reality could set the input
---- of the prompt to *display* anything. The callback is passed the current line
---- and is expected to return a line that will be used as the input display.
---- Note that to set a highlighter, one has to override this function.
---- 
-function hilbish.highlighter(line) end
-
 --- The command line hint handler. It gets called on every key insert to
 --- determine what text to use as an inline hint. It is passed the current
 --- line and cursor position. It is expected to return a string which is used
---- as the text for the hint. This is by default a shim. To set hints,
---- override this function with your custom handler.
+--- as the text for the hint. The hint can be accepted with the right arrow
+--- or End key (or the next word of it with Alt-F) when the cursor is at the
+--- end of the line.
+--- If a second value of false is returned, the hint is only shown and
+--- can't be accepted.
+--- By default, this suggests a command from history that starts with the line,
+--- unless the `autosuggest` opt is disabled. Commands run in the current
+--- directory and ones that succeeded are preferred. When the cursor is in a
+--- call to a Lua function with a [signature](#signature), like `hilbish.run(`,
+--- the signature is shown instead (unless the `signatureHints` opt is disabled).
+--- To set other hints, override this function with your custom handler.
 --- 
 --- 
 function hilbish.hinter(line, pos) end
@@ -109,13 +255,25 @@ function hilbish.inputMode(mode) end
 function hilbish.interval(cb, time) end
 
 --- Changes the text prompt when Hilbish asks for more input.
---- This will show up when text is incomplete, like a missing quote
+--- This will show up when text is incomplete, like a missing quote,
+--- before each line after the first one of the command.
 --- 
 --- 
 function hilbish.multiprompt(str) end
 
---- Prepends `dir` to $PATH.
-function hilbish.prependPath(dir) end
+--- Shows a fuzzy finder for the user to pick from `items`.
+--- This is the same full screen finder used by Ctrl-R, Ctrl-T and Alt-C.
+--- Typing filters and ranks the items, Up and Down (or Ctrl-P and Ctrl-N)
+--- move through them and Enter picks the highlighted item.
+--- Escape or Ctrl-C cancels, in which case nothing is returned.
+--- The following `opts` can be set:
+--- `prompt` (string): shown before the query, `> ` by default.
+--- `query` (string): text to start the query with.
+--- `multi` (boolean): if true, Tab marks items so more than one can be picked.
+--- `preview` (function): called with the highlighted item, and returns
+--- text to show in a pane beside the list.
+--- 
+function hilbish.pick(items, opts) end
 
 --- Changes the shell prompt to the provided string.
 --- There are a few verbs that can be used in the prompt text.
@@ -149,6 +307,16 @@ function hilbish.run(cmd, streams) end
 --- Read [about runner mode](../features/runner-mode) for more information.
 function hilbish.runnerMode(mode) end
 
+--- Adds the signature of a Lua function, or replaces the one it has.
+--- When the cursor is in a call to the function at the prompt, the
+--- signature is shown as a hint, along with the docs of the argument being
+--- typed. Signatures are also used to describe functions in Lua completion.
+--- The functions of the Hilbish API already have signatures, generated
+--- from their docs.
+--- 
+--- 
+function hilbish.signature(name, info) end
+
 --- Executed the `cb` function after a period of `time`.
 --- This creates a Timer that starts ticking immediately.
 function hilbish.timeout(cb, time) end
@@ -157,59 +325,28 @@ function hilbish.timeout(cb, time) end
 --- Will return the path of the binary, or a basename if it's a commander.
 function hilbish.which(name) end
 
---- Puts a job in the background. This acts the same as initially running a job.
-function hilbish.jobs:background() end
-
---- Puts a job in the foreground. This will cause it to run like it was
---- executed normally and wait for it to complete.
-function hilbish.jobs:foreground() end
-
 --- Evaluates `cmd` as Lua input. This is the same as using `dofile`
 --- or `load`, but is appropriated for the runner interface.
+--- Unfinished Lua (like a function without its `end`) returns
+--- `continue` as true, so Hilbish prompts for the rest of it.
+--- An expression (like `1 + 2`) is run for its values, which are shown
+--- with `hilbish.runner.echo` when the `luaEcho` opt is enabled.
 function hilbish.runner.lua(cmd) end
 
---- Sets/toggles the option of automatically flushing output.
---- A call with no argument will toggle the value.
---- @param auto boolean|nil
-function hilbish:autoFlush(auto) end
-
---- Flush writes all buffered input to the sink.
-function hilbish:flush() end
-
---- Reads a liine of input from the sink.
---- @returns string
-function hilbish:read() end
-
---- Reads all input from the sink.
---- @returns string
-function hilbish:readAll() end
-
---- Writes data to a sink.
-function hilbish:write(str) end
-
---- Writes data to a sink with a newline at the end.
-function hilbish:writeln(str) end
-
---- Starts running the job.
-function hilbish.jobs:start() end
-
---- Stops the job from running.
-function hilbish.jobs:stop() end
-
 --- Loads a module at the designated `path`.
 --- It will throw if any error occurs.
 function hilbish.module.load(path) end
 
+--- Returns whether `input` needs more lines before the builtin runner `mode`
+--- (`hybrid`, `hybridRev`, `lua` or `sh`) can run it, like an unfinished
+--- function or sh loop. In the hybrid modes, input is only incomplete if
+--- it's unfinished in both languages.
+function hilbish.runner.incomplete(input, mode) end
+
 --- Runs a command in Hilbish's shell script interpreter.
 --- This is the equivalent of using `source`.
 function hilbish.runner.sh(cmd) end
 
---- Starts a timer.
-function hilbish.timers:start() end
-
---- Stops a timer.
-function hilbish.timers:stop() end
-
 --- Removes an alias.
 function hilbish.aliases.delete(name) end
 
@@ -241,8 +378,12 @@ function hilbish.jobs.get(id) end
 --- Returns the last added job to the table.
 function hilbish.jobs.last() end
 
---- Adds a command to the history.
-function hilbish.history.add(cmd) end
+--- Adds a command to the history. Along with the command, the history
+--- saves when and where it was run and how it went. Any of these can be
+--- set in the `info` table, with the same fields as returned by `entry`.
+--- Those not set are taken from the last command that was run,
+--- along with the current directory, host and session.
+function hilbish.history.add(cmd, info) end
 
 --- Retrieves all history as a table.
 function hilbish.history.all() end
@@ -250,12 +391,57 @@ function hilbish.history.all() end
 --- Deletes all commands from the history.
 function hilbish.history.clear() end
 
+--- Removes the command at `index` from the history,
+--- without affecting the rest of it.
+function hilbish.history.delete(index) end
+
 --- Retrieves a command from the history based on the `index`.
 function hilbish.history.get(index) end
 
+--- Retrieves a command from the history, along with its info, based on the `index`.
+--- The returned table has these fields, which are nil if not known
+--- (for example in history from older versions of Hilbish):
+--- `cmd` (the command), `index`, `time` (unix time the command was run at),
+--- `duration` (how long it ran for in milliseconds), `cwd`, `exitCode`,
+--- `session` (an ID of the Hilbish instance it was run in) and `host`.
+function hilbish.history.entry(index) end
+
+--- Returns the history entries (as returned by `entry`) of commands that
+--- were run in the directory at `path`, or in directories inside of it,
+--- from oldest to newest. If `path` is in a git repository, the history
+--- of the whole repository is returned, like Alt-R shows.
+function hilbish.history.forDir(path) end
+
+--- Imports the history of another shell from the file at `path`.
+--- The commands are added to the history in the order they were run,
+--- skipping those which have already been imported.
+--- `format` is the shell the history is from, which can be `bash`, `zsh`
+--- or `fish`. If it isn't passed, it is guessed from the file.
+--- Returns how many commands were imported.
+--- 
+--- 
+function hilbish.history.import(path, format) end
+
+--- Returns the history entries (as returned by `entry`) that match `filter`,
+--- from oldest to newest. The filter can have these fields:
+--- `cwd` to only get commands run in a directory,
+--- `exitCode` for commands which exited with a specific code,
+--- `success` for commands which did (`true`) or did not (`false`) succeed,
+--- and `since` and `before` (unix times) for commands run in a time range.
+--- 
+--- 
+function hilbish.history.query(filter) end
+
 --- Returns the amount of commands in the history.
 function hilbish.history.size() end
 
+--- Returns a command from the history that starts with `line`, to suggest
+--- to the user. Commands that were run in the current directory are preferred,
+--- then ones that succeeded, then the most recent.
+--- This is what the default `hilbish.hinter` uses.
+--- Returns nil if there is no suggestion.
+function hilbish.history.suggest(line) end
+
 --- Creates a timer that runs based on the specified `time`.
 function hilbish.timers.create(type, time, callback) end
 
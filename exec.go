@@ -1,4 +1,4 @@
-package main
+package hilbish
 
 import (
 	"bytes"
@@ -9,6 +9,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"strings"
 	"syscall"
@@ -17,6 +18,7 @@ import (
 	"hilbish/util"
 
 	rt "github.com/arnodel/golua/runtime"
+	"github.com/arnodel/golua/token"
 	"mvdan.cc/sh/v3/shell"
 	//"github.com/yuin/gopher-lua/parse"
 	"mvdan.cc/sh/v3/interp"
@@ -24,9 +26,10 @@ import (
 	"mvdan.cc/sh/v3/expand"
 )
 
+var rxLuaName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 var errNotExec = errors.New("not executable")
 var errNotFound = errors.New("not found")
-var runnerMode rt.Value = rt.StringValue("hybrid")
 
 type streams struct {
 	stdout io.Writer
@@ -88,45 +91,88 @@ func isExecError(err error) (execError, bool) {
 	return execError{}, false
 }
 
-func runInput(input string, priv bool) {
-	running = true
-	cmdString := aliases.Resolve(input)
-	hooks.Emit("command.preexec", input, cmdString)
+func (sh *Shell) runInput(input string, priv bool) {
+	sh.running = true
+	sh.cmdStart = time.Now()
+	sh.cmdCwd, _ = os.Getwd()
+
+	if sh.interactive && sh.opt("histExpand") == rt.BoolValue(true) {
+		expanded, printOnly, err := sh.expandInput(input, &luaHistory{sh})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			sh.cmdFinish(1, input, true)
+			return
+		}
+
+		if expanded != input {
+			// show what is actually run
+			fmt.Println(expanded)
+		}
+		input = expanded
+
+		if printOnly {
+			sh.cmdFinish(0, input, priv)
+			return
+		}
+	}
+
+	cmdString := sh.aliases.Resolve(input)
+	sh.hooks.Emit("command.preexec", input, cmdString)
 
 	rerun:
 	var exitCode uint8
 	var err error
 	var cont bool
 	// save incase it changes while prompting (For some reason)
-	currentRunner := runnerMode
+	currentRunner := sh.runnerMode
+	// input routed to another runner is run by it, without its prefix
+	runnerName, runnerInput, routed := sh.resolveRunner(input)
+	if routed {
+		currentRunner, err = sh.getRunner(runnerName)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			sh.cmdFinish(124, input, priv)
+			return
+		}
+	}
+
 	if currentRunner.Type() == rt.StringType {
 		switch currentRunner.AsString() {
 			case "hybrid":
-				_, _, err = handleLua(input)
+				_, _, cont, err = sh.handleLua(input)
 				if err == nil {
-					cmdFinish(0, input, priv)
+					sh.cmdFinish(0, input, priv)
 					return
 				}
-				input, exitCode, cont, err = handleSh(input)
+				// unfinished Lua is prompted for more of, unless it's
+				// a whole sh command (like `CC=clang make`)
+				if !cont || !sh.incomplete(sh.aliases.Resolve(input), "hybrid") {
+					input, exitCode, cont, err = sh.handleSh(input)
+				}
 			case "hybridRev":
-				_, _, _, err = handleSh(input)
+				_, _, cont, err = sh.handleSh(input)
 				if err == nil {
-					cmdFinish(0, input, priv)
+					sh.cmdFinish(0, input, priv)
 					return
 				}
-				input, exitCode, err = handleLua(input)
+				if !cont || !sh.incomplete(sh.aliases.Resolve(input), "hybridRev") {
+					input, exitCode, cont, err = sh.handleLua(input)
+				}
 			case "lua":
-				input, exitCode, err = handleLua(input)
+				input, exitCode, cont, err = sh.handleLua(input)
 			case "sh":
-				input, exitCode, cont, err = handleSh(input)
+				input, exitCode, cont, err = sh.handleSh(input)
 		}
 	} else {
 		// can only be a string or function so
 		var runnerErr error
-		input, exitCode, cont, runnerErr, err = runLuaRunner(currentRunner, input)
+		prefix := strings.TrimSuffix(input, runnerInput)
+		input, exitCode, cont, runnerErr, err = sh.runLuaRunner(currentRunner, runnerInput)
+		// keep the prefix in the history and when prompting for more
+		input = prefix + input
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
-			cmdFinish(124, input, priv)
+			sh.cmdFinish(124, input, priv)
 			return
 		}
 		// yep, we only use `err` to check for lua eval error
@@ -136,7 +182,7 @@ func runInput(input string, priv bool) {
 	}
 
 	if cont {
-		input, err = reprompt(input)
+		input, err = sh.reprompt(input)
 		if err == nil {
 			goto rerun
 		} else if err == io.EOF {
@@ -146,30 +192,91 @@ func runInput(input string, priv bool) {
 
 	if err != nil {
 		if exErr, ok := isExecError(err); ok {
-			hooks.Emit("command." + exErr.typ, exErr.cmd)
+			sh.hooks.Emit("command." + exErr.typ, exErr.cmd)
 		} else {
 			fmt.Fprintln(os.Stderr, err)
 		}
 	}
-	cmdFinish(exitCode, input, priv)
+	sh.cmdFinish(exitCode, input, priv)
 }
 
-func reprompt(input string) (string, error) {
+func (sh *Shell) reprompt(input string) (string, error) {
 	for {
-		in, err := continuePrompt(strings.TrimSuffix(input, "\\"))
+		in, err := sh.continuePrompt(input)
 		if err != nil {
-			lr.SetPrompt(fmtPrompt(prompt))
+			sh.lr.SetPrompt(fmtPrompt(sh.prompt))
 			return input, err
 		}
+		input = in
 
 		if strings.HasSuffix(in, "\\") {
 			continue
 		}
+		sh.lr.SetPrompt(fmtPrompt(sh.prompt))
 		return in, nil
 	}
 }
 
-func runLuaRunner(runr rt.Value, userInput string) (input string, exitCode uint8, continued bool, runnerErr, err error) {
+// resolveRunner returns the name of the runner that input goes to with
+// hilbish.runner.resolve, the input to pass it and whether it was routed
+// there with hilbish.runner.route instead of going to the current runner.
+func (sh *Shell) resolveRunner(input string) (name, runnerInput string, routed bool) {
+	resolve := sh.hshMod.Get(rt.StringValue("runner")).AsTable().Get(rt.StringValue("resolve"))
+	if resolve.IsNil() {
+		return "", input, false
+	}
+
+	term := rt.NewTerminationWith(sh.runtime.MainThread().CurrentCont(), 3, false)
+	err := rt.Call(sh.runtime.MainThread(), resolve, []rt.Value{rt.StringValue(input)}, term)
+	if err != nil {
+		return "", input, false
+	}
+
+	name, _ = term.Get(0).TryString()
+	if !rt.Truth(term.Get(2)) {
+		return name, input, false
+	}
+	runnerInput, ok := term.Get(1).TryString()
+	if !ok {
+		runnerInput = input
+	}
+	return name, runnerInput, true
+}
+
+// expandInput does history expansion on input that goes to a runner for
+// shell commands (sh, hybrid or hybridRev). Input for other runners is
+// returned as it is, since `!` is no history event in Lua.
+func (sh *Shell) expandInput(input string, hist lineHistory) (string, bool, error) {
+	name, runnerInput, routed := sh.resolveRunner(input)
+	// a runner mode set with hilbish.runnerMode isn't known by name to resolve
+	if mode, ok := sh.runnerMode.TryString(); ok && !routed {
+		name = mode
+	}
+	switch name {
+	case "sh", "hybrid", "hybridRev":
+	default:
+		return input, false, nil
+	}
+
+	// a routed prefix (like `!`) isn't expanded, only what's after it
+	prefix := strings.TrimSuffix(input, runnerInput)
+	expanded, printOnly, err := expandHistory(runnerInput, hist)
+	return prefix + expanded, printOnly, err
+}
+
+// getRunner returns the run function of a runner added by name.
+func (sh *Shell) getRunner(name string) (rt.Value, error) {
+	get := sh.hshMod.Get(rt.StringValue("runner")).AsTable().Get(rt.StringValue("get"))
+	runner, err := rt.Call1(sh.runtime.MainThread(), get, rt.StringValue(name))
+	if err != nil {
+		return rt.NilValue, err
+	}
+
+	return rt.Index(sh.runtime.MainThread(), runner, rt.StringValue("run"))
+}
+
+func (sh *Shell) runLuaRunner(runr rt.Value, userInput string) (input string, exitCode uint8, continued bool, runnerErr, err error) {
+	l := sh.runtime
 	term := rt.NewTerminationWith(l.MainThread().CurrentCont(), 3, false)
 	err = rt.Call(l.MainThread(), runr, []rt.Value{rt.StringValue(userInput)}, term)
 	if err != nil {
@@ -204,11 +311,25 @@ func runLuaRunner(runr rt.Value, userInput string) (input string, exitCode uint8
 	return
 }
 
-func handleLua(input string) (string, uint8, error) {
-	cmdString := aliases.Resolve(input)
-	// First try to load input, essentially compiling to bytecode
+func (sh *Shell) handleLua(input string) (string, uint8, bool, error) {
+	l := sh.runtime
+	cmdString := sh.aliases.Resolve(input)
+	// First load it, essentially compiling to bytecode.
 	chunk, err := l.CompileAndLoadLuaChunk("", []byte(cmdString), rt.TableValue(l.GlobalEnv()))
-	if err != nil && noexecute {
+	// With luaEcho, input that isn't a statement may be an expression to
+	// show the values of. A function call is both, and runs the same way
+	// as an expression, so what it returns is shown too.
+	var isExpr bool
+	if sh.interactive && sh.opt("luaEcho") == rt.BoolValue(true) {
+		if exprChunk, ok := sh.luaExpr(cmdString); ok {
+			chunk, err, isExpr = exprChunk, nil, true
+		}
+	}
+	// If input is unfinished, prompt for the rest of it
+	if err != nil && sh.interactive && !sh.noexecute && sh.luaIncomplete(cmdString, err) {
+		return cmdString, 125, true, err
+	}
+	if err != nil && sh.noexecute {
 		fmt.Println(err)
 	/*	if lerr, ok := err.(*lua.ApiError); ok {
 			if perr, ok := lerr.Cause.(*parse.Error); ok {
@@ -216,37 +337,171 @@ func handleLua(input string) (string, uint8, error) {
 			}
 		}
 	*/
-		return cmdString, 125, err
+		return cmdString, 125, false, err
 	}
 	// And if there's no syntax errors and -n isnt provided, run
-	if !noexecute {
+	if !sh.noexecute {
 		if chunk != nil {
-			_, err = rt.Call1(l.MainThread(), rt.FunctionValue(chunk))
+			term := rt.NewTerminationWith(l.MainThread().CurrentCont(), 0, true)
+			err = rt.Call(l.MainThread(), rt.FunctionValue(chunk), nil, term)
+			if err == nil && isExpr {
+				sh.luaEcho(term.Etc())
+			}
 		}
 	}
 	if err == nil {
-		return cmdString, 0, nil
+		return cmdString, 0, false, nil
+	}
+
+	return cmdString, 125, false, err
+}
+
+// luaExpr compiles input as an expression that returns its values, like
+// the Lua REPL does. A lone name of a global that isn't set (like `ls`)
+// is more likely a command, so it isn't taken as one.
+func (sh *Shell) luaExpr(input string) (*rt.Closure, bool) {
+	input = strings.TrimSpace(input)
+	if rxLuaName.MatchString(input) {
+		// globals can come from the environment, so index with metamethods
+		val, err := rt.Index(sh.runtime.MainThread(), rt.TableValue(sh.runtime.GlobalEnv()), rt.StringValue(input))
+		if err != nil || val.IsNil() {
+			return nil, false
+		}
 	}
 
-	return cmdString, 125, err
+	chunk, err := sh.runtime.CompileAndLoadLuaChunk("", []byte("return " + input), rt.TableValue(sh.runtime.GlobalEnv()))
+	if err != nil {
+		return nil, false
+	}
+	return chunk, true
 }
 
-func handleSh(cmdString string) (input string, exitCode uint8, cont bool, runErr error) {
-	shRunner := hshMod.Get(rt.StringValue("runner")).AsTable().Get(rt.StringValue("sh"))
+// luaEcho shows the values of a Lua expression run interactively with
+// hilbish.runner.echo, and keeps the first one in the `_` global.
+func (sh *Shell) luaEcho(vals []rt.Value) {
+	if !sh.interactive || len(vals) == 0 || sh.opt("luaEcho") != rt.BoolValue(true) {
+		return
+	}
+	sh.runtime.GlobalEnv().Set(rt.StringValue("_"), vals[0])
+
+	runner, ok := sh.hshMod.Get(rt.StringValue("runner")).TryTable()
+	if !ok {
+		return
+	}
+	echo := runner.Get(rt.StringValue("echo"))
+	if echo.IsNil() {
+		return
+	}
+
+	term := rt.NewTerminationWith(sh.runtime.MainThread().CurrentCont(), 0, false)
+	if err := rt.Call(sh.runtime.MainThread(), echo, vals, term); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// inputIncomplete returns whether interactive input needs more lines before
+// the runner mode can run it, like an unfinished sh loop or Lua function.
+// Input routed to one of the builtin runners is checked like in that mode.
+// Input for runner functions is never known to be incomplete.
+func (sh *Shell) inputIncomplete(input string) bool {
+	mode, ok := sh.runnerMode.TryString()
+	if name, runnerInput, routed := sh.resolveRunner(input); routed {
+		mode, ok, input = name, true, runnerInput
+	}
+	if !ok {
+		return false
+	}
+
+	return sh.incomplete(sh.aliases.Resolve(input), mode)
+}
+
+// incomplete returns whether input needs more lines before the builtin
+// runner mode can run it. Hybrid modes can run input in either language,
+// so input is only incomplete if neither parses it as complete (an env
+// assignment like `CC=clang make` is unfinished Lua, but a whole sh command).
+func (sh *Shell) incomplete(input, mode string) bool {
+	shCheck := func() (complete, incomplete bool) {
+		// a backslash at the end continues the line
+		if strings.HasSuffix(input, "\\") {
+			return false, true
+		}
+		_, err := syntax.NewParser().Parse(strings.NewReader(input), "")
+		return err == nil, syntax.IsIncomplete(err)
+	}
+	luaCheck := func() (complete, incomplete bool) {
+		_, err := sh.runtime.CompileAndLoadLuaChunk("", []byte(input), rt.TableValue(sh.runtime.GlobalEnv()))
+		return err == nil, err != nil && sh.luaIncomplete(input, err)
+	}
+
+	var checks []func() (bool, bool)
+	switch mode {
+	case "sh":
+		checks = append(checks, shCheck)
+	case "lua":
+		checks = append(checks, luaCheck)
+	case "hybrid", "hybridRev":
+		checks = append(checks, luaCheck, shCheck)
+	}
+
+	var incomplete bool
+	for _, check := range checks {
+		complete, unfinished := check()
+		if complete {
+			return false
+		}
+		incomplete = incomplete || unfinished
+	}
+
+	return incomplete
+}
+
+// luaIncomplete returns whether Lua input failed to compile only because
+// it ended too early, like in the middle of a function or table.
+func (sh *Shell) luaIncomplete(input string, err error) bool {
+	// a lone expression (like `ls`) ends early for a statement, but
+	// isn't waiting for more
+	_, exprErr := sh.runtime.CompileAndLoadLuaChunk("", []byte("return " + input), rt.TableValue(sh.runtime.GlobalEnv()))
+	if exprErr == nil {
+		return false
+	}
+
+	// an unfinished if block is reported at the if instead of the end,
+	// so check whether ending it is enough.
+	for i := 0; i < 10 && isLuaIfError(err); i++ {
+		input += "\nend"
+		_, err = sh.runtime.CompileAndLoadLuaChunk("", []byte(input), rt.TableValue(sh.runtime.GlobalEnv()))
+		if err == nil {
+			return true
+		}
+	}
+
+	return rt.ErrorIsUnexpectedEOF(err)
+}
+
+func isLuaIfError(err error) bool {
+	snErr, ok := rt.AsSyntaxError(err)
+	return ok && snErr.Err.Got.Type == token.KwIf
+}
+
+func (sh *Shell) handleSh(cmdString string) (input string, exitCode uint8, cont bool, runErr error) {
+	shRunner := sh.hshMod.Get(rt.StringValue("runner")).AsTable().Get(rt.StringValue("sh"))
 	var err error
-	input, exitCode, cont, runErr, err = runLuaRunner(shRunner, cmdString)
+	input, exitCode, cont, runErr, err = sh.runLuaRunner(shRunner, cmdString)
 	if err != nil {
 		runErr = err
 	}
 	return
 }
 
-func execSh(cmdString string) (string, uint8, bool, error) {
-	_, _, err := execCommand(cmdString, nil)
+func (sh *Shell) execSh(cmdString string) (string, uint8, bool, error) {
+	_, _, err := sh.execCommand(cmdString, nil)
+	if exitErr, ok := err.(ExitError); ok {
+		return cmdString, exitErr.Code, false, nil
+	}
 	if err != nil {
 		// If input is incomplete, start multiline prompting
 		if syntax.IsIncomplete(err) {
-			if !interactive {
+			if !sh.interactive {
 				return cmdString, 126, false, err
 			}
 			return cmdString, 126, true, err
@@ -263,7 +518,7 @@ func execSh(cmdString string) (string, uint8, bool, error) {
 }
 
 // Run command in sh interpreter
-func execCommand(cmd string, strms *streams) (io.Writer, io.Writer, error) {
+func (sh *Shell) execCommand(cmd string, strms *streams) (io.Writer, io.Writer, error) {
 	file, err := syntax.NewParser().Parse(strings.NewReader(cmd), "")
 	if err != nil {
 		return nil, nil, err
@@ -301,10 +556,10 @@ func execCommand(cmd string, strms *streams) (io.Writer, io.Writer, error) {
 
 			stmtStr := buf.String()
 			buf.Reset()
-			jobs.add(stmtStr, []string{}, "")
+			sh.jobs.add(stmtStr, []string{}, "")
 		}
 
-		interp.ExecHandler(execHandle(bg))(runner)
+		interp.ExecHandler(sh.execHandle(bg))(runner)
 		err = runner.Run(context.TODO(), stmt)
 		if err != nil {
 			return strms.stdout, strms.stderr, err
@@ -314,11 +569,11 @@ func execCommand(cmd string, strms *streams) (io.Writer, io.Writer, error) {
 	return strms.stdout, strms.stderr, nil
 }
 
-func execHandle(bg bool) interp.ExecHandlerFunc {
+func (sh *Shell) execHandle(bg bool) interp.ExecHandlerFunc {
 	return func(ctx context.Context, args []string) error {
 		_, argstring := splitInput(strings.Join(args, " "))
 		// i dont really like this but it works
-		if aliases.All()[args[0]] != "" {
+		if sh.aliases.All()[args[0]] != "" {
 			for i, arg := range args {
 				if strings.Contains(arg, " ") {
 					args[i] = fmt.Sprintf("\"%s\"", arg)
@@ -327,7 +582,7 @@ func execHandle(bg bool) interp.ExecHandlerFunc {
 			_, argstring = splitInput(strings.Join(args, " "))
 
 			// If alias was found, use command alias
-			argstring = aliases.Resolve(argstring)
+			argstring = sh.aliases.Resolve(argstring)
 			var err error
 			args, err = shell.Fields(argstring, nil)
 			if err != nil {
@@ -342,10 +597,10 @@ func execHandle(bg bool) interp.ExecHandlerFunc {
 		}
 
 		hc := interp.HandlerCtx(ctx)
-		if cmd := cmds.Commands[args[0]]; cmd != nil {
-			stdin := newSinkInput(hc.Stdin)
-			stdout := newSinkOutput(hc.Stdout)
-			stderr := newSinkOutput(hc.Stderr)
+		if cmd := sh.cmds.Commands[args[0]]; cmd != nil {
+			stdin := newSinkInput(sh.runtime, hc.Stdin)
+			stdout := newSinkOutput(sh.runtime, hc.Stdout)
+			stderr := newSinkOutput(sh.runtime, hc.Stderr)
 
 			sinks := rt.NewTable()
 			sinks.Set(rt.StringValue("in"), rt.UserDataValue(stdin.ud))
@@ -353,7 +608,7 @@ func execHandle(bg bool) interp.ExecHandlerFunc {
 			sinks.Set(rt.StringValue("out"), rt.UserDataValue(stdout.ud))
 			sinks.Set(rt.StringValue("err"), rt.UserDataValue(stderr.ud))
 
-			luaexitcode, err := rt.Call1(l.MainThread(), rt.FunctionValue(cmd), rt.TableValue(luacmdArgs), rt.TableValue(sinks))
+			luaexitcode, err := rt.Call1(sh.runtime.MainThread(), rt.FunctionValue(cmd), rt.TableValue(luacmdArgs), rt.TableValue(sinks))
 			if err != nil {
 				fmt.Fprintln(os.Stderr, "Error in command:\n" + err.Error())
 				return interp.NewExitStatus(1)
@@ -365,9 +620,13 @@ func execHandle(bg bool) interp.ExecHandlerFunc {
 				exitcode = uint8(code)
 			} else if luaexitcode != rt.NilValue {
 				// deregister commander
-				delete(cmds.Commands, args[0])
+				delete(sh.cmds.Commands, args[0])
 				fmt.Fprintf(os.Stderr, "Commander did not return number for exit code. %s, you're fired.\n", args[0])
 			}
+			// stop running the rest of the input after exit
+			if sh.exited != nil {
+				return *sh.exited
+			}
 
 			return interp.NewExitStatus(exitcode)
 		}
@@ -431,7 +690,7 @@ func execHandle(bg bool) interp.ExecHandlerFunc {
 
 		var j *job
 		if bg {
-			j = jobs.getLatest()
+			j = sh.jobs.getLatest()
 			j.setHandle(&cmd)
 			err = j.start()
 		} else {
@@ -530,12 +789,9 @@ func splitInput(input string) ([]string, string) {
 	// end my suffering
 	// TODO: refactor this garbage
 	quoted := false
-	startlastcmd := false
-	lastcmddone := false
 	cmdArgs := []string{}
 	sb := &strings.Builder{}
 	cmdstr := &strings.Builder{}
-	lastcmd := "" //readline.GetHistory(readline.HistorySize() - 1)
 
 	for _, r := range input {
 		if r == '"' {
@@ -551,22 +807,6 @@ func splitInput(input string) ([]string, string) {
 			// if not quoted and there's a space then add to cmdargs
 			cmdArgs = append(cmdArgs, sb.String())
 			sb.Reset()
-		} else if !quoted && r == '^' && startlastcmd && !lastcmddone {
-			// if ^ is found, isnt in quotes and is
-			// the second occurence of the character and is
-			// the first time "^^" has been used
-			cmdstr.WriteString(lastcmd)
-			sb.WriteString(lastcmd)
-
-			startlastcmd = !startlastcmd
-			lastcmddone = !lastcmddone
-
-			continue
-		} else if !quoted && r == '^' && !lastcmddone {
-			// if ^ is found, isnt in quotes and is the
-			// first time of starting "^^"
-			startlastcmd = !startlastcmd
-			continue
 		} else {
 			sb.WriteRune(r)
 		}
@@ -579,10 +819,12 @@ func splitInput(input string) ([]string, string) {
 	return cmdArgs, cmdstr.String()
 }
 
-func cmdFinish(code uint8, cmdstr string, private bool) {
-	util.SetField(l, hshMod, "exitCode", rt.IntValue(int64(code)))
+func (sh *Shell) cmdFinish(code uint8, cmdstr string, private bool) {
+	sh.exitCode = code
+	sh.cmdDuration = time.Since(sh.cmdStart)
+	util.SetField(sh.runtime, sh.hshMod, "exitCode", rt.IntValue(int64(code)))
 	// using AsValue (to convert to lua type) on an interface which is an int
 	// results in it being unknown in lua .... ????
 	// so we allow the hook handler to take lua runtime Values
-	hooks.Emit("command.exit", rt.IntValue(int64(code)), cmdstr, private)
+	sh.hooks.Emit("command.exit", rt.IntValue(int64(code)), cmdstr, private)
 }
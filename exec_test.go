@@ -0,0 +1,280 @@
+package hilbish
+
+import (
+	"testing"
+
+	"hilbish/util"
+
+	rt "github.com/arnodel/golua/runtime"
+	"github.com/arnodel/golua/lib"
+)
+
+func TestLuaIncomplete(t *testing.T) {
+	sh := &Shell{runtime: rt.New(nil)}
+
+	type TestLuaIncompleteT struct {
+		Input string
+		Expected bool
+	}
+
+	tests := []TestLuaIncompleteT{
+		{Input: "function f()", Expected: true},
+		{Input: "function f()\n\treturn 1", Expected: true},
+		{Input: "if x then", Expected: true},
+		{Input: "if x then print(1)", Expected: true},
+		{Input: "if x then print(1) else", Expected: true},
+		{Input: "if x then\n\tif y then", Expected: true},
+		{Input: "for i = 1, 3 do", Expected: true},
+		{Input: "while true do", Expected: true},
+		{Input: "repeat", Expected: true},
+		{Input: "t = {", Expected: true},
+		{Input: "t = {\n\ta = 1,", Expected: true},
+		{Input: "print('a',", Expected: true},
+		{Input: "x =", Expected: true},
+		// a lone word is an expression, and more likely a command
+		{Input: "ls", Expected: false},
+		{Input: "hilbish", Expected: false},
+		{Input: "git status", Expected: false},
+		{Input: "print(1))", Expected: false},
+		{Input: "x = = 1", Expected: false},
+		{Input: "if x then end end", Expected: false},
+		{Input: "end", Expected: false},
+	}
+
+	for _, test := range tests {
+		_, err := sh.runtime.CompileAndLoadLuaChunk("", []byte(test.Input), rt.TableValue(sh.runtime.GlobalEnv()))
+		if err == nil {
+			t.Errorf("%q: expected it not to compile", test.Input)
+			continue
+		}
+		if incomplete := sh.luaIncomplete(test.Input, err); incomplete != test.Expected {
+			t.Errorf("%q: expected incomplete to be %v, got %v (%s)", test.Input, test.Expected, incomplete, err)
+		}
+	}
+}
+
+func TestExpandInput(t *testing.T) {
+	sh := &Shell{runtime: rt.New(nil)}
+	// routed input goes to the lua runner, like with hilbish.runner.route('>', 'lua')
+	chunk, err := sh.runtime.CompileAndLoadLuaChunk("", []byte(`
+		current = 'hybrid'
+		local routed = {['>print("done!x")'] = 'print("done!x")'}
+		return function(input)
+			if routed[input] then
+				return 'lua', routed[input], true
+			end
+			return current, input, false
+		end
+	`), rt.TableValue(sh.runtime.GlobalEnv()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolve, err := rt.Call1(sh.runtime.MainThread(), rt.FunctionValue(chunk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner := rt.NewTable()
+	runner.Set(rt.StringValue("resolve"), resolve)
+	sh.hshMod = rt.NewTable()
+	sh.hshMod.Set(rt.StringValue("runner"), rt.TableValue(runner))
+
+	hist := testHistory{"echo one"}
+
+	type TestExpandInputT struct {
+		Runner string
+		Input string
+		Expected string
+	}
+
+	tests := []TestExpandInputT{
+		{Runner: "hybrid", Input: "sudo !!", Expected: "sudo echo one"},
+		{Runner: "sh", Input: "!!", Expected: "echo one"},
+		{Runner: "hybridRev", Input: "!$", Expected: "one"},
+		// Lua input is left alone
+		{Runner: "lua", Input: `print("done!x")`, Expected: `print("done!x")`},
+		{Runner: "lua", Input: "print(1 ~= 2, not x)", Expected: "print(1 ~= 2, not x)"},
+		{Runner: "hybrid", Input: `>print("done!x")`, Expected: `>print("done!x")`},
+		{Runner: "custom", Input: "!!", Expected: "!!"},
+	}
+
+	for _, test := range tests {
+		sh.runtime.GlobalEnv().Set(rt.StringValue("current"), rt.StringValue(test.Runner))
+		expanded, _, err := sh.expandInput(test.Input, hist)
+		if err != nil {
+			t.Errorf("%q with the %s runner: unexpected error: %s", test.Input, test.Runner, err)
+			continue
+		}
+		if expanded != test.Expected {
+			t.Errorf("%q with the %s runner: expected %q, got %q", test.Input, test.Runner, test.Expected, expanded)
+		}
+	}
+}
+
+func TestHandleLuaEcho(t *testing.T) {
+	sh := &Shell{runtime: rt.New(nil), aliases: newAliases(), interactive: true}
+	opts := rt.NewTable()
+	sh.hshMod = rt.NewTable()
+	sh.hshMod.Set(rt.StringValue("opts"), rt.TableValue(opts))
+
+	chunk, err := sh.runtime.CompileAndLoadLuaChunk("", []byte("function f() return 'ret' end"), rt.TableValue(sh.runtime.GlobalEnv()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Call(sh.runtime.MainThread(), rt.FunctionValue(chunk), nil, rt.NewTerminationWith(nil, 0, false)); err != nil {
+		t.Fatal(err)
+	}
+
+	type TestHandleLuaEchoT struct {
+		Input string
+		Echo bool
+		Err bool
+		// the value of _ after running it, or nil if nothing is shown
+		Shown rt.Value
+	}
+
+	tests := []TestHandleLuaEchoT{
+		{Input: "1 + 2", Echo: true, Shown: rt.IntValue(3)},
+		{Input: "f()", Echo: true, Shown: rt.StringValue("ret")},
+		{Input: "f", Echo: true, Shown: sh.runtime.GlobalEnv().Get(rt.StringValue("f"))},
+		{Input: "x = 1", Echo: true},
+		// a name of a global that isn't set is more likely a command
+		{Input: "ls", Echo: true, Err: true},
+		// without luaEcho only statements are run
+		{Input: "1 + 2", Err: true},
+		{Input: "f", Err: true},
+		{Input: "f()"},
+		{Input: "x = 1"},
+	}
+
+	for _, test := range tests {
+		opts.Set(rt.StringValue("luaEcho"), rt.BoolValue(test.Echo))
+		sh.runtime.GlobalEnv().Set(rt.StringValue("_"), rt.NilValue)
+
+		_, _, _, err := sh.handleLua(test.Input)
+		if (err != nil) != test.Err {
+			t.Errorf("%q (luaEcho %v): expected error to be %v, got %v", test.Input, test.Echo, test.Err, err)
+			continue
+		}
+		if shown := sh.runtime.GlobalEnv().Get(rt.StringValue("_")); shown != test.Shown {
+			t.Errorf("%q (luaEcho %v): expected %v to be shown, got %v", test.Input, test.Echo, test.Shown, shown)
+		}
+	}
+}
+
+func TestInputIncomplete(t *testing.T) {
+	sh := &Shell{runtime: rt.New(nil), aliases: newAliases()}
+	sh.hshMod = rt.NewTable()
+	sh.hshMod.Set(rt.StringValue("runner"), rt.TableValue(rt.NewTable()))
+
+	type TestInputIncompleteT struct {
+		Runner string
+		Input string
+		Expected bool
+	}
+
+	tests := []TestInputIncompleteT{
+		// env assignments are unfinished Lua, but whole sh commands
+		{Runner: "hybrid", Input: "CC=clang make", Expected: false},
+		{Runner: "hybrid", Input: "x=1 env", Expected: false},
+		{Runner: "hybridRev", Input: "CC=clang make", Expected: false},
+		{Runner: "hybrid", Input: "function f()", Expected: true},
+		{Runner: "hybrid", Input: "for i in a b; do", Expected: true},
+		{Runner: "hybridRev", Input: "t = {\n\ta = 1,", Expected: false},
+		{Runner: "hybridRev", Input: "print('a',", Expected: true},
+		{Runner: "hybrid", Input: "echo a \\", Expected: true},
+		{Runner: "hybrid", Input: "ls", Expected: false},
+		{Runner: "lua", Input: "x=1 env", Expected: true},
+		{Runner: "sh", Input: "x=1 env", Expected: false},
+		{Runner: "sh", Input: "function f()", Expected: true},
+	}
+
+	for _, test := range tests {
+		sh.runnerMode = rt.StringValue(test.Runner)
+		if incomplete := sh.inputIncomplete(test.Input); incomplete != test.Expected {
+			t.Errorf("%q with the %s runner: expected incomplete to be %v, got %v", test.Input, test.Runner, test.Expected, incomplete)
+		}
+	}
+}
+
+// newRunnerShell returns a Shell with the runner interface from nature,
+// without the rest of it.
+func newRunnerShell(t *testing.T) *Shell {
+	sh := &Shell{runtime: rt.New(nil), aliases: newAliases()}
+	lib.LoadAll(sh.runtime)
+	sh.hshMod = rt.NewTable()
+	sh.hshMod.Set(rt.StringValue("runner"), rt.TableValue(sh.runnerModeLoader(sh.runtime)))
+	sh.runtime.GlobalEnv().Set(rt.StringValue("hilbish"), rt.TableValue(sh.hshMod))
+
+	if _, err := util.DoString(sh.runtime, "package.loaded.lunacolors = {}"); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.DoFile(sh.runtime, "nature/runner.lua"); err != nil {
+		t.Fatal(err)
+	}
+
+	return sh
+}
+
+func TestRunnerRoutes(t *testing.T) {
+	sh := newRunnerShell(t)
+	_, err := util.DoString(sh.runtime, `
+		hilbish.runner.add('fennel', function(input) return {input = input} end)
+		hilbish.runner.route('>', 'lua')
+		hilbish.runner.route('>>', 'sh')
+		hilbish.runner.route('^%(', 'fennel')
+		hilbish.runner.route('?', 'missing')
+		hilbish.runner.route('!', 'sh')
+		hilbish.runner.route('!', nil)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type TestRunnerRoutesT struct {
+		Input string
+		Runner string
+		RunnerInput string
+		Routed bool
+	}
+
+	tests := []TestRunnerRoutesT{
+		{Input: ">print(1)", Runner: "lua", RunnerInput: "print(1)", Routed: true},
+		// routes are checked in the order they were added
+		{Input: ">>ls", Runner: "lua", RunnerInput: ">ls", Routed: true},
+		// patterns keep the input as it is
+		{Input: "(+ 1 2)", Runner: "fennel", RunnerInput: "(+ 1 2)", Routed: true},
+		{Input: "ls (", Runner: "hybrid", RunnerInput: "ls (", Routed: false},
+		// input that isn't routed goes to the current runner
+		{Input: "ls", Runner: "hybrid", RunnerInput: "ls", Routed: false},
+		// removed routes aren't used
+		{Input: "!ls", Runner: "hybrid", RunnerInput: "!ls", Routed: false},
+		// a route to a runner that doesn't exist still resolves
+		{Input: "?ls", Runner: "missing", RunnerInput: "ls", Routed: true},
+	}
+
+	for _, test := range tests {
+		name, runnerInput, routed := sh.resolveRunner(test.Input)
+		if name != test.Runner || runnerInput != test.RunnerInput || routed != test.Routed {
+			t.Errorf("%q: expected %q, %q, %v, got %q, %q, %v", test.Input, test.Runner, test.RunnerInput, test.Routed, name, runnerInput, routed)
+		}
+	}
+
+	// but running it fails
+	if _, err := sh.getRunner("missing"); err == nil {
+		t.Error("expected an error getting a runner that doesn't exist")
+	}
+	if _, err := sh.getRunner("fennel"); err != nil {
+		t.Errorf("unexpected error getting a runner: %s", err)
+	}
+
+	// the current runner is the fallback
+	if _, err := util.DoString(sh.runtime, "hilbish.runner.setCurrent 'lua'"); err != nil {
+		t.Fatal(err)
+	}
+	if name, _, routed := sh.resolveRunner("ls"); name != "lua" || routed {
+		t.Errorf("expected unrouted input to go to the lua runner, got %q (routed: %v)", name, routed)
+	}
+	if _, err := util.DoString(sh.runtime, "hilbish.runner.setCurrent 'missing'"); err == nil {
+		t.Error("expected an error setting a runner that doesn't exist as the current one")
+	}
+}
@@ -0,0 +1,231 @@
+package hilbish
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"hilbish/util"
+
+	rt "github.com/arnodel/golua/runtime"
+	"github.com/maxlandon/readline"
+)
+
+// finderMaxFiles is how many paths the file and directory finders look through at most,
+// so opening them in a huge directory tree doesn't take forever.
+const finderMaxFiles = 100000
+
+var errFinderFull = errors.New("too many files")
+
+// bindFinders adds the fuzzy finder widgets as editor actions, and binds
+// them to their keys: Ctrl-R for history, Ctrl-T for files and Alt-C for
+// directories. With the finder opt off, the keys do what they normally do
+// (search history, transpose characters and capitalize a word).
+func (lr *lineReader) bindFinders() {
+	lr.rl.AddAction("finder-history", lr.historyFinder)
+	lr.rl.AddAction("finder-files", lr.fileFinder)
+	lr.rl.AddAction("finder-cd", lr.dirFinder)
+
+	for _, mode := range []string{readline.KeymapEmacs, readline.KeymapInsert} {
+		lr.rl.BindAction(mode, "Ctrl-R", "finder-history")
+		lr.rl.BindAction(mode, "Ctrl-T", "finder-files")
+		lr.rl.BindAction(mode, "Alt-c", "finder-cd")
+	}
+}
+
+// finderEnabled returns whether the finder widgets are used,
+// going by the finder opt.
+func (lr *lineReader) finderEnabled() bool {
+	return lr.sh.opt("finder") != rt.BoolValue(false)
+}
+
+// forwardKey makes readline handle a key the finders were bound to
+// like they weren't.
+func forwardKey(line []rune, pos int) *readline.EventReturn {
+	return &readline.EventReturn{
+		ForwardKey: true,
+		NewLine: line,
+		NewPos: pos,
+	}
+}
+
+// historyFinder picks commands from history, newest first,
+// to replace the line with. Commands picked together are joined by `;`.
+func (lr *lineReader) historyFinder(key string, line []rune, pos int) *readline.EventReturn {
+	if !lr.finderEnabled() {
+		return forwardKey(line, pos)
+	}
+
+	entries := lr.fileHist.entries()
+	cmds := []string{}
+	newest := []historyEntry{}
+	seen := map[string]bool{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		cmd := entries[i].Cmd
+		if cmd == "" || seen[cmd] {
+			continue
+		}
+		seen[cmd] = true
+		cmds = append(cmds, cmd)
+		newest = append(newest, entries[i])
+	}
+
+	p := newPicker(cmds)
+	p.prompt = "history> "
+	p.query = append([]rune{}, line...)
+	p.multi = true
+	p.preview = func(idx int) string {
+		return historyPreview(newest[idx])
+	}
+
+	picked, ok := p.pick()
+	if !ok {
+		return &readline.EventReturn{NewLine: line, NewPos: pos}
+	}
+
+	var sb strings.Builder
+	for i, idx := range picked {
+		if i != 0 {
+			sb.WriteString("; ")
+		}
+		sb.WriteString(cmds[idx])
+	}
+	newLine := []rune(sb.String())
+
+	return &readline.EventReturn{
+		NewLine: newLine,
+		NewPos: len(newLine),
+	}
+}
+
+// historyPreview describes a history entry for the preview pane.
+func historyPreview(e historyEntry) string {
+	var sb strings.Builder
+	sb.WriteString(e.Cmd)
+	sb.WriteString("\n\n")
+
+	if e.Time != 0 {
+		fmt.Fprintf(&sb, "Ran at:    %s\n", time.Unix(e.Time, 0).Format("2006-01-02 15:04:05"))
+	}
+	if e.Duration != 0 {
+		fmt.Fprintf(&sb, "Took:      %s\n", time.Duration(e.Duration) * time.Millisecond)
+	}
+	if e.Cwd != "" {
+		fmt.Fprintf(&sb, "Directory: %s\n", util.AbbrevHome(e.Cwd))
+	}
+	if e.ExitCode != nil {
+		fmt.Fprintf(&sb, "Exit code: %d\n", *e.ExitCode)
+	}
+	if e.Host != "" {
+		fmt.Fprintf(&sb, "Host:      %s\n", e.Host)
+	}
+
+	return sb.String()
+}
+
+// fileFinder picks files to insert at the cursor. If the word before
+// the cursor is a directory, files are looked for in it, otherwise in
+// the current directory, and the word is used as the query.
+func (lr *lineReader) fileFinder(key string, line []rune, pos int) *readline.EventReturn {
+	if !lr.finderEnabled() {
+		return forwardKey(line, pos)
+	}
+
+	start := pos
+	for start > 0 && !unicode.IsSpace(line[start - 1]) {
+		start--
+	}
+	word := string(line[start:pos])
+
+	root, prefix, query := ".", "", word
+	if word != "" {
+		if info, err := os.Stat(util.ExpandHome(word)); err == nil && info.IsDir() {
+			root = util.ExpandHome(word)
+			prefix = strings.TrimSuffix(word, "/") + "/"
+			query = ""
+		}
+	}
+
+	p := newPicker(walkFiles(root, false))
+	p.prompt = "files> "
+	p.query = []rune(query)
+	p.multi = true
+
+	picked, ok := p.pick()
+	if !ok {
+		return &readline.EventReturn{NewLine: line, NewPos: pos}
+	}
+
+	var sb strings.Builder
+	for _, idx := range picked {
+		sb.WriteString(escapeFilename(prefix + p.items[idx]))
+		sb.WriteString(" ")
+	}
+	inserted := []rune(sb.String())
+
+	newLine := append(append(append([]rune{}, line[:start]...), inserted...), line[pos:]...)
+	return &readline.EventReturn{
+		NewLine: newLine,
+		NewPos: start + len(inserted),
+	}
+}
+
+// dirFinder picks a directory under the current one and changes to it,
+// by running `cd` with it.
+func (lr *lineReader) dirFinder(key string, line []rune, pos int) *readline.EventReturn {
+	if !lr.finderEnabled() {
+		return forwardKey(line, pos)
+	}
+
+	p := newPicker(walkFiles(".", true))
+	p.prompt = "cd> "
+
+	picked, ok := p.pick()
+	if !ok {
+		return &readline.EventReturn{NewLine: line, NewPos: pos}
+	}
+
+	cmd := []rune("cd " + escapeFilename(p.items[picked[0]]))
+	return &readline.EventReturn{
+		CloseReadline: true,
+		NewLine: cmd,
+		NewPos: len(cmd),
+	}
+}
+
+// walkFiles returns the paths under root, relative to it.
+// Hidden files and directories are skipped. Directories end with
+// a slash, and if dirsOnly is set they are the only paths returned.
+func walkFiles(root string, dirsOnly bool) []string {
+	paths := []string{}
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == root {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if len(paths) == finderMaxFiles {
+			return errFinderFull
+		}
+
+		rel, _ := filepath.Rel(root, path)
+		if d.IsDir() {
+			paths = append(paths, rel + string(filepath.Separator))
+		} else if !dirsOnly {
+			paths = append(paths, rel)
+		}
+
+		return nil
+	})
+
+	return paths
+}
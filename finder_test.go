@@ -0,0 +1,77 @@
+package hilbish
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	rt "github.com/arnodel/golua/runtime"
+)
+
+func TestWalkFiles(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "docs"), 0755)
+	for _, path := range []string{"a.go", "src/b.go", "src/lib/c.go", ".git/config", "src/.hidden"} {
+		path = filepath.Join(root, path)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, nil, 0644)
+	}
+
+	sep := string(filepath.Separator)
+	type TestWalkFilesT struct {
+		DirsOnly bool
+		Expected []string
+	}
+
+	tests := []TestWalkFilesT{
+		{Expected: []string{"a.go", "docs" + sep, "src" + sep, filepath.Join("src", "b.go"), filepath.Join("src", "lib") + sep, filepath.Join("src", "lib", "c.go")}},
+		{DirsOnly: true, Expected: []string{"docs" + sep, "src" + sep, filepath.Join("src", "lib") + sep}},
+	}
+
+	for _, test := range tests {
+		paths := walkFiles(root, test.DirsOnly)
+		sort.Strings(paths)
+		if !reflect.DeepEqual(paths, test.Expected) {
+			t.Errorf("dirs only %v: expected %q, got %q", test.DirsOnly, test.Expected, paths)
+		}
+	}
+}
+
+func TestFindersDisabled(t *testing.T) {
+	opts := rt.NewTable()
+	opts.Set(rt.StringValue("finder"), rt.BoolValue(false))
+	sh := &Shell{hshMod: rt.NewTable()}
+	sh.hshMod.Set(rt.StringValue("opts"), rt.TableValue(opts))
+	lr := &lineReader{sh: sh}
+
+	// the keys do what they normally would instead, like Ctrl-T transposing
+	finders := map[string]func(string, []rune, int) bool{
+		"finder-history": func(key string, line []rune, pos int) bool { return lr.historyFinder(key, line, pos).ForwardKey },
+		"finder-files": func(key string, line []rune, pos int) bool { return lr.fileFinder(key, line, pos).ForwardKey },
+		"finder-cd": func(key string, line []rune, pos int) bool { return lr.dirFinder(key, line, pos).ForwardKey },
+	}
+	for name, finder := range finders {
+		if !finder("", []rune("ab"), 1) {
+			t.Errorf("%s: expected the key to be forwarded", name)
+		}
+	}
+}
+
+func TestHistoryPreview(t *testing.T) {
+	code := 2
+	entry := historyEntry{Cmd: "make", Time: 1700000000, Duration: 1500, Cwd: "/src", ExitCode: &code, Host: "box"}
+
+	preview := historyPreview(entry)
+	for _, expected := range []string{"make\n\n", "Took:      1.5s\n", "Directory: /src\n", "Exit code: 2\n", "Host:      box\n"} {
+		if !strings.Contains(preview, expected) {
+			t.Errorf("expected %q in the preview, got %q", expected, preview)
+		}
+	}
+
+	if preview := historyPreview(historyEntry{Cmd: "ls"}); preview != "ls\n\n" {
+		t.Errorf("expected only the command for an entry without info, got %q", preview)
+	}
+}
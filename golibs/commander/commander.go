@@ -43,13 +43,13 @@ import (
 type Commander struct{
 	Events *bait.Bait
 	Loader packagelib.Loader
-	Commands map[string]*rt.Closure
+	Commands map[string]rt.Callable
 }
 
 func New(rtm *rt.Runtime) *Commander {
 	c := &Commander{
 		Events: bait.New(rtm),
-		Commands: make(map[string]*rt.Closure),
+		Commands: make(map[string]rt.Callable),
 	}
 	c.Loader = packagelib.Loader{
 		Load: c.loaderFunc,
@@ -0,0 +1,292 @@
+package hilbish
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// lineHistory is the part of a history needed for history expansion.
+type lineHistory interface {
+	GetLine(idx int) (string, error)
+	Len() int
+}
+
+// expandHistory does bash style history expansion on input.
+// It supports the `!!`, `!n`, `!-n` and `!prefix` event designators,
+// word designators after a colon (`:0`, `:n`, `:^`, `:$`, `:*`, `:x-y`)
+// along with the `!$`, `!^` and `!*` shorthands, the `:p` modifier
+// and `^old^new` quick substitution.
+// It returns the expanded input and whether it should only be printed.
+func expandHistory(input string, hist lineHistory) (string, bool, error) {
+	if strings.HasPrefix(input, "^") {
+		return quickSubstitute(input, hist)
+	}
+
+	if !strings.Contains(input, "!") {
+		return input, false, nil
+	}
+
+	var sb strings.Builder
+	var printOnly bool
+	var quote rune
+	runes := []rune(input)
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\' && quote != '\'' && i + 1 < len(runes):
+			sb.WriteRune(r)
+			sb.WriteRune(runes[i + 1])
+			i++
+			continue
+		case r == '\'' && quote != '"':
+			if quote == '\'' {
+				quote = 0
+			} else {
+				quote = r
+			}
+		case r == '"' && quote != '\'':
+			if quote == '"' {
+				quote = 0
+			} else {
+				quote = r
+			}
+		}
+
+		if r != '!' || quote == '\'' || i + 1 == len(runes) || isHistoryLiteral(runes[i + 1]) {
+			sb.WriteRune(r)
+			continue
+		}
+
+		expansion, end, p, err := expandEvent(runes, i + 1, hist)
+		if err != nil {
+			return "", false, err
+		}
+		sb.WriteString(expansion)
+		printOnly = printOnly || p
+		i = end - 1
+	}
+
+	return sb.String(), printOnly, nil
+}
+
+// isHistoryLiteral reports whether a ! followed by r is left alone.
+func isHistoryLiteral(r rune) bool {
+	return unicode.IsSpace(r) || r == '=' || r == '(' || r == '"'
+}
+
+// expandEvent expands the history event starting at runes[i], right after
+// a !, and returns the expansion and the index after the event.
+func expandEvent(runes []rune, i int, hist lineHistory) (string, int, bool, error) {
+	var cmd string
+	var words string // word designator
+	var found bool
+	start := i
+
+	switch r := runes[i]; {
+	case r == '!':
+		cmd, found = historyLine(hist, hist.Len() - 1)
+		i++
+	case r == '$' || r == '^' || r == '*':
+		// shorthand for the words of the last command
+		cmd, found = historyLine(hist, hist.Len() - 1)
+		words = string(r)
+		i++
+	case r == '-' || unicode.IsDigit(r):
+		j := i + 1
+		for j < len(runes) && unicode.IsDigit(runes[j]) {
+			j++
+		}
+		n, err := strconv.Atoi(string(runes[i:j]))
+		if err != nil {
+			return "", 0, false, fmt.Errorf("!%s: event not found", string(runes[i:j]))
+		}
+
+		idx := n - 1
+		if n < 0 {
+			idx = hist.Len() + n
+		}
+		cmd, found = historyLine(hist, idx)
+		i = j
+	default:
+		j := i
+		for j < len(runes) && !unicode.IsSpace(runes[j]) && runes[j] != ':' && runes[j] != '"' && runes[j] != '\'' {
+			j++
+		}
+		prefix := string(runes[i:j])
+		for idx := hist.Len() - 1; idx >= 0; idx-- {
+			if line, ok := historyLine(hist, idx); ok && strings.HasPrefix(line, prefix) {
+				cmd, found = line, true
+				break
+			}
+		}
+		i = j
+	}
+
+	if !found {
+		return "", 0, false, fmt.Errorf("!%s: event not found", string(runes[start:i]))
+	}
+
+	var printOnly bool
+	for i < len(runes) && runes[i] == ':' && i + 1 < len(runes) {
+		j := i + 1
+		if runes[j] == 'p' {
+			printOnly = true
+			i = j + 1
+			continue
+		}
+		if words != "" {
+			break
+		}
+
+		for j < len(runes) && strings.ContainsRune("0123456789^$*-", runes[j]) {
+			j++
+		}
+		if j == i + 1 {
+			break
+		}
+		words = string(runes[i + 1:j])
+		i = j
+	}
+
+	if words != "" {
+		selected, err := selectWords(historyWords(cmd), words)
+		if err != nil {
+			return "", 0, false, err
+		}
+		cmd = selected
+	}
+
+	return cmd, i, printOnly, nil
+}
+
+func historyLine(hist lineHistory, idx int) (string, bool) {
+	if idx < 0 || idx >= hist.Len() {
+		return "", false
+	}
+
+	line, err := hist.GetLine(idx)
+	return line, err == nil
+}
+
+// selectWords returns the words of a command picked by a word designator.
+func selectWords(words []string, designator string) (string, error) {
+	last := len(words) - 1
+	bad := fmt.Errorf(":%s: bad word specifier", designator)
+
+	index := func(s string) (int, error) {
+		switch s {
+		case "^":
+			return 1, nil
+		case "$":
+			return last, nil
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, bad
+		}
+		return n, nil
+	}
+
+	from, to := 0, 0
+	switch {
+	case designator == "*":
+		if last < 1 {
+			return "", nil
+		}
+		from, to = 1, last
+	case strings.HasSuffix(designator, "*"):
+		n, err := index(strings.TrimSuffix(designator, "*"))
+		if err != nil {
+			return "", err
+		}
+		from, to = n, last
+	case strings.Contains(designator[1:], "-"):
+		sep := strings.Index(designator[1:], "-") + 1
+		var err error
+		if from, err = index(designator[:sep]); err != nil {
+			return "", err
+		}
+		if to, err = index(designator[sep + 1:]); err != nil {
+			return "", err
+		}
+	default:
+		n, err := index(designator)
+		if err != nil {
+			return "", err
+		}
+		from, to = n, n
+	}
+
+	if from < 0 || to > last || from > to {
+		return "", bad
+	}
+
+	return strings.Join(words[from:to + 1], " "), nil
+}
+
+// historyWords splits a command into words, like a shell would,
+// but keeps the quotes in them.
+func historyWords(cmd string) []string {
+	words := []string{}
+	var sb strings.Builder
+	var quote rune
+	escaped := false
+
+	for _, r := range cmd {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case unicode.IsSpace(r):
+			if sb.Len() != 0 {
+				words = append(words, sb.String())
+				sb.Reset()
+			}
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	if sb.Len() != 0 {
+		words = append(words, sb.String())
+	}
+
+	return words
+}
+
+// quickSubstitute handles ^old^new^, which runs the last command with
+// the first occurrence of old replaced with new.
+func quickSubstitute(input string, hist lineHistory) (string, bool, error) {
+	parts := strings.SplitN(input[1:], "^", 3)
+	if len(parts) < 2 {
+		// not a substitution, just a line starting with ^
+		return input, false, nil
+	}
+	old, new := parts[0], parts[1]
+
+	var rest string
+	if len(parts) == 3 {
+		rest = parts[2]
+	}
+	printOnly := strings.HasPrefix(rest, ":p")
+	rest = strings.TrimPrefix(rest, ":p")
+
+	last, ok := historyLine(hist, hist.Len() - 1)
+	if !ok {
+		return "", false, fmt.Errorf("^%s: event not found", old)
+	}
+	if old == "" || !strings.Contains(last, old) {
+		return "", false, fmt.Errorf("^%s^%s: substitution failed", old, new)
+	}
+
+	return strings.Replace(last, old, new, 1) + rest, printOnly, nil
+}
@@ -0,0 +1,158 @@
+package hilbish
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testHistory []string
+
+func (h testHistory) GetLine(idx int) (string, error) {
+	return h[idx], nil
+}
+
+func (h testHistory) Len() int {
+	return len(h)
+}
+
+func TestExpandHistory(t *testing.T) {
+	hist := testHistory{
+		"cd /tmp",
+		"git commit -m 'a message'",
+		"echo one two three",
+	}
+
+	type TestExpandHistoryT struct {
+		Input string
+		Expected string
+		PrintOnly bool
+		Err bool
+	}
+
+	tests := []TestExpandHistoryT{
+		{Input: "ls", Expected: "ls"},
+		{Input: "!!", Expected: "echo one two three"},
+		{Input: "sudo !!", Expected: "sudo echo one two three"},
+		{Input: "!1", Expected: "cd /tmp"},
+		{Input: "!-2", Expected: "git commit -m 'a message'"},
+		{Input: "!git", Expected: "git commit -m 'a message'"},
+		{Input: "!cd:1", Expected: "/tmp"},
+		{Input: "!$", Expected: "three"},
+		{Input: "!^", Expected: "one"},
+		{Input: "!*", Expected: "one two three"},
+		{Input: "!!:0", Expected: "echo"},
+		{Input: "!!:1-2", Expected: "one two"},
+		{Input: "!!:2*", Expected: "two three"},
+		{Input: "!git:$", Expected: "'a message'"},
+		{Input: "!!:p", Expected: "echo one two three", PrintOnly: true},
+		{Input: "^one^uno", Expected: "echo uno two three"},
+		{Input: "^one^uno^ four", Expected: "echo uno two three four"},
+		{Input: "^one^uno^:p", Expected: "echo uno two three", PrintOnly: true},
+		// a ! that can't start an event is left alone
+		{Input: "echo hi!", Expected: "echo hi!"},
+		{Input: "echo ! x", Expected: "echo ! x"},
+		{Input: "[ != ]", Expected: "[ != ]"},
+		{Input: "echo '!!'", Expected: "echo '!!'"},
+		{Input: `echo \!!`, Expected: `echo \!!`},
+		{Input: `echo "!!"`, Expected: `echo "echo one two three"`},
+		{Input: "!nope", Err: true},
+		{Input: "!9", Err: true},
+		{Input: "!!:7", Err: true},
+		{Input: "^nope^x", Err: true},
+	}
+
+	for _, test := range tests {
+		expanded, printOnly, err := expandHistory(test.Input, hist)
+		if test.Err {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %q", test.Input, expanded)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", test.Input, err)
+			continue
+		}
+		if expanded != test.Expected || printOnly != test.PrintOnly {
+			t.Errorf("%q: expected %q (print only: %v), got %q (print only: %v)", test.Input, test.Expected, test.PrintOnly, expanded, printOnly)
+		}
+	}
+}
+
+func TestExpandHistoryEmpty(t *testing.T) {
+	if _, _, err := expandHistory("!!", testHistory{}); err == nil {
+		t.Error("expected an error expanding !! with no history")
+	}
+}
+
+func TestSelectWords(t *testing.T) {
+	words := []string{"cp", "a", "b", "c"}
+
+	type TestSelectWordsT struct {
+		Designator string
+		Expected string
+		Err bool
+	}
+
+	tests := []TestSelectWordsT{
+		{Designator: "0", Expected: "cp"},
+		{Designator: "2", Expected: "b"},
+		{Designator: "^", Expected: "a"},
+		{Designator: "$", Expected: "c"},
+		{Designator: "*", Expected: "a b c"},
+		{Designator: "1-2", Expected: "a b"},
+		{Designator: "0-$", Expected: "cp a b c"},
+		{Designator: "^-$", Expected: "a b c"},
+		{Designator: "2*", Expected: "b c"},
+		{Designator: "4", Err: true},
+		{Designator: "3-1", Err: true},
+		{Designator: "x", Err: true},
+	}
+
+	for _, test := range tests {
+		selected, err := selectWords(words, test.Designator)
+		if test.Err {
+			if err == nil {
+				t.Errorf(":%s: expected an error, got %q", test.Designator, selected)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf(":%s: unexpected error: %s", test.Designator, err)
+			continue
+		}
+		if selected != test.Expected {
+			t.Errorf(":%s: expected %q, got %q", test.Designator, test.Expected, selected)
+		}
+	}
+
+	// * of a command without arguments is empty
+	if selected, err := selectWords([]string{"ls"}, "*"); err != nil || selected != "" {
+		t.Errorf(":*: expected nothing for a lone command, got %q (%v)", selected, err)
+	}
+}
+
+func TestHistoryWords(t *testing.T) {
+	type TestHistoryWordsT struct {
+		Cmd string
+		Expected []string
+	}
+
+	tests := []TestHistoryWordsT{
+		{Cmd: "", Expected: []string{}},
+		{Cmd: "ls", Expected: []string{"ls"}},
+		{Cmd: "  ls  -la\tfoo ", Expected: []string{"ls", "-la", "foo"}},
+		{Cmd: `echo "a b" 'c d'`, Expected: []string{"echo", `"a b"`, "'c d'"}},
+		{Cmd: `echo a\ b`, Expected: []string{"echo", `a\ b`}},
+		{Cmd: `echo "it's"`, Expected: []string{"echo", `"it's"`}},
+		{Cmd: `echo pre"mid dle"post`, Expected: []string{"echo", `pre"mid dle"post`}},
+		{Cmd: `echo 'unclosed quote`, Expected: []string{"echo", "'unclosed quote"}},
+	}
+
+	for _, test := range tests {
+		words := historyWords(test.Cmd)
+		if !reflect.DeepEqual(words, test.Expected) {
+			t.Errorf("%q: expected %q, got %q", test.Cmd, test.Expected, words)
+		}
+	}
+}
@@ -1,20 +1,30 @@
-package main
+package hilbish
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 
 	rt "github.com/arnodel/golua/runtime"
 )
 
-type luaHistory struct {}
+type luaHistory struct {
+	sh *Shell
+}
 
 func (h *luaHistory) Write(line string) (int, error) {
-	histWrite := hshMod.Get(rt.StringValue("history")).AsTable().Get(rt.StringValue("add"))
-	ln, err := rt.Call1(l.MainThread(), histWrite, rt.StringValue(line))
+	histWrite := h.sh.hshMod.Get(rt.StringValue("history")).AsTable().Get(rt.StringValue("add"))
+	ln, err := rt.Call1(h.sh.runtime.MainThread(), histWrite, rt.StringValue(line))
 
 	var num int64
 	if ln.Type() == rt.IntType {
@@ -25,8 +35,8 @@ func (h *luaHistory) Write(line string) (int, error) {
 }
 
 func (h *luaHistory) GetLine(idx int) (string, error) {
-	histGet := hshMod.Get(rt.StringValue("history")).AsTable().Get(rt.StringValue("get"))
-	lcmd, err := rt.Call1(l.MainThread(), histGet, rt.IntValue(int64(idx)))
+	histGet := h.sh.hshMod.Get(rt.StringValue("history")).AsTable().Get(rt.StringValue("get"))
+	lcmd, err := rt.Call1(h.sh.runtime.MainThread(), histGet, rt.IntValue(int64(idx)))
 
 	var cmd string
 	if lcmd.Type() == rt.StringType {
@@ -37,8 +47,8 @@ func (h *luaHistory) GetLine(idx int) (string, error) {
 }
 
 func (h *luaHistory) Len() int {
-	histSize := hshMod.Get(rt.StringValue("history")).AsTable().Get(rt.StringValue("size"))
-	ln, _ := rt.Call1(l.MainThread(), histSize)
+	histSize := h.sh.hshMod.Get(rt.StringValue("history")).AsTable().Get(rt.StringValue("size"))
+	ln, _ := rt.Call1(h.sh.runtime.MainThread(), histSize)
 
 	var num int64
 	if ln.Type() == rt.IntType {
@@ -53,12 +63,58 @@ func (h *luaHistory) Dump() interface{} {
 	return nil
 }
 
+// historyHeader is the first line of a history file. Files without it
+// are from older versions of Hilbish, and only have one command per line.
+const historyHeader = "#hilbish-history v1"
+
+// historyEntry is a command in the history, along with info about
+// where and how it was run. Fields that aren't known are left empty.
+// Each entry is saved as a line of JSON, so a crash can only ever
+// leave a partial last line, which gets skipped when reading.
+type historyEntry struct {
+	Cmd string `json:"cmd"`
+	Time int64 `json:"time,omitempty"` // unix time in seconds the command started at
+	Duration int64 `json:"duration,omitempty"` // in milliseconds
+	Cwd string `json:"cwd,omitempty"`
+	ExitCode *int `json:"exitCode,omitempty"`
+	Session string `json:"session,omitempty"`
+	Host string `json:"host,omitempty"`
+}
+
 type fileHistory struct {
-	items []string
+	mu sync.Mutex
+	items []historyEntry
 	f *os.File
+	path string
+	session string
+	// maximum amount of entries to keep, 0 for no limit
+	maxSize int
+
+	// how far into the file has been read, entries after that
+	// have been added by other sessions
+	offset int64
+	// whether entries are written to the file as they are added and
+	// entries from other sessions are loaded, instead of only writing
+	// this session's entries to the file when it is closed
+	shared bool
+	pending []historyEntry
+	stopWatch func()
+	// changed whenever entries are changed instead of only added to
+	gen int
 }
 
 func newFileHistory(path string) *fileHistory {
+	fh := &fileHistory{
+		items: []historyEntry{},
+		session: newSessionID(),
+		shared: true,
+	}
+
+	if path == "" {
+		// only keep history in memory
+		return fh
+	}
+
 	dir := filepath.Dir(path)
 
 	err := os.MkdirAll(dir, 0755)
@@ -73,62 +129,615 @@ func newFileHistory(path string) *fileHistory {
 		}
 	}
 
-	lines := strings.Split(string(data), "\n")
-	itms := make([]string, len(lines) - 1)
-	for i, l := range lines {
-		if i == len(lines) - 1 {
-			continue
+	if len(data) != 0 && !bytes.HasPrefix(data, []byte(historyHeader + "\n")) {
+		err = migrateHistory(path, data)
+		if err != nil {
+			panic(err)
 		}
-		itms[i] = l
 	}
-	f, err := os.OpenFile(path, os.O_APPEND | os.O_WRONLY | os.O_CREATE, 0755)
+
+	f, err := openHistoryFile(path)
 	if err != nil {
 		panic(err)
 	}
+	fh.f = f
+	fh.path = path
 
-	fh := &fileHistory{
-		items: itms,
-		f: f,
-	}
+	fh.lock()
+	fh.reload()
+	fh.unlock()
+
+	fh.stopWatch = watchHistory(path, fh.refresh)
 
 	return fh
 }
 
+func openHistoryFile(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_APPEND | os.O_RDWR | os.O_CREATE, 0755)
+}
+
+// lock locks the history file, so other sessions can't write to it.
+func (h *fileHistory) lock() {
+	h.mu.Lock()
+	for h.f != nil {
+		lockFile(h.f)
+
+		// another session may have replaced the file while we were waiting
+		info, err := os.Stat(h.path)
+		finfo, ferr := h.f.Stat()
+		if err != nil || ferr != nil || os.SameFile(info, finfo) {
+			return
+		}
+
+		f, err := openHistoryFile(h.path)
+		if err != nil {
+			return
+		}
+		unlockFile(h.f)
+		h.f.Close()
+		h.f = f
+		// read all of the new file
+		h.offset = 0
+	}
+}
+
+func (h *fileHistory) unlock() {
+	if h.f != nil {
+		unlockFile(h.f)
+	}
+	h.mu.Unlock()
+}
+
+// refresh loads entries other sessions have added to the history file.
+func (h *fileHistory) refresh() {
+	h.lock()
+	defer h.unlock()
+
+	if h.shared {
+		h.reload()
+	}
+}
+
+// reload reads the history file from where it was last read.
+// If the file has been rewritten, all of it is read again.
+// The file has to be locked.
+func (h *fileHistory) reload() {
+	if h.f == nil {
+		return
+	}
+
+	info, err := h.f.Stat()
+	if err != nil {
+		return
+	}
+	size := info.Size()
+
+	if size < h.offset || h.offset == 0 {
+		// new, cleared or compacted file
+		h.offset = 0
+		h.items = []historyEntry{}
+		h.gen++
+	}
+	if size == h.offset {
+		if size == 0 {
+			h.f.WriteString(historyHeader + "\n")
+			h.offset = int64(len(historyHeader)) + 1
+		}
+		return
+	}
+
+	data := make([]byte, size - h.offset)
+	n, err := h.f.ReadAt(data, h.offset)
+	if err != nil && err != io.EOF {
+		return
+	}
+	data = data[:n]
+
+	h.items = append(h.items, readHistoryEntries(data)...)
+	h.offset += int64(len(data))
+
+	if data[len(data) - 1] != '\n' {
+		// entries are written while the file is locked, so this is
+		// from a session which crashed while writing. make sure the
+		// next entry starts on its own line
+		n, _ := h.f.WriteString("\n")
+		h.offset += int64(n)
+	}
+}
+
+// rewrite replaces the history file with the entries returned by change,
+// which is passed the entries currently in it. If the amount of entries
+// stays the same, the file is left alone. The file is replaced instead of
+// written in place, so other sessions know to read all of it again.
+// The file has to be locked.
+func (h *fileHistory) rewrite(change func([]historyEntry) []historyEntry) error {
+	if h.f == nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(h.path)
+	if err != nil {
+		return err
+	}
+	current := readHistoryEntries(data)
+	entries := change(current)
+	if len(entries) == len(current) {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(historyHeader + "\n")
+	for _, entry := range entries {
+		b, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(h.path), ".hilbish-history-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(buf.Bytes())
+	tmp.Close()
+	if err == nil {
+		err = os.Rename(tmp.Name(), h.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	f, err := openHistoryFile(h.path)
+	if err != nil {
+		return err
+	}
+	lockFile(f)
+	unlockFile(h.f)
+	h.f.Close()
+	h.f = f
+
+	h.offset = int64(buf.Len())
+	if h.shared {
+		h.items = entries
+		h.gen++
+		// in case another session added to the new file before we locked it
+		h.reload()
+	}
+
+	return nil
+}
+
+// compact removes the oldest entries from the history file once
+// it has grown a quarter past the maximum size. The file has to be locked.
+func (h *fileHistory) compact() {
+	if h.maxSize <= 0 {
+		return
+	}
+
+	limit := h.maxSize + h.maxSize / 4
+	if h.shared && len(h.items) <= limit {
+		return
+	}
+
+	h.rewrite(func(entries []historyEntry) []historyEntry {
+		if len(entries) <= limit {
+			return entries
+		}
+		return entries[len(entries) - h.maxSize:]
+	})
+	if len(h.items) > h.maxSize {
+		h.items = h.items[len(h.items) - h.maxSize:]
+		h.gen++
+	}
+}
+
+// readHistoryEntries parses the entries in a history file.
+func readHistoryEntries(data []byte) []historyEntry {
+	items := []historyEntry{}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+
+		var entry historyEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			continue
+		}
+		items = append(items, entry)
+	}
+
+	return items
+}
+
+// migrateHistory converts a history file from the old format of one
+// command per line to the current one. The old file is kept with
+// a .bak extension.
+func migrateHistory(path string, data []byte) error {
+	var buf bytes.Buffer
+	buf.WriteString(historyHeader + "\n")
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+
+		b, err := json.Marshal(historyEntry{Cmd: line})
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+
+	if err := os.WriteFile(path + ".bak", data, 0644); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".hilbish-history-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(buf.Bytes())
+	tmp.Close()
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
+
+func newSessionID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.Itoa(os.Getpid())
+	}
+
+	return hex.EncodeToString(b)
+}
+
 func (h *fileHistory) Write(line string) (int, error) {
-	if line == "" {
+	return h.add(historyEntry{Cmd: line})
+}
+
+func (h *fileHistory) add(entry historyEntry) (int, error) {
+	h.lock()
+	defer h.unlock()
+
+	if entry.Cmd == "" {
 		return len(h.items), nil
 	}
 
-	_, err := h.f.WriteString(line + "\n")
+	if h.shared {
+		// keep entries in the order they were run in
+		h.reload()
+		if err := h.write(append(h.pending, entry)...); err != nil {
+			return 0, err
+		}
+		h.pending = nil
+		h.items = append(h.items, entry)
+		h.compact()
+	} else {
+		h.pending = append(h.pending, entry)
+		h.items = append(h.items, entry)
+		if h.maxSize > 0 && len(h.items) > h.maxSize {
+			h.items = h.items[len(h.items) - h.maxSize:]
+			h.gen++
+		}
+	}
+
+	return len(h.items), nil
+}
+
+// delete removes the entry at idx from the history.
+func (h *fileHistory) delete(idx int) error {
+	h.lock()
+	defer h.unlock()
+
+	if idx < 0 || idx >= len(h.items) {
+		return errors.New("history index out of range")
+	}
+	entry := h.items[idx]
+	h.items = append(h.items[:idx:idx], h.items[idx + 1:]...)
+	h.gen++
+
+	if pidx := idx - (len(h.items) + 1 - len(h.pending)); pidx >= 0 {
+		// it hasn't been written to the file yet
+		h.pending = append(h.pending[:pidx:pidx], h.pending[pidx + 1:]...)
+		return nil
+	}
+
+	return h.rewrite(func(entries []historyEntry) []historyEntry {
+		// the newest matching entry is most likely the one
+		for i := len(entries) - 1; i >= 0; i-- {
+			if reflect.DeepEqual(entries[i], entry) {
+				return append(entries[:i:i], entries[i + 1:]...)
+			}
+		}
+		return entries
+	})
+}
+
+// removeCmd removes all entries of cmd from the history.
+func (h *fileHistory) removeCmd(cmd string) error {
+	h.lock()
+	defer h.unlock()
+
+	found := false
+	filter := func(entries []historyEntry) []historyEntry {
+		kept := make([]historyEntry, 0, len(entries))
+		for _, entry := range entries {
+			if entry.Cmd == cmd {
+				found = true
+				continue
+			}
+			kept = append(kept, entry)
+		}
+		return kept
+	}
+
+	h.items = filter(h.items)
+	h.pending = filter(h.pending)
+	if !found {
+		return nil
+	}
+	h.gen++
+
+	return h.rewrite(filter)
+}
+
+// merge adds entries to the history, in the order they were run in.
+// It returns how many were added.
+func (h *fileHistory) merge(entries []historyEntry) (int, error) {
+	h.lock()
+	defer h.unlock()
+
+	if h.f == nil {
+		before := len(h.items)
+		h.items = mergeHistory(h.items, entries)
+		h.gen++
+		return len(h.items) - before, nil
+	}
+
+	var added int
+	var merged []historyEntry
+	err := h.rewrite(func(current []historyEntry) []historyEntry {
+		merged = mergeHistory(current, entries)
+		added = len(merged) - len(current)
+		return merged
+	})
 	if err != nil {
 		return 0, err
 	}
-	h.f.Sync()
 
-	h.items = append(h.items, line)
-	return len(h.items), nil
+	if !h.shared && added != 0 {
+		h.items = append(merged, h.pending...)
+		h.gen++
+	}
+
+	return added, nil
 }
 
-func (h *fileHistory) GetLine(idx int) (string, error) {
+// since returns the entries after the first n, if the history has only been
+// added to since it was at generation gen. Otherwise all entries are returned,
+// with reset set. It also returns the current generation.
+func (h *fileHistory) since(n, gen int) (entries []historyEntry, reset bool, current int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if gen != h.gen || n > len(h.items) {
+		return append([]historyEntry{}, h.items...), true, h.gen
+	}
+
+	return append([]historyEntry{}, h.items[n:]...), false, h.gen
+}
+
+// last returns the command of the newest entry in the history.
+func (h *fileHistory) last() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
 	if len(h.items) == 0 {
-		return "", nil
+		return ""
 	}
-	if idx == -1 { // this should be fixed readline side
+	return h.items[len(h.items) - 1].Cmd
+}
+
+// write appends entries to the history file. The file has to be locked.
+func (h *fileHistory) write(entries ...historyEntry) error {
+	if h.f == nil || len(entries) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		b, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+
+	// one write for all entries, so a crash can only leave a partial last line
+	n, err := h.f.Write(buf.Bytes())
+	h.offset += int64(n)
+	if err != nil {
+		return err
+	}
+
+	return h.f.Sync()
+}
+
+// setShared sets whether history is shared with other sessions as it is added.
+// If it is, the entries of this session which have not been written yet are.
+func (h *fileHistory) setShared(shared bool) {
+	h.lock()
+	defer h.unlock()
+
+	if h.shared == shared {
+		return
+	}
+	h.shared = shared
+
+	if shared {
+		// entries added by other sessions go before ours, which weren't written yet
+		h.items = h.items[:len(h.items) - len(h.pending)]
+		h.gen++
+		h.reload()
+		h.items = append(h.items, h.pending...)
+		if h.write(h.pending...) == nil {
+			h.pending = nil
+		}
+	}
+}
+
+func (h *fileHistory) GetLine(idx int) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if idx < 0 || idx >= len(h.items) { // -1 should be fixed readline side
 		return "", nil
 	}
-	return h.items[idx], nil
+	return h.items[idx].Cmd, nil
+}
+
+func (h *fileHistory) entry(idx int) (historyEntry, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if idx < 0 || idx >= len(h.items) {
+		return historyEntry{}, false
+	}
+	return h.items[idx], true
+}
+
+// entries returns a copy of all the entries in the history.
+func (h *fileHistory) entries() []historyEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return append([]historyEntry{}, h.items...)
 }
 
 func (h *fileHistory) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
 	return len(h.items)
 }
 
 func (h *fileHistory) Dump() interface{} {
-	return h.items
+	return h.entries()
 }
 
 func (h *fileHistory) clear() {
-	h.items = []string{}
-	h.f.Truncate(0)
-	h.f.Sync()
+	h.lock()
+	defer h.unlock()
+
+	h.items = []historyEntry{}
+	h.gen++
+	h.pending = nil
+	h.rewrite(func([]historyEntry) []historyEntry {
+		return nil
+	})
+}
+
+// close writes the entries that haven't been yet (if history isn't shared),
+// and closes the history file.
+func (h *fileHistory) close() {
+	if h.stopWatch != nil {
+		h.stopWatch()
+	}
+
+	h.lock()
+	h.write(h.pending...)
+	h.pending = nil
+	h.compact()
+	h.unlock()
+
+	if h.f != nil {
+		h.f.Close()
+	}
+}
+
+// historyFilter selects entries in hilbish.history.query.
+// Empty fields match all entries.
+type historyFilter struct {
+	cwd string
+	dir string // matches cwd and directories inside it
+	exitCode *int
+	success *bool
+	since int64
+	until int64
+}
+
+func (f historyFilter) match(e historyEntry) bool {
+	if f.cwd != "" && e.Cwd != f.cwd {
+		return false
+	}
+	if f.dir != "" && !inDir(e.Cwd, f.dir) {
+		return false
+	}
+	if f.exitCode != nil && (e.ExitCode == nil || *e.ExitCode != *f.exitCode) {
+		return false
+	}
+	if f.success != nil && (e.ExitCode == nil || (*e.ExitCode == 0) != *f.success) {
+		return false
+	}
+	if f.since != 0 && e.Time < f.since {
+		return false
+	}
+	if f.until != 0 && (e.Time == 0 || e.Time >= f.until) {
+		return false
+	}
+
+	return true
+}
+
+func (h *fileHistory) query(f historyFilter) ([]int, []historyEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	idxs := []int{}
+	entries := []historyEntry{}
+	for i, e := range h.items {
+		if f.match(e) {
+			idxs = append(idxs, i)
+			entries = append(entries, e)
+		}
+	}
+
+	return idxs, entries
+}
+
+func historyEntryTable(e historyEntry, idx int) *rt.Table {
+	tbl := rt.NewTable()
+	tbl.Set(rt.StringValue("cmd"), rt.StringValue(e.Cmd))
+	tbl.Set(rt.StringValue("index"), rt.IntValue(int64(idx)))
+	if e.Time != 0 {
+		tbl.Set(rt.StringValue("time"), rt.IntValue(e.Time))
+	}
+	if e.Duration != 0 {
+		tbl.Set(rt.StringValue("duration"), rt.IntValue(e.Duration))
+	}
+	if e.Cwd != "" {
+		tbl.Set(rt.StringValue("cwd"), rt.StringValue(e.Cwd))
+	}
+	if e.ExitCode != nil {
+		tbl.Set(rt.StringValue("exitCode"), rt.IntValue(int64(*e.ExitCode)))
+	}
+	if e.Session != "" {
+		tbl.Set(rt.StringValue("session"), rt.StringValue(e.Session))
+	}
+	if e.Host != "" {
+		tbl.Set(rt.StringValue("host"), rt.StringValue(e.Host))
+	}
+
+	return tbl
 }
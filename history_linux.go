@@ -0,0 +1,67 @@
+package hilbish
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// watchHistory calls changed whenever the history file at path is written to.
+// It returns a function to stop watching.
+// The directory of the file is watched instead of the file itself, since
+// compacting the history replaces the file with a new one.
+func watchHistory(path string, changed func()) func() {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return func() {}
+	}
+	mask := uint32(unix.IN_MODIFY | unix.IN_CREATE | unix.IN_MOVED_TO)
+	if _, err := unix.InotifyAddWatch(fd, filepath.Dir(path), mask); err != nil {
+		unix.Close(fd)
+		return func() {}
+	}
+	name := []byte(filepath.Base(path))
+
+	// as a non blocking file, reads go through the runtime poller
+	// and closing it stops the read below
+	f := os.NewFile(uintptr(fd), "inotify")
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := f.Read(buf)
+			if err != nil {
+				return
+			}
+			if historyEventFor(buf[:n], name) {
+				changed()
+			}
+		}
+	}()
+
+	return func() {
+		f.Close()
+	}
+}
+
+// historyEventFor reports whether any of the inotify events
+// in buf are for the file with the passed name.
+func historyEventFor(buf, name []byte) bool {
+	for len(buf) >= unix.SizeofInotifyEvent {
+		ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[0]))
+		end := unix.SizeofInotifyEvent + int(ev.Len)
+		if end > len(buf) {
+			return false
+		}
+		// the name is padded with NUL bytes
+		evName := bytes.TrimRight(buf[unix.SizeofInotifyEvent:end], "\x00")
+		if bytes.Equal(evName, name) {
+			return true
+		}
+		buf = buf[end:]
+	}
+
+	return false
+}
@@ -0,0 +1,71 @@
+package hilbish
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchHistoryReplaced(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".hilbish-history")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := make(chan struct{}, 16)
+	stop := watchHistory(path, func() {
+		changes <- struct{}{}
+	})
+	defer stop()
+
+	waitChange := func(what string) {
+		select {
+		case <-changes:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no change seen after %s", what)
+		}
+		// drop the rest of the events for the same change
+		for {
+			select {
+			case <-changes:
+			case <-time.After(50 * time.Millisecond):
+				return
+			}
+		}
+	}
+
+	appendFile := func() {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString("ls\n")
+		f.Close()
+	}
+
+	appendFile()
+	waitChange("writing")
+
+	// like compacting the history does
+	tmp := filepath.Join(dir, ".hilbish-history.tmp")
+	if err := os.WriteFile(tmp, []byte("ls\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	waitChange("replacing the file")
+
+	appendFile()
+	waitChange("writing to the new file")
+
+	// other files in the directory aren't the history
+	os.WriteFile(filepath.Join(dir, "other"), []byte("x"), 0644)
+	select {
+	case <-changes:
+		t.Error("change seen for another file")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,9 @@
+// +build !linux
+
+package hilbish
+
+// watchHistory does nothing on this platform,
+// history from other sessions is only loaded at the prompt.
+func watchHistory(path string, changed func()) func() {
+	return func() {}
+}
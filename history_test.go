@@ -0,0 +1,210 @@
+package hilbish
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadHistoryEntries(t *testing.T) {
+	type TestReadHistoryEntriesT struct {
+		Data string
+		Expected []historyEntry
+	}
+
+	tests := []TestReadHistoryEntriesT{
+		{
+			Data: "",
+			Expected: []historyEntry{},
+		},
+		{
+			Data: historyHeader + "\n" + `{"cmd":"ls"}` + "\n" + `{"cmd":"cd /tmp","time":10,"cwd":"/home"}` + "\n",
+			Expected: []historyEntry{
+				{Cmd: "ls"},
+				{Cmd: "cd /tmp", Time: 10, Cwd: "/home"},
+			},
+		},
+		{
+			// a partial last line from a crash is skipped
+			Data: historyHeader + "\n" + `{"cmd":"ls"}` + "\n" + `{"cmd":"ec`,
+			Expected: []historyEntry{{Cmd: "ls"}},
+		},
+		{
+			// and so is one which another entry was written after
+			Data: `{"cmd":"ec{"cmd":"pwd"}` + "\n" + `{"cmd":"pwd"}` + "\n",
+			Expected: []historyEntry{{Cmd: "pwd"}},
+		},
+	}
+
+	for i, test := range tests {
+		entries := readHistoryEntries([]byte(test.Data))
+		if !reflect.DeepEqual(entries, test.Expected) {
+			t.Errorf("%d: expected %+v, got %+v", i, test.Expected, entries)
+		}
+	}
+}
+
+func TestMigrateHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".hilbish-history")
+	old := "ls\n\necho hi\ncd /tmp\n"
+	if err := os.WriteFile(path, []byte(old), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := newFileHistory(path)
+	defer h.close()
+
+	expected := []historyEntry{{Cmd: "ls"}, {Cmd: "echo hi"}, {Cmd: "cd /tmp"}}
+	if entries := h.entries(); !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected %+v, got %+v", expected, entries)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), historyHeader + "\n") {
+		t.Errorf("migrated history doesn't start with the header: %q", data)
+	}
+
+	bak, err := os.ReadFile(path + ".bak")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bak) != old {
+		t.Errorf("expected the old history to be kept, got %q", bak)
+	}
+}
+
+func TestHistoryPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".hilbish-history")
+	code := 1
+	entry := historyEntry{Cmd: "false", Time: 100, Duration: 5, Cwd: "/tmp", ExitCode: &code}
+
+	h := newFileHistory(path)
+	h.add(historyEntry{Cmd: "ls"})
+	h.add(entry)
+	h.close()
+
+	h = newFileHistory(path)
+	defer h.close()
+
+	expected := []historyEntry{{Cmd: "ls"}, entry}
+	if entries := h.entries(); !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected %+v, got %+v", expected, entries)
+	}
+}
+
+func TestHistoryFilter(t *testing.T) {
+	zero, one := 0, 1
+	yes, no := true, false
+	entry := historyEntry{Cmd: "make", Time: 100, Cwd: "/src/hilbish", ExitCode: &one}
+
+	type TestHistoryFilterT struct {
+		Filter historyFilter
+		Entry historyEntry
+		Expected bool
+	}
+
+	tests := []TestHistoryFilterT{
+		{Filter: historyFilter{}, Entry: entry, Expected: true},
+		{Filter: historyFilter{cwd: "/src/hilbish"}, Entry: entry, Expected: true},
+		{Filter: historyFilter{cwd: "/src"}, Entry: entry, Expected: false},
+		{Filter: historyFilter{dir: "/src"}, Entry: entry, Expected: true},
+		{Filter: historyFilter{dir: "/src/hil"}, Entry: entry, Expected: false},
+		{Filter: historyFilter{dir: "/src/hilbish"}, Entry: entry, Expected: true},
+		{Filter: historyFilter{exitCode: &one}, Entry: entry, Expected: true},
+		{Filter: historyFilter{exitCode: &zero}, Entry: entry, Expected: false},
+		{Filter: historyFilter{success: &no}, Entry: entry, Expected: true},
+		{Filter: historyFilter{success: &yes}, Entry: entry, Expected: false},
+		{Filter: historyFilter{since: 100}, Entry: entry, Expected: true},
+		{Filter: historyFilter{since: 101}, Entry: entry, Expected: false},
+		{Filter: historyFilter{until: 101}, Entry: entry, Expected: true},
+		{Filter: historyFilter{until: 100}, Entry: entry, Expected: false},
+		// entries from older versions don't have any of the info to match
+		{Filter: historyFilter{success: &no}, Entry: historyEntry{Cmd: "make"}, Expected: false},
+		{Filter: historyFilter{until: 101}, Entry: historyEntry{Cmd: "make"}, Expected: false},
+		{Filter: historyFilter{dir: "/src"}, Entry: historyEntry{Cmd: "make"}, Expected: false},
+	}
+
+	for i, test := range tests {
+		if match := test.Filter.match(test.Entry); match != test.Expected {
+			t.Errorf("%d: expected %v, got %v for filter %+v", i, test.Expected, match, test.Filter)
+		}
+	}
+}
+
+func historyCmds(h *fileHistory) []string {
+	cmds := []string{}
+	for _, e := range h.entries() {
+		cmds = append(cmds, e.Cmd)
+	}
+
+	return cmds
+}
+
+func TestHistoryRemove(t *testing.T) {
+	type TestHistoryRemoveT struct {
+		Shared bool
+		Expected []string
+	}
+
+	for _, test := range []TestHistoryRemoveT{
+		{Shared: true, Expected: []string{"b", "c"}},
+		{Shared: false, Expected: []string{"b", "c"}},
+	} {
+		path := filepath.Join(t.TempDir(), ".hilbish-history")
+		h := newFileHistory(path)
+		h.setShared(test.Shared)
+		for _, cmd := range []string{"a", "b", "a", "c", "a"} {
+			h.add(historyEntry{Cmd: cmd})
+		}
+
+		if err := h.delete(4); err != nil {
+			t.Fatal(err)
+		}
+		if err := h.removeCmd("a"); err != nil {
+			t.Fatal(err)
+		}
+		if cmds := historyCmds(h); !reflect.DeepEqual(cmds, test.Expected) {
+			t.Errorf("shared %v: expected %q, got %q", test.Shared, test.Expected, cmds)
+		}
+		if err := h.delete(5); err == nil {
+			t.Errorf("shared %v: expected an error deleting past the end", test.Shared)
+		}
+		h.close()
+
+		// the file has the same entries
+		h = newFileHistory(path)
+		if cmds := historyCmds(h); !reflect.DeepEqual(cmds, test.Expected) {
+			t.Errorf("shared %v: expected %q after reopening, got %q", test.Shared, test.Expected, cmds)
+		}
+		h.close()
+	}
+}
+
+func TestHistorySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".hilbish-history")
+	h := newFileHistory(path)
+	h.mu.Lock()
+	h.maxSize = 4
+	h.mu.Unlock()
+
+	for _, cmd := range []string{"1", "2", "3", "4", "5", "6"} {
+		h.add(historyEntry{Cmd: cmd})
+	}
+	// a quarter past the size, the history is cut down to it
+	expected := []string{"3", "4", "5", "6"}
+	if cmds := historyCmds(h); !reflect.DeepEqual(cmds, expected) {
+		t.Errorf("expected %q, got %q", expected, cmds)
+	}
+	h.close()
+
+	h = newFileHistory(path)
+	defer h.close()
+	if cmds := historyCmds(h); !reflect.DeepEqual(cmds, expected) {
+		t.Errorf("expected %q after reopening, got %q", expected, cmds)
+	}
+}
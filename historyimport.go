@@ -0,0 +1,222 @@
+package hilbish
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// historyParsers parse the history files of other shells.
+var historyParsers = map[string]func([]byte) []historyEntry{
+	"bash": parseBashHistory,
+	"zsh": parseZshHistory,
+	"fish": parseFishHistory,
+}
+
+// importHistory reads the history file of another shell at path.
+// If format is empty, it is guessed from the name and contents of the file.
+func importHistory(path, format string) ([]historyEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == "" {
+		format = historyFormat(path, data)
+	}
+
+	parse, ok := historyParsers[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown history format %s (expected bash, zsh or fish)", format)
+	}
+
+	return parse(data), nil
+}
+
+func historyFormat(path string, data []byte) string {
+	name := filepath.Base(path)
+	switch {
+	case strings.Contains(name, "zsh"):
+		return "zsh"
+	case strings.Contains(name, "fish"):
+		return "fish"
+	case strings.Contains(name, "bash"):
+		return "bash"
+	case bytes.HasPrefix(data, []byte(": ")):
+		return "zsh"
+	case bytes.HasPrefix(data, []byte("- cmd: ")):
+		return "fish"
+	}
+
+	return "bash"
+}
+
+// parseBashHistory parses bash history, which is a command per line.
+// With HISTTIMEFORMAT set, commands are preceded by a line with
+// a # and the time they were run at.
+func parseBashHistory(data []byte) []historyEntry {
+	entries := []historyEntry{}
+	var when int64
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data) + 1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			if t, err := strconv.ParseInt(line[1:], 10, 64); err == nil {
+				when = t
+				continue
+			}
+		}
+		if line == "" {
+			continue
+		}
+
+		entries = append(entries, historyEntry{
+			Cmd: line,
+			Time: when,
+		})
+		when = 0
+	}
+
+	return entries
+}
+
+// parseZshHistory parses zsh history, which is a command per line, or with
+// the EXTENDED_HISTORY option `: <start time>:<duration in seconds>;<command>`.
+// Commands with multiple lines have a backslash at the end of each line.
+func parseZshHistory(data []byte) []historyEntry {
+	entries := []historyEntry{}
+	lines := strings.Split(unmetafyZsh(data), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		for strings.HasSuffix(line, "\\") && i + 1 < len(lines) {
+			i++
+			line = line[:len(line) - 1] + "\n" + lines[i]
+		}
+		if line == "" {
+			continue
+		}
+
+		var entry historyEntry
+		if meta, cmd, ok := strings.Cut(line, ";"); ok && strings.HasPrefix(meta, ": ") {
+			start, dur, _ := strings.Cut(meta[2:], ":")
+			entry.Time, _ = strconv.ParseInt(strings.TrimSpace(start), 10, 64)
+			secs, _ := strconv.ParseInt(dur, 10, 64)
+			entry.Duration = secs * 1000
+			entry.Cmd = cmd
+		} else {
+			entry.Cmd = line
+		}
+
+		if entry.Cmd != "" {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// unmetafyZsh decodes the "metafied" bytes zsh writes to its history file.
+// Some bytes are written as 0x83 followed by the byte xored with 32.
+func unmetafyZsh(data []byte) string {
+	if bytes.IndexByte(data, 0x83) == -1 {
+		return string(data)
+	}
+
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] == 0x83 && i + 1 < len(data) {
+			i++
+			out = append(out, data[i] ^ 32)
+			continue
+		}
+		out = append(out, data[i])
+	}
+
+	return string(out)
+}
+
+// parseFishHistory parses fish history, which is a YAML-like list of
+// entries with `cmd` and `when` (and `paths`, which are ignored) keys.
+func parseFishHistory(data []byte) []historyEntry {
+	entries := []historyEntry{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data) + 1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "- cmd: "):
+			entries = append(entries, historyEntry{
+				Cmd: unescapeFish(strings.TrimPrefix(line, "- cmd: ")),
+			})
+		case strings.HasPrefix(line, "  when: ") && len(entries) != 0:
+			entries[len(entries) - 1].Time, _ = strconv.ParseInt(strings.TrimPrefix(line, "  when: "), 10, 64)
+		}
+	}
+
+	return entries
+}
+
+// unescapeFish decodes the escapes fish uses for newlines
+// and backslashes in commands in its history file.
+func unescapeFish(cmd string) string {
+	if !strings.Contains(cmd, "\\") {
+		return cmd
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(cmd); i++ {
+		if cmd[i] == '\\' && i + 1 < len(cmd) {
+			switch cmd[i + 1] {
+			case 'n':
+				sb.WriteByte('\n')
+				i++
+				continue
+			case '\\':
+				sb.WriteByte('\\')
+				i++
+				continue
+			}
+		}
+		sb.WriteByte(cmd[i])
+	}
+
+	return sb.String()
+}
+
+// mergeHistory adds imported entries to the current ones, in the order
+// they were run. Imported entries already in the history are skipped,
+// so importing the same file twice doesn't duplicate them.
+// Entries without a time are put before those with one.
+func mergeHistory(current, imported []historyEntry) []historyEntry {
+	type key struct {
+		cmd string
+		time int64
+	}
+	seen := map[key]bool{}
+	for _, entry := range current {
+		seen[key{entry.Cmd, entry.Time}] = true
+	}
+
+	merged := append([]historyEntry{}, current...)
+	for _, entry := range imported {
+		if seen[key{entry.Cmd, entry.Time}] {
+			continue
+		}
+		merged = append(merged, entry)
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Time < merged[j].Time
+	})
+
+	return merged
+}
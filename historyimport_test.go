@@ -0,0 +1,125 @@
+package hilbish
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHistory(t *testing.T) {
+	type TestParseHistoryT struct {
+		Format string
+		Data string
+		Expected []historyEntry
+	}
+
+	tests := []TestParseHistoryT{
+		{
+			Format: "bash",
+			Data: "ls\n\ncd /tmp\n",
+			Expected: []historyEntry{{Cmd: "ls"}, {Cmd: "cd /tmp"}},
+		},
+		{
+			// with HISTTIMEFORMAT set
+			Format: "bash",
+			Data: "#1700000000\nls\ncd /tmp\n#1700000005\n#not a time\n",
+			Expected: []historyEntry{
+				{Cmd: "ls", Time: 1700000000},
+				{Cmd: "cd /tmp"},
+				{Cmd: "#not a time", Time: 1700000005},
+			},
+		},
+		{
+			Format: "zsh",
+			Data: "ls\ncd /tmp\n",
+			Expected: []historyEntry{{Cmd: "ls"}, {Cmd: "cd /tmp"}},
+		},
+		{
+			// EXTENDED_HISTORY, with a multi-line command
+			Format: "zsh",
+			Data: ": 1700000000:3;make\n: 1700000010:0;for i in 1 2; do\\\necho $i\\\ndone\n: 1700000020:0;echo a;b\n",
+			Expected: []historyEntry{
+				{Cmd: "make", Time: 1700000000, Duration: 3000},
+				{Cmd: "for i in 1 2; do\necho $i\ndone", Time: 1700000010},
+				{Cmd: "echo a;b", Time: 1700000020},
+			},
+		},
+		{
+			// metafied bytes: é is 0xc3 0xa9, and 0xa9 is written as 0x83 0x89
+			Format: "zsh",
+			Data: "echo caf\xc3\x83\x89\n",
+			Expected: []historyEntry{{Cmd: "echo café"}},
+		},
+		{
+			Format: "fish",
+			Data: "- cmd: ls\n  when: 1700000000\n- cmd: echo a\\nb \\\\n\n  when: 1700000001\n  paths:\n    - /tmp\n- cmd: pwd\n",
+			Expected: []historyEntry{
+				{Cmd: "ls", Time: 1700000000},
+				{Cmd: "echo a\nb \\n", Time: 1700000001},
+				{Cmd: "pwd"},
+			},
+		},
+	}
+
+	for i, test := range tests {
+		entries := historyParsers[test.Format]([]byte(test.Data))
+		if !reflect.DeepEqual(entries, test.Expected) {
+			t.Errorf("%d (%s): expected %+v, got %+v", i, test.Format, test.Expected, entries)
+		}
+	}
+}
+
+func TestHistoryFormat(t *testing.T) {
+	type TestHistoryFormatT struct {
+		Path string
+		Data string
+		Expected string
+	}
+
+	tests := []TestHistoryFormatT{
+		{Path: "/home/user/.bash_history", Data: ": not zsh", Expected: "bash"},
+		{Path: "/home/user/.zsh_history", Data: "ls", Expected: "zsh"},
+		{Path: "/home/user/.local/share/fish/fish_history", Data: "", Expected: "fish"},
+		{Path: "/tmp/hist", Data: ": 1700000000:0;ls", Expected: "zsh"},
+		{Path: "/tmp/hist", Data: "- cmd: ls", Expected: "fish"},
+		{Path: "/tmp/hist", Data: "ls", Expected: "bash"},
+	}
+
+	for _, test := range tests {
+		if format := historyFormat(test.Path, []byte(test.Data)); format != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Path, test.Expected, format)
+		}
+	}
+}
+
+func TestMergeHistory(t *testing.T) {
+	current := []historyEntry{
+		{Cmd: "old"},
+		{Cmd: "b", Time: 20},
+		{Cmd: "d", Time: 40},
+	}
+	imported := []historyEntry{
+		{Cmd: "a", Time: 10},
+		{Cmd: "b", Time: 20},
+		{Cmd: "c", Time: 30},
+		{Cmd: "untimed"},
+		{Cmd: "old"},
+	}
+
+	expected := []historyEntry{
+		{Cmd: "old"},
+		{Cmd: "untimed"},
+		{Cmd: "a", Time: 10},
+		{Cmd: "b", Time: 20},
+		{Cmd: "c", Time: 30},
+		{Cmd: "d", Time: 40},
+	}
+	merged := mergeHistory(current, imported)
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("expected %+v, got %+v", expected, merged)
+	}
+
+	// importing again adds nothing
+	if again := mergeHistory(merged, imported); !reflect.DeepEqual(again, expected) {
+		t.Errorf("expected %+v after importing again, got %+v", expected, again)
+	}
+}
@@ -0,0 +1,270 @@
+package hilbish
+
+import (
+	"errors"
+
+	"hilbish/golibs/bait"
+	"hilbish/moduleapi"
+	"hilbish/util"
+
+	rt "github.com/arnodel/golua/runtime"
+	"mvdan.cc/sh/v3/interp"
+)
+
+// Shell is the moduleapi.Host given to native modules.
+var _ moduleapi.Host = (*Shell)(nil)
+
+// Runtime returns the Lua runtime of the shell.
+func (sh *Shell) Runtime() *rt.Runtime {
+	return sh.runtime
+}
+
+// Hooks returns the event emitter the shell throws its hooks on.
+func (sh *Shell) Hooks() *bait.Bait {
+	return sh.hooks
+}
+
+// RegisterCommand adds a commander with the given name.
+func (sh *Shell) RegisterCommand(name string, cmd moduleapi.Command) {
+	cmdFunc := func(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+		if err := c.CheckNArgs(2); err != nil {
+			return nil, err
+		}
+		luaArgs, err := c.TableArg(0)
+		if err != nil {
+			return nil, err
+		}
+		sinks, err := c.TableArg(1)
+		if err != nil {
+			return nil, err
+		}
+
+		var args []string
+		util.ForEach(luaArgs, func(k rt.Value, v rt.Value) {
+			if v.Type() == rt.StringType {
+				args = append(args, v.AsString())
+			}
+		})
+
+		stdin, _ := valueToSink(sinks.Get(rt.StringValue("input")))
+		stdout, _ := valueToSink(sinks.Get(rt.StringValue("out")))
+		stderr, _ := valueToSink(sinks.Get(rt.StringValue("err")))
+		if stdin == nil || stdout == nil || stderr == nil {
+			return nil, errors.New("missing sinks for command " + name)
+		}
+
+		code := cmd(args, stdin.reader, stdout.writer, stderr.writer)
+		stdout.writer.Flush()
+		stderr.writer.Flush()
+
+		return c.PushingNext1(t.Runtime, rt.IntValue(int64(code))), nil
+	}
+
+	sh.cmds.Commands[name] = rt.NewGoFunction(cmdFunc, name, 2, false)
+}
+
+// RegisterCompleter adds a completer for a scope, like `command.git`.
+func (sh *Shell) RegisterCompleter(scope string, completer moduleapi.Completer) {
+	compFunc := func(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+		query, ctx, fds, err := getCompleteParams(t, c)
+		if err != nil {
+			return nil, err
+		}
+		// the handler passes the context it completed with,
+		// otherwise it's parsed from the line
+		var compTbl *rt.Table
+		if c.NArgs() > 3 {
+			compTbl, _ = c.Arg(3).TryTable()
+		}
+		var comp moduleapi.CompletionContext
+		if compTbl != nil {
+			comp = moduleCompletionContext(compTbl)
+		} else {
+			comp = contextFor(ctx).module()
+		}
+
+		groups, pfx := completer(query, ctx, fds, comp)
+		luaGroups := rt.NewTable()
+		for i, group := range groups {
+			luaItems := rt.NewTable()
+			// items with descriptions are keyed by name, so the
+			// others are counted apart to keep the list without holes
+			j := 0
+			for _, item := range group.Items {
+				if desc, ok := group.Descriptions[item]; ok {
+					descTbl := rt.NewTable()
+					descTbl.Set(rt.IntValue(1), rt.StringValue(desc))
+					luaItems.Set(rt.StringValue(item), rt.TableValue(descTbl))
+					continue
+				}
+				j++
+				luaItems.Set(rt.IntValue(int64(j)), rt.StringValue(item))
+			}
+
+			typ := group.Type
+			if typ == "" {
+				typ = "grid"
+			}
+
+			luaGroup := rt.NewTable()
+			luaGroup.Set(rt.StringValue("type"), rt.StringValue(typ))
+			luaGroup.Set(rt.StringValue("items"), rt.TableValue(luaItems))
+			luaGroups.Set(rt.IntValue(int64(i + 1)), rt.TableValue(luaGroup))
+		}
+
+		return c.PushingNext(t.Runtime, rt.TableValue(luaGroups), rt.StringValue(pfx)), nil
+	}
+
+	sh.luaCompletions[scope] = rt.NewGoFunction(compFunc, scope, 4, false)
+}
+
+// moduleCompletionContext converts a table from hilbish.completion.context
+// for Go completers.
+func moduleCompletionContext(tbl *rt.Table) moduleapi.CompletionContext {
+	comp := moduleapi.CompletionContext{Index: -1}
+	comp.Command, _ = tbl.Get(rt.StringValue("command")).TryString()
+	if index, ok := tbl.Get(rt.StringValue("index")).TryInt(); ok {
+		comp.Index = int(index)
+	}
+	if args, ok := tbl.Get(rt.StringValue("args")).TryTable(); ok {
+		util.ForEach(args, func(_ rt.Value, v rt.Value) {
+			if arg, ok := v.TryString(); ok {
+				comp.Args = append(comp.Args, arg)
+			}
+		})
+	}
+	comp.Word, _ = tbl.Get(rt.StringValue("word")).TryString()
+	comp.Value, _ = tbl.Get(rt.StringValue("value")).TryString()
+	comp.Quote, _ = tbl.Get(rt.StringValue("quote")).TryString()
+	comp.Variable = rt.Truth(tbl.Get(rt.StringValue("variable")))
+	comp.Redirect = rt.Truth(tbl.Get(rt.StringValue("redirect")))
+	comp.Assignment, _ = tbl.Get(rt.StringValue("assignment")).TryString()
+
+	return comp
+}
+
+// RegisterRunner adds a runner with the given name.
+func (sh *Shell) RegisterRunner(name string, runner moduleapi.Runner) error {
+	runFunc := func(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+		if err := c.Check1Arg(); err != nil {
+			return nil, err
+		}
+		input, err := c.StringArg(0)
+		if err != nil {
+			return nil, err
+		}
+
+		res := runner(input)
+		var luaErr rt.Value = rt.NilValue
+		if res.Err != nil {
+			luaErr = rt.StringValue(res.Err.Error())
+		}
+		runnerRet := rt.NewTable()
+		runnerRet.Set(rt.StringValue("input"), rt.StringValue(res.Input))
+		runnerRet.Set(rt.StringValue("exitCode"), rt.IntValue(int64(res.ExitCode)))
+		runnerRet.Set(rt.StringValue("continue"), rt.BoolValue(res.Continue))
+		runnerRet.Set(rt.StringValue("err"), luaErr)
+
+		return c.PushingNext1(t.Runtime, rt.TableValue(runnerRet)), nil
+	}
+
+	runnerAdd := sh.hshMod.Get(rt.StringValue("runner")).AsTable().Get(rt.StringValue("add"))
+	if runnerAdd.IsNil() {
+		return errors.New("runner interface is not loaded")
+	}
+	_, err := rt.Call1(sh.runtime.MainThread(), runnerAdd, rt.StringValue(name),
+	rt.FunctionValue(rt.NewGoFunction(runFunc, name, 1, false)))
+
+	return err
+}
+
+// Run runs cmd as shell script and returns its exit code.
+func (sh *Shell) Run(cmd string) (uint8, error) {
+	_, _, err := sh.execCommand(cmd, nil)
+	if code, ok := interp.IsExitStatus(err); ok {
+		return code, nil
+	} else if err != nil {
+		return 1, err
+	}
+
+	return 0, nil
+}
+
+// Jobs returns the job manager of the shell.
+func (sh *Shell) Jobs() moduleapi.Jobs {
+	return sh.jobs
+}
+
+func (j *jobHandler) All() []moduleapi.Job {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	all := make([]moduleapi.Job, 0, len(j.jobs))
+	for _, jb := range j.jobs {
+		all = append(all, jb)
+	}
+
+	return all
+}
+
+func (j *jobHandler) Get(id int) moduleapi.Job {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	if jb := j.jobs[id]; jb != nil {
+		return jb
+	}
+
+	return nil
+}
+
+func (j *jobHandler) Last() moduleapi.Job {
+	if jb := j.getLatest(); jb != nil {
+		return jb
+	}
+
+	return nil
+}
+
+func (j *jobHandler) Add(cmd string, args []string, path string) moduleapi.Job {
+	return j.add(cmd, args, path)
+}
+
+func (j *job) ID() int {
+	return j.id
+}
+
+func (j *job) PID() int {
+	return j.pid
+}
+
+func (j *job) Cmd() string {
+	return j.cmd
+}
+
+func (j *job) Running() bool {
+	return j.running
+}
+
+func (j *job) ExitCode() int {
+	return j.exitCode
+}
+
+func (j *job) Start() error {
+	if j.running {
+		return nil
+	}
+
+	err := j.start()
+	j.exitCode = int(handleExecErr(err))
+	j.finish()
+
+	return err
+}
+
+func (j *job) Stop() {
+	if j.running {
+		j.stop()
+		j.finish()
+	}
+}
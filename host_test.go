@@ -0,0 +1,81 @@
+package hilbish
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	"hilbish/moduleapi"
+	"hilbish/util"
+)
+
+func TestHostCompleter(t *testing.T) {
+	var h moduleapi.Host = New(WithHistory(""))
+	defer h.(*Shell).Close()
+
+	var got moduleapi.CompletionContext
+	h.RegisterCompleter("command.greet", func(query, ctx string, fields []string, comp moduleapi.CompletionContext) ([]moduleapi.CompletionGroup, string) {
+		got = comp
+		return []moduleapi.CompletionGroup{{Items: []string{"world"}}}, query
+	})
+
+	type TestHostCompleterT struct {
+		Lua string
+		Expected moduleapi.CompletionContext
+	}
+
+	tests := []TestHostCompleterT{
+		{
+			// the context the handler passes on
+			Lua: `hilbish.completion.call('command.greet', '"wo', 'sudo greet "wo', {'greet', 'wo'}, hilbish.completion.context 'sudo greet "wo')`,
+			Expected: moduleapi.CompletionContext{Command: "greet", Index: 1, Args: []string{"greet", "wo"}, Word: `"wo`, Value: "wo", Quote: `"`},
+		},
+		{
+			// or the line parsed if there is none
+			Lua: `hilbish.completion.call('command.greet', '', 'greet > ', {'greet'})`,
+			Expected: moduleapi.CompletionContext{Command: "greet", Index: -1, Args: []string{"greet"}, Redirect: true},
+		},
+	}
+
+	for _, test := range tests {
+		got = moduleapi.CompletionContext{}
+		if _, err := util.DoString(h.Runtime(), test.Lua); err != nil {
+			t.Errorf("%s: %s", test.Lua, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.Expected) {
+			t.Errorf("%s: expected %+v, got %+v", test.Lua, test.Expected, got)
+		}
+	}
+}
+
+func TestHostCommand(t *testing.T) {
+	var h moduleapi.Host = New(WithHistory(""))
+	defer h.(*Shell).Close()
+
+	var args []string
+	h.RegisterCommand("greet", func(cmdArgs []string, in io.Reader, out, err io.Writer) uint8 {
+		args = cmdArgs
+		return 3
+	})
+	var thrown bool
+	h.Hooks().On("greeted", func(...interface{}) {
+		thrown = true
+	})
+
+	code, err := h.Run("greet 'the world' && echo never")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != 3 {
+		t.Errorf("expected exit code 3, got %d", code)
+	}
+	if !reflect.DeepEqual(args, []string{"the world"}) {
+		t.Errorf("expected the command to get its args unquoted, got %q", args)
+	}
+
+	h.Hooks().Emit("greeted")
+	if !thrown {
+		t.Error("expected the hook to be thrown")
+	}
+}
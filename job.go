@@ -1,4 +1,4 @@
-package main
+package hilbish
 
 import (
 	"bytes"
@@ -15,7 +15,6 @@ import (
 	rt "github.com/arnodel/golua/runtime"
 )
 
-var jobs *jobHandler
 var jobMetaKey = rt.StringValue("hshjob")
 
 // #type
@@ -45,6 +44,7 @@ type job struct {
 	stdout *bytes.Buffer
 	stderr *bytes.Buffer
 	ud *rt.UserData
+	handler *jobHandler
 }
 
 func (j *job) start() error {
@@ -77,7 +77,7 @@ func (j *job) start() error {
 	j.pid = proc.Pid
 	j.running = true
 
-	hooks.Emit("job.start", rt.UserDataValue(j.ud))
+	j.handler.sh.hooks.Emit("job.start", rt.UserDataValue(j.ud))
 
 	return err
 }
@@ -92,7 +92,7 @@ func (j *job) stop() {
 
 func (j *job) finish() {
 	j.running = false
-	hooks.Emit("job.done", rt.UserDataValue(j.ud))
+	j.handler.sh.hooks.Emit("job.done", rt.UserDataValue(j.ud))
 }
 
 func (j *job) wait() {
@@ -134,12 +134,7 @@ func luaStartJob(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 		return nil, err
 	}
 
-	if !j.running {
-		err := j.start()
-		exit := handleExecErr(err)
-		j.exitCode = int(exit)
-		j.finish()
-	}
+	j.Start()
 
 	return c.Next(), nil
 }
@@ -158,10 +153,7 @@ func luaStopJob(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 		return nil, err
 	}
 
-	if j.running {
-		j.stop()
-		j.finish()
-	}
+	j.Stop()
 
 	return c.Next(), nil
 }
@@ -186,7 +178,7 @@ func luaForegroundJob(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	}
 
 	// lua code can run in other threads and goroutines, so this exists
-	jobs.foreground = true
+	j.handler.foreground = true
 	// this is kinda funny
 	// background continues the process incase it got suspended
 	err = j.background()
@@ -198,7 +190,7 @@ func luaForegroundJob(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	if err != nil {
 		return nil, err
 	}
-	jobs.foreground = false
+	j.handler.foreground = false
 
 	return c.Next(), nil
 }
@@ -234,13 +226,15 @@ type jobHandler struct {
 	latestID int
 	foreground bool // if job currently in the foreground
 	mu *sync.RWMutex
+	sh *Shell
 }
 
-func newJobHandler() *jobHandler {
+func newJobHandler(sh *Shell) *jobHandler {
 	return &jobHandler{
 		jobs: make(map[int]*job),
 		latestID: 0,
 		mu: &sync.RWMutex{},
+		sh: sh,
 	}
 }
 
@@ -259,11 +253,12 @@ func (j *jobHandler) add(cmd string, args []string, path string) *job {
 		cmderr: os.Stderr,
 		stdout: &bytes.Buffer{},
 		stderr: &bytes.Buffer{},
+		handler: j,
 	}
-	jb.ud = jobUserData(jb)
+	jb.ud = jobUserData(j.sh.runtime, jb)
 
 	j.jobs[j.latestID] = jb
-	hooks.Emit("job.add", rt.UserDataValue(jb.ud))
+	j.sh.hooks.Emit("job.add", rt.UserDataValue(jb.ud))
 
 	return jb
 }
@@ -318,7 +313,7 @@ func (j *jobHandler) loader(rtm *rt.Runtime) *rt.Table {
 		"foreground": {luaForegroundJob, 1, false},
 		"background": {luaBackgroundJob, 1, false},
 	}
-	util.SetExports(l, jobMethods, jFuncs)
+	util.SetExports(rtm, jobMethods, jFuncs)
 
 	jobMeta := rt.NewTable()
 	jobIndex := func(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
@@ -347,7 +342,7 @@ func (j *jobHandler) loader(rtm *rt.Runtime) *rt.Table {
 	}
 
 	jobMeta.Set(rt.StringValue("__index"), rt.FunctionValue(rt.NewGoFunction(jobIndex, "__index", 2, false)))
-	l.SetRegistry(jobMetaKey, rt.TableValue(jobMeta))
+	rtm.SetRegistry(jobMetaKey, rt.TableValue(jobMeta))
 
 	jobFuncs := map[string]util.LuaExport{
 		"all": {j.luaAllJobs, 0, false},
@@ -382,8 +377,8 @@ func valueToJob(val rt.Value) (*job, bool) {
 	return j, ok
 }
 
-func jobUserData(j *job) *rt.UserData {
-	jobMeta := l.Registry(jobMetaKey)
+func jobUserData(rtm *rt.Runtime, j *job) *rt.UserData {
+	jobMeta := rtm.Registry(jobMetaKey)
 	return rt.NewUserData(j, jobMeta.AsTable())
 }
 
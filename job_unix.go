@@ -1,6 +1,6 @@
 // +build darwin linux
 
-package main
+package hilbish
 
 import (
 	"errors"
@@ -11,7 +11,7 @@ import (
 )
 
 func (j *job) foreground() error {
-	if jobs.foreground {
+	if j.handler.foreground {
 		return errors.New("(another) job already foregrounded")
 	}
 
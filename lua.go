@@ -1,8 +1,10 @@
-package main
+package hilbish
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"hilbish/util"
 	"hilbish/golibs/bait"
@@ -13,19 +15,27 @@ import (
 	rt "github.com/arnodel/golua/runtime"
 	"github.com/arnodel/golua/lib"
 	"github.com/arnodel/golua/lib/debuglib"
+	"github.com/arnodel/golua/lib/packagelib"
 )
 
 var minimalconf = `hilbish.prompt '& '`
 
-func luaInit() {
-	l = rt.New(os.Stdout)
+func (sh *Shell) luaInit() {
+	l := rt.New(os.Stdout)
+	sh.runtime = l
+	if sh.chunkCacheDir != "" {
+		util.EnableChunkCache(l, sh.chunkCacheDir, Version())
+	}
 	l.PushContext(rt.RuntimeContextDef{
 		MessageHandler: debuglib.Traceback,
 	})
 	lib.LoadAll(l)
 	setupSinkType(l)
 
-	lib.LoadLibs(l, hilbishLoader)
+	lib.LoadLibs(l, packagelib.Loader{
+		Load: sh.hilbishLoad,
+		Name: "hilbish",
+	})
 	// yes this is stupid, i know
 	util.DoString(l, "hilbish = require 'hilbish'")
 
@@ -33,26 +43,19 @@ func luaInit() {
 	lib.LoadLibs(l, fs.Loader)
 	lib.LoadLibs(l, terminal.Loader)
 
-	cmds = commander.New(l)
-	lib.LoadLibs(l, cmds.Loader)
+	sh.cmds = commander.New(l)
+	lib.LoadLibs(l, sh.cmds.Loader)
 
-	hooks = bait.New(l)
-	hooks.SetRecoverer(func(event string, handler *bait.Listener, err interface{}) {
+	sh.hooks = bait.New(l)
+	sh.hooks.SetRecoverer(func(event string, handler *bait.Listener, err interface{}) {
 		fmt.Println("Error in `error` hook handler:", err)
-		hooks.Off(event, handler)
+		sh.hooks.Off(event, handler)
 	})
 
-	lib.LoadLibs(l, hooks.Loader)
-
-	// Add Ctrl-C handler
-	hooks.On("signal.sigint", func(...interface{}) {
-		if !interactive {
-			os.Exit(0)
-		}
-	})
+	lib.LoadLibs(l, sh.hooks.Loader)
 
-	lr.rl.RawInputCallback = func(r []rune) {
-		hooks.Emit("hilbish.rawInput", string(r))
+	sh.lr.rl.RawInputCallback = func(r []rune) {
+		sh.hooks.Emit("hilbish.rawInput", string(r))
 	}
 
 	// Add more paths that Lua can require from
@@ -61,24 +64,118 @@ func luaInit() {
 		fmt.Fprintln(os.Stderr, "Could not add Hilbish require paths! Libraries will be missing. This shouldn't happen.")
 	}
 
-	err1 := util.DoFile(l, "nature/init.lua")
-	if err1 != nil {
-		err2 := util.DoFile(l, preloadPath)
-		if err2 != nil {
-			fmt.Fprintln(os.Stderr, "Missing nature module, some functionality and builtins will be missing.")
-			fmt.Fprintln(os.Stderr, "local error:", err1)
-			fmt.Fprintln(os.Stderr, "global install error:", err2)
-		}
+	searchers := l.GlobalEnv().Get(rt.StringValue("package")).AsTable().Get(rt.StringValue("searchers")).AsTable()
+	// Replace the Lua file searcher with one that uses the chunk cache
+	searchers.Set(rt.IntValue(2), rt.FunctionValue(rt.NewGoFunction(sh.fileSearcher, "fileSearcher", 1, false)))
+	// Search for modules built into the binary last, so ones on disk override them
+	searchers.Set(rt.IntValue(searchers.Len() + 1), rt.FunctionValue(rt.NewGoFunction(sh.dataSearcher, "dataSearcher", 1, false)))
+
+	err = sh.doFile("nature/init.lua")
+	if errors.Is(err, os.ErrNotExist) {
+		// not running from Hilbish's git, use the installed or builtin nature
+		err = sh.doDataFile(preloadPath, "nature/init.lua")
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Could not load the nature module, some functionality and builtins will be missing.")
+		fmt.Fprintln(os.Stderr, err)
 	}
 }
 
-func runConfig(confpath string) {
-	if !interactive {
+func (sh *Shell) runConfig(confpath string) {
+	l := sh.runtime
+	if !sh.interactive {
 		return
 	}
-	err := util.DoFile(l, confpath)
+	var err error
+	if confpath == sampleConfPath {
+		err = sh.doDataFile(sampleConfPath, ".hilbishrc.lua")
+	} else {
+		err = sh.doFile(confpath)
+	}
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err, "\nAn error has occured while loading your config! Falling back to minimal default config.")
 		util.DoString(l, minimalconf)
 	}
 }
+
+// doFile runs the Lua file at path, like util.DoFile,
+// and adds it to the startup profile.
+func (sh *Shell) doFile(path string) error {
+	// don't add files that don't exist to the profile
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	done := sh.profile.track(path)
+	chunk, cached, err := util.LoadFile(sh.runtime, path)
+	if err != nil {
+		done(false)
+		return err
+	}
+
+	_, err = rt.Call1(sh.runtime.MainThread(), rt.FunctionValue(chunk))
+	done(cached)
+
+	return err
+}
+
+// fileSearcher is a package searcher for Lua files in package.path.
+// It works like the default one, but loads files through the chunk cache.
+func (sh *Shell) fileSearcher(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+	if err := c.Check1Arg(); err != nil {
+		return nil, err
+	}
+	name, err := c.StringArg(0)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg := t.Runtime.GlobalEnv().Get(rt.StringValue("package")).AsTable()
+	searchPath, ok := pkg.Get(rt.StringValue("path")).TryString()
+	if !ok {
+		return nil, errors.New("package.path must be a string")
+	}
+
+	namePath := strings.ReplaceAll(name, ".", "/")
+	templates := strings.Split(searchPath, ";")
+	tried := make([]string, 0, len(templates))
+	for _, template := range templates {
+		path := strings.ReplaceAll(template, "?", namePath)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			loader := rt.NewGoFunction(sh.fileLoader, "fileLoader", 2, false)
+			return c.PushingNext(t.Runtime, rt.FunctionValue(loader), rt.StringValue(path)), nil
+		}
+		tried = append(tried, path)
+	}
+
+	return c.PushingNext1(t.Runtime, rt.StringValue(strings.Join(tried, "\n"))), nil
+}
+
+func (sh *Shell) fileLoader(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+	if err := c.CheckNArgs(2); err != nil {
+		return nil, err
+	}
+	name, err := c.StringArg(0)
+	if err != nil {
+		return nil, err
+	}
+	path, err := c.StringArg(1)
+	if err != nil {
+		return nil, err
+	}
+
+	done := sh.profile.track(name)
+	chunk, cached, err := util.LoadFile(t.Runtime, path)
+	if err != nil {
+		done(false)
+		return nil, fmt.Errorf("error loading file: %s", err)
+	}
+
+	mod, err := rt.Call1(t, rt.FunctionValue(chunk), c.Arg(0), c.Arg(1))
+	done(cached)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.PushingNext1(t.Runtime, mod), nil
+}
@@ -0,0 +1,357 @@
+package hilbish
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"hilbish/util"
+
+	rt "github.com/arnodel/golua/runtime"
+)
+
+// a string key being typed in brackets, like `t["ke`
+var rxLuaStringKey = regexp.MustCompile(`\[\s*(["'])([^"'\\]*)$`)
+
+var luaKeywords = map[string]bool{
+	"and": true, "break": true, "do": true, "else": true, "elseif": true,
+	"end": true, "false": true, "for": true, "function": true, "goto": true,
+	"if": true, "in": true, "local": true, "nil": true, "not": true, "or": true,
+	"repeat": true, "return": true, "then": true, "true": true, "until": true,
+	"while": true,
+}
+
+// luaSegment is a step of a Lua expression being completed, like
+// `hilbish`, `.jobs`, `["key"]` or the `'fs'` of `require 'fs'`.
+type luaSegment struct {
+	key rt.Value
+	call bool // called with key as the argument
+}
+
+// luaAccess is what is being completed at the end of Lua input:
+// a global name, or a key of the value of chain.
+type luaAccess struct {
+	chain []luaSegment
+	access byte // '.', ':', '[' or 0 for a global
+	quote byte // quote of a string key in brackets
+	partial string // what's typed of the name or key
+}
+
+// parseLuaAccess finds what is being completed at the end of ctx.
+// It is false if the end of ctx can't be completed as Lua.
+func parseLuaAccess(ctx string) (luaAccess, bool) {
+	var a luaAccess
+	if m := rxLuaStringKey.FindStringSubmatchIndex(ctx); m != nil {
+		a.access = '['
+		a.quote = ctx[m[2]]
+		a.partial = ctx[m[4]:m[5]]
+		chain, ok := parseLuaChain(ctx[:m[0]])
+		a.chain = chain
+		return a, ok
+	}
+
+	i := len(ctx)
+	for i > 0 && isLuaNameByte(ctx[i - 1]) {
+		i--
+	}
+	a.partial = ctx[i:]
+	if a.partial != "" && isDigit(a.partial[0]) {
+		return a, false
+	}
+
+	if i > 0 && (ctx[i - 1] == '.' || ctx[i - 1] == ':') {
+		// `..` is concatenation
+		if i > 1 && ctx[i - 2] == '.' {
+			return a, false
+		}
+		a.access = ctx[i - 1]
+		chain, ok := parseLuaChain(ctx[:i - 1])
+		a.chain = chain
+		return a, ok
+	}
+
+	return a, true
+}
+
+// parseLuaChain parses the expression at the end of s, going back from
+// the end. Only names, indexes with literals and calls with a single
+// string argument are understood.
+func parseLuaChain(s string) ([]luaSegment, bool) {
+	var chain []luaSegment
+	prepend := func(seg luaSegment) {
+		chain = append([]luaSegment{seg}, chain...)
+	}
+
+	i := len(s)
+	for {
+		if i == 0 {
+			return nil, false
+		}
+
+		switch s[i - 1] {
+		case ']':
+			open := matchingOpen(s, i - 1, '[', ']')
+			if open == -1 {
+				return nil, false
+			}
+			key, ok := luaLiteral(strings.TrimSpace(s[open + 1:i - 1]))
+			if !ok {
+				return nil, false
+			}
+			prepend(luaSegment{key: key})
+			i = open
+
+		case ')':
+			open := matchingOpen(s, i - 1, '(', ')')
+			if open == -1 {
+				return nil, false
+			}
+			arg, ok := luaLiteral(strings.TrimSpace(s[open + 1:i - 1]))
+			if _, isStr := arg.TryString(); !ok || !isStr {
+				return nil, false
+			}
+			prepend(luaSegment{key: arg, call: true})
+			i = len(strings.TrimRight(s[:open], " \t"))
+
+		case '"', '\'':
+			open := strings.LastIndexByte(s[:i - 1], s[i - 1])
+			if open == -1 {
+				return nil, false
+			}
+			prepend(luaSegment{key: rt.StringValue(s[open + 1:i - 1]), call: true})
+			i = len(strings.TrimRight(s[:open], " \t"))
+
+		default:
+			j := i
+			for j > 0 && isLuaNameByte(s[j - 1]) {
+				j--
+			}
+			name := s[j:i]
+			if name == "" || isDigit(name[0]) || luaKeywords[name] {
+				return nil, false
+			}
+			prepend(luaSegment{key: rt.StringValue(name)})
+			i = j
+
+			if i > 0 && s[i - 1] == '.' && !(i > 1 && s[i - 2] == '.') {
+				i--
+				continue
+			}
+			// method calls aren't run to complete what they return
+			if i > 0 && s[i - 1] == ':' {
+				return nil, false
+			}
+			return chain, true
+		}
+	}
+}
+
+// matchingOpen returns the index of the bracket that opens
+// the one that closes at end, or -1 if there isn't one.
+func matchingOpen(s string, end int, open, close byte) int {
+	depth := 0
+	for i := end; i >= 0; i-- {
+		switch s[i] {
+		case close:
+			depth++
+		case open:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// luaLiteral returns the value of a string or integer literal.
+func luaLiteral(s string) (rt.Value, bool) {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s) - 1] == s[0] {
+		inner := s[1:len(s) - 1]
+		if strings.ContainsAny(inner, "\\\"'") {
+			return rt.NilValue, false
+		}
+		return rt.StringValue(inner), true
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return rt.IntValue(n), true
+	}
+
+	return rt.NilValue, false
+}
+
+func isLuaNameByte(b byte) bool {
+	return b == '_' || isDigit(b) || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+// luaEvalChain gets the value of a parsed expression, along with its
+// dotted path (like `hilbish.jobs`) if it has one, to find its docs.
+// No Lua code is run: `require` calls only get modules already loaded,
+// and fields are looked up with luaRawIndex.
+func (sh *Shell) luaEvalChain(chain []luaSegment) (rt.Value, string, bool) {
+	globals := rt.TableValue(sh.runtime.GlobalEnv())
+	val := globals
+	path := ""
+
+	for i, seg := range chain {
+		if val.IsNil() {
+			return rt.NilValue, "", false
+		}
+
+		if seg.call {
+			require := sh.luaRawIndex(globals, rt.StringValue("require"))
+			if isRequire, _ := rt.RawEqual(val, require); i != 1 || !isRequire {
+				return rt.NilValue, "", false
+			}
+			pkg := sh.luaRawIndex(globals, rt.StringValue("package"))
+			loaded := sh.luaRawIndex(pkg, rt.StringValue("loaded"))
+			val = sh.luaRawIndex(loaded, seg.key)
+			path = seg.key.AsString()
+			continue
+		}
+
+		val = sh.luaRawIndex(val, seg.key)
+
+		// the path stops at anything but a name
+		name, ok := seg.key.TryString()
+		switch {
+		case ok && i == 0:
+			path = name
+		case ok && path != "":
+			path += "." + name
+		default:
+			path = ""
+		}
+	}
+
+	return val, path, !val.IsNil()
+}
+
+// luaRawIndex gets key from v without running any Lua code. Like luaKeys,
+// it follows the __index tables of metatables (like those of strings and
+// objects), but stops at __index functions, which could do anything.
+func (sh *Shell) luaRawIndex(v rt.Value, key rt.Value) rt.Value {
+	for depth := 0; depth < 10 && !v.IsNil(); depth++ {
+		if tbl, ok := v.TryTable(); ok {
+			if val := tbl.Get(key); !val.IsNil() {
+				return val
+			}
+		}
+
+		meta := sh.runtime.RawMetatable(v)
+		if meta == nil {
+			break
+		}
+		v = meta.Get(rt.StringValue("__index"))
+		if _, ok := v.TryTable(); !ok {
+			break
+		}
+	}
+
+	return rt.NilValue
+}
+
+// luaKeys returns the string keys of v, following the __index tables
+// of its metatables (like those of strings and objects).
+func (sh *Shell) luaKeys(v rt.Value) map[string]rt.Value {
+	keys := map[string]rt.Value{}
+	for depth := 0; depth < 10 && !v.IsNil(); depth++ {
+		if tbl, ok := v.TryTable(); ok {
+			util.ForEach(tbl, func(k rt.Value, val rt.Value) {
+				if name, ok := k.TryString(); ok {
+					if _, seen := keys[name]; !seen {
+						keys[name] = val
+					}
+				}
+			})
+		}
+
+		meta := sh.runtime.RawMetatable(v)
+		if meta == nil {
+			break
+		}
+		v = meta.Get(rt.StringValue("__index"))
+	}
+
+	return keys
+}
+
+// luaComplete returns the completions for Lua at the end of ctx, with
+// descriptions, and what's typed of them. It is false if ctx doesn't
+// end in Lua that can be completed.
+func (sh *Shell) luaComplete(ctx string) ([]string, map[string]string, string, bool) {
+	a, ok := parseLuaAccess(ctx)
+	if !ok {
+		return nil, nil, "", false
+	}
+
+	val := rt.TableValue(sh.runtime.GlobalEnv())
+	path := ""
+	if a.access != 0 {
+		val, path, ok = sh.luaEvalChain(a.chain)
+		if !ok {
+			return nil, nil, "", false
+		}
+	}
+	if _, ok := val.TryString(); ok && a.access == ':' {
+		path = "string"
+	}
+
+	var items []string
+	descriptions := map[string]string{}
+	for name, v := range sh.luaKeys(val) {
+		if !strings.HasPrefix(name, a.partial) {
+			continue
+		}
+
+		item := name
+		switch a.access {
+		case '[':
+			if strings.IndexByte(name, a.quote) != -1 || strings.Contains(name, "\\") {
+				continue
+			}
+			item = name + string(a.quote) + "]"
+		case ':':
+			if v.Type() != rt.FunctionType {
+				continue
+			}
+			fallthrough
+		default:
+			if !rxLuaName.MatchString(name) || luaKeywords[name] {
+				continue
+			}
+		}
+
+		items = append(items, item)
+		descriptions[item] = sh.luaDescription(path, name, v)
+	}
+	sort.Strings(items)
+
+	return items, descriptions, a.partial, true
+}
+
+// luaDescription describes a value for completion: the signature
+// of documented functions, or the type of anything else.
+func (sh *Shell) luaDescription(path, name string, v rt.Value) string {
+	if v.Type() != rt.FunctionType {
+		return v.TypeName()
+	}
+
+	if path != "" {
+		sigs := sh.luaSignatures()
+		if sig, ok := sigs[path + "." + name]; ok {
+			return name + sig.args()
+		}
+		if sig, ok := sigs[path + ":" + name]; ok {
+			return name + sig.args()
+		}
+	}
+
+	return "function"
+}
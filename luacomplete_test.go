@@ -0,0 +1,156 @@
+package hilbish
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	rt "github.com/arnodel/golua/runtime"
+)
+
+// chainString writes a chain like the Lua it was parsed from,
+// with keys after dots, numbers in brackets and calls in parentheses.
+func chainString(chain []luaSegment) string {
+	var sb strings.Builder
+	for i, seg := range chain {
+		switch {
+		case seg.call:
+			fmt.Fprintf(&sb, "(%q)", seg.key.AsString())
+		case seg.key.Type() == rt.StringType:
+			if i != 0 {
+				sb.WriteString(".")
+			}
+			sb.WriteString(seg.key.AsString())
+		default:
+			fmt.Fprintf(&sb, "[%d]", seg.key.AsInt())
+		}
+	}
+
+	return sb.String()
+}
+
+func TestParseLuaAccess(t *testing.T) {
+	type TestParseLuaAccessT struct {
+		Ctx string
+		Chain string
+		Access byte
+		Quote byte
+		Partial string
+		Fail bool
+	}
+
+	tests := []TestParseLuaAccessT{
+		{Ctx: "", Partial: ""},
+		{Ctx: "hil", Partial: "hil"},
+		{Ctx: "print(hil", Partial: "hil"},
+		{Ctx: "hilbish.jo", Chain: "hilbish", Access: '.', Partial: "jo"},
+		{Ctx: "x = hilbish.jobs.", Chain: "hilbish.jobs", Access: '.', Partial: ""},
+		{Ctx: "s:up", Chain: "s", Access: ':', Partial: "up"},
+		{Ctx: "t[1].na", Chain: "t[1]", Access: '.', Partial: "na"},
+		{Ctx: `t["ke`, Chain: "t", Access: '[', Quote: '"', Partial: "ke"},
+		{Ctx: `t[ 'a'][ 'b`, Chain: "t.a", Access: '[', Quote: '\'', Partial: "b"},
+		{Ctx: "require 'fs'.re", Chain: `require("fs")`, Access: '.', Partial: "re"},
+		{Ctx: `require("fs").re`, Chain: `require("fs")`, Access: '.', Partial: "re"},
+		{Ctx: `require ( "fs" ).`, Chain: `require("fs")`, Access: '.', Partial: ""},
+		// concatenation, numbers and expressions that would have to be run
+		{Ctx: "a..b", Fail: true},
+		{Ctx: "x.1", Fail: true},
+		{Ctx: "1", Fail: true},
+		{Ctx: "f(x).y", Fail: true},
+		{Ctx: "obj:method().x", Fail: true},
+		{Ctx: "a:b.c", Fail: true},
+		{Ctx: "end.x", Fail: true},
+		{Ctx: "(a).b", Fail: true},
+		{Ctx: `t["a\"b"].c`, Fail: true},
+		{Ctx: "t[x].y", Fail: true},
+	}
+
+	for _, test := range tests {
+		a, ok := parseLuaAccess(test.Ctx)
+		if test.Fail {
+			if ok {
+				t.Errorf("%q: expected it not to complete, got %+v", test.Ctx, a)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("%q: expected it to complete", test.Ctx)
+			continue
+		}
+
+		chain := chainString(a.chain)
+		if chain != test.Chain || a.access != test.Access || a.quote != test.Quote || a.partial != test.Partial {
+			t.Errorf("%q: expected chain %q, access %q, quote %q and partial %q, got %q, %q, %q and %q",
+			test.Ctx, test.Chain, test.Access, test.Quote, test.Partial, chain, a.access, a.quote, a.partial)
+		}
+	}
+}
+
+func TestLuaEvalChain(t *testing.T) {
+	sh := &Shell{runtime: rt.New(nil)}
+	globals := sh.runtime.GlobalEnv()
+
+	called := false
+	index := rt.FunctionValue(rt.NewGoFunction(func(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+		called = true
+		return c.PushingNext1(t.Runtime, rt.TableValue(rt.NewTable())), nil
+	}, "__index", 2, false))
+	withIndex := func(idx rt.Value) *rt.Table {
+		meta := rt.NewTable()
+		meta.Set(rt.StringValue("__index"), idx)
+		return meta
+	}
+
+	// like in nature/init.lua, globals set by the user are in another table
+	user := rt.NewTable()
+	user.Set(rt.StringValue("name"), rt.StringValue("hilbish"))
+	virt := rt.NewTable()
+	virt.Set(rt.StringValue("user"), rt.TableValue(user))
+	sh.runtime.SetRawMetatable(rt.TableValue(virt), withIndex(index))
+	sh.runtime.SetRawMetatable(rt.TableValue(globals), withIndex(rt.TableValue(virt)))
+
+	lazy := rt.NewTable()
+	sh.runtime.SetRawMetatable(rt.TableValue(lazy), withIndex(index))
+	globals.Set(rt.StringValue("lazy"), rt.TableValue(lazy))
+
+	mod := rt.NewTable()
+	loaded := rt.NewTable()
+	loaded.Set(rt.StringValue("mod"), rt.TableValue(mod))
+	pkg := rt.NewTable()
+	pkg.Set(rt.StringValue("loaded"), rt.TableValue(loaded))
+	globals.Set(rt.StringValue("package"), rt.TableValue(pkg))
+	globals.Set(rt.StringValue("require"), index)
+
+	type TestLuaEvalChainT struct {
+		Ctx string
+		Expected rt.Value
+		Path string
+	}
+
+	tests := []TestLuaEvalChainT{
+		{Ctx: "user.", Expected: rt.TableValue(user), Path: "user"},
+		{Ctx: "user.name:", Expected: rt.StringValue("hilbish"), Path: "user.name"},
+		{Ctx: "require 'mod'.", Expected: rt.TableValue(mod), Path: "mod"},
+		{Ctx: "lazy.", Expected: rt.TableValue(lazy), Path: "lazy"},
+		// __index functions aren't called
+		{Ctx: "lazy.field.", Expected: rt.NilValue},
+		{Ctx: "nope.", Expected: rt.NilValue},
+		{Ctx: "require 'other'.", Expected: rt.NilValue},
+	}
+
+	for _, test := range tests {
+		a, ok := parseLuaAccess(test.Ctx)
+		if !ok {
+			t.Errorf("%q: expected it to parse", test.Ctx)
+			continue
+		}
+
+		val, path, ok := sh.luaEvalChain(a.chain)
+		if ok != !test.Expected.IsNil() || val != test.Expected || (ok && path != test.Path) {
+			t.Errorf("%q: expected %v at %q, got %v at %q", test.Ctx, test.Expected, test.Path, val, path)
+		}
+	}
+	if called {
+		t.Error("an __index function was called")
+	}
+}
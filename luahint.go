@@ -0,0 +1,300 @@
+package hilbish
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	rt "github.com/arnodel/golua/runtime"
+)
+
+// the name of a function being defined, like `function foo.bar`
+var rxLuaFuncDef = regexp.MustCompile(`(^|[^\w])function\s+[\w.:]+$`)
+
+// luaSignature is how a Lua function is called, to show as a hint.
+// The ones of the Hilbish API and nature are generated by docgen, in
+// emmyLuaDocs/signatures.json.
+type luaSignature struct {
+	Signature string `json:"signature"`
+	Description string `json:"description"`
+	Params []luaParam `json:"params"`
+}
+
+type luaParam struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Doc string `json:"doc"`
+}
+
+// args returns the part of the signature in parentheses.
+func (s luaSignature) args() string {
+	if i := strings.IndexByte(s.Signature, '('); i != -1 {
+		return s.Signature[i:]
+	}
+
+	return "()"
+}
+
+// param returns the parameter at idx, which is the last one if it takes
+// any number of arguments.
+func (s luaSignature) param(idx int) (luaParam, bool) {
+	if len(s.Params) == 0 {
+		return luaParam{}, false
+	}
+	if idx >= len(s.Params) {
+		last := s.Params[len(s.Params) - 1]
+		if !strings.HasPrefix(last.Type, "...") && !strings.HasPrefix(last.Name, "...") {
+			return luaParam{}, false
+		}
+		return last, true
+	}
+
+	return s.Params[idx], true
+}
+
+// luaSignatures returns the signatures of Lua functions by their full
+// names (like `hilbish.run`). They're read from the docgen output the
+// first time they're needed.
+func (sh *Shell) luaSignatures() map[string]luaSignature {
+	if sh.luaSigs != nil {
+		return sh.luaSigs
+	}
+	sh.luaSigs = map[string]luaSignature{}
+
+	if data, err := readData("emmyLuaDocs/signatures.json"); err == nil {
+		json.Unmarshal(data, &sh.luaSigs)
+	}
+
+	return sh.luaSigs
+}
+
+// parseLuaCall finds the innermost call that ctx ends inside of.
+// It returns the index of the opening parenthesis and the index of
+// the argument being typed.
+func parseLuaCall(ctx string) (int, int, bool) {
+	type open struct {
+		pos int
+		char byte
+		commas int
+	}
+	var stack []open
+
+	for i := 0; i < len(ctx); i++ {
+		switch c := ctx[i]; c {
+		case '"', '\'':
+			// an unfinished string is the argument being typed
+			i++
+			for i < len(ctx) && ctx[i] != c {
+				if ctx[i] == '\\' {
+					i++
+				}
+				i++
+			}
+		case '-':
+			if i + 1 < len(ctx) && ctx[i + 1] == '-' {
+				for i < len(ctx) && ctx[i] != '\n' {
+					i++
+				}
+			}
+		case '(', '{', '[':
+			stack = append(stack, open{pos: i, char: c})
+		case ')', '}', ']':
+			if len(stack) != 0 {
+				stack = stack[:len(stack) - 1]
+			}
+		case ',':
+			if len(stack) != 0 {
+				stack[len(stack) - 1].commas++
+			}
+		}
+	}
+
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i].char == '(' {
+			return stack[i].pos, stack[i].commas, true
+		}
+	}
+
+	return 0, 0, false
+}
+
+// luaCallName returns the full name of the function called by fn,
+// the Lua before the parenthesis of a call.
+func (sh *Shell) luaCallName(fn string) (string, bool) {
+	fn = strings.TrimRight(fn, " \t")
+	if rxLuaFuncDef.MatchString(fn) {
+		return "", false
+	}
+
+	i := len(fn)
+	for i > 0 && isLuaNameByte(fn[i - 1]) {
+		i--
+	}
+	name := fn[i:]
+	if name == "" || isDigit(name[0]) || luaKeywords[name] {
+		return "", false
+	}
+
+	if i > 0 && fn[i - 1] == ':' {
+		chain, ok := parseLuaChain(fn[:i - 1])
+		if !ok {
+			return "", false
+		}
+		val, path, ok := sh.luaEvalChain(chain)
+		if !ok {
+			return "", false
+		}
+		if _, ok := val.TryString(); ok {
+			return "string." + name, true
+		}
+		if path == "" {
+			return "", false
+		}
+		return path + ":" + name, true
+	}
+
+	chain, ok := parseLuaChain(fn)
+	if !ok {
+		return "", false
+	}
+	if _, path, ok := sh.luaEvalChain(chain); ok && path != "" {
+		return path, true
+	}
+
+	// a function that isn't set (yet) still has its docs
+	names := make([]string, len(chain))
+	for i, seg := range chain {
+		s, ok := seg.key.TryString()
+		if seg.call || !ok || !rxLuaName.MatchString(s) {
+			return "", false
+		}
+		names[i] = s
+	}
+	return strings.Join(names, "."), true
+}
+
+// luaSignatureHint returns the hint for a call to a known function
+// that ctx ends inside of: its signature, with the argument being typed
+// marked, and the docs of that argument.
+func (sh *Shell) luaSignatureHint(ctx string) string {
+	open, arg, ok := parseLuaCall(ctx)
+	if !ok {
+		return ""
+	}
+	name, ok := sh.luaCallName(ctx[:open])
+	if !ok {
+		return ""
+	}
+	sig, ok := sh.luaSignatures()[name]
+	if !ok {
+		return ""
+	}
+
+	hint := sig.Signature
+	p, ok := sig.param(arg)
+	if !ok {
+		if len(sig.Params) == 0 && sig.Description != "" {
+			hint += " " + sig.Description
+		}
+		return "  -- " + hint
+	}
+
+	// mark the argument in the signature's parameters
+	if i := strings.IndexByte(hint, '('); i != -1 {
+		params := strings.Split(strings.TrimSuffix(hint[i + 1:], ")"), ",")
+		idx := arg
+		if idx >= len(params) {
+			idx = len(params) - 1
+		}
+		p := strings.TrimSpace(params[idx])
+		params[idx] = strings.Replace(params[idx], p, "<" + p + ">", 1)
+		hint = hint[:i + 1] + strings.Join(params, ",") + ")"
+	}
+
+	if p.Type != "" || p.Doc != "" {
+		hint += " " + strings.TrimSuffix(p.Name, "?") + ":"
+		if p.Type != "" {
+			hint += " " + p.Type
+		}
+		if p.Doc != "" {
+			hint += " " + p.Doc
+		}
+	}
+	return "  -- " + hint
+}
+
+// luaInputMaybe reports whether line may be run as Lua, by the runner
+// it's routed to or the runner mode.
+func (sh *Shell) luaInputMaybe(line string) bool {
+	if name, _, routed := sh.resolveRunner(line); routed {
+		return name != "sh"
+	}
+	mode, _ := sh.runnerMode.TryString()
+
+	return mode != "sh"
+}
+
+// signature(name, info)
+// Adds the signature of a Lua function, or replaces the one it has.
+// When the cursor is in a call to the function at the prompt, the
+// signature is shown as a hint, along with the docs of the argument being
+// typed. Signatures are also used to describe functions in Lua completion.
+// The functions of the Hilbish API already have signatures, generated
+// from their docs.
+// #param name string Full name of the function, like `greet.hello`. Methods are named like `greet.Greeter:hello`.
+// #param info table Table with the `params` of the function, a list of tables with their `name`, `type` and `doc`, and a `description`. A `signature` can be set to show instead of one made from the name and params.
+/*
+#example
+hilbish.signature('greet.hello', {
+	description = 'Says hello to someone.',
+	params = {
+		{name = 'name', type = 'string', doc = 'Who to greet'},
+		{name = 'loud', type = 'boolean', doc = 'Whether to shout it'}
+	}
+})
+-- typing `greet.hello('Sammy', ` will hint:
+-- greet.hello(name, <loud>) loud: boolean Whether to shout it
+#example
+*/
+func (sh *Shell) hlsignature(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+	if err := c.CheckNArgs(2); err != nil {
+		return nil, err
+	}
+	name, err := c.StringArg(0)
+	if err != nil {
+		return nil, err
+	}
+	info, err := c.TableArg(1)
+	if err != nil {
+		return nil, err
+	}
+
+	var sig luaSignature
+	sig.Description, _ = info.Get(rt.StringValue("description")).TryString()
+	if params, ok := info.Get(rt.StringValue("params")).TryTable(); ok {
+		var names []string
+		for i := int64(1); i <= params.Len(); i++ {
+			v := params.Get(rt.IntValue(i))
+			var p luaParam
+			if name, ok := v.TryString(); ok {
+				p.Name = name
+			} else if tbl, ok := v.TryTable(); ok {
+				p.Name, _ = tbl.Get(rt.StringValue("name")).TryString()
+				p.Type, _ = tbl.Get(rt.StringValue("type")).TryString()
+				p.Doc, _ = tbl.Get(rt.StringValue("doc")).TryString()
+			}
+			sig.Params = append(sig.Params, p)
+			names = append(names, p.Name)
+		}
+		sig.Signature = name + "(" + strings.Join(names, ", ") + ")"
+	}
+	if s, ok := info.Get(rt.StringValue("signature")).TryString(); ok {
+		sig.Signature = s
+	} else if sig.Signature == "" {
+		sig.Signature = name + "()"
+	}
+
+	sh.luaSignatures()[name] = sig
+
+	return c.Next(), nil
+}
@@ -0,0 +1,90 @@
+package hilbish
+
+import (
+	"testing"
+)
+
+func TestParseLuaCall(t *testing.T) {
+	type TestParseLuaCallT struct {
+		Ctx string
+		Open int
+		Arg int
+		Fail bool
+	}
+
+	tests := []TestParseLuaCallT{
+		{Ctx: "print(", Open: 5, Arg: 0},
+		{Ctx: "hilbish.run('ls', ", Open: 11, Arg: 1},
+		{Ctx: "f(a, g(b, ", Open: 6, Arg: 1},
+		{Ctx: "f(a, g(b), ", Open: 1, Arg: 2},
+		{Ctx: "f('a, b', ", Open: 1, Arg: 1},
+		{Ctx: `f("a \" (", `, Open: 1, Arg: 1},
+		{Ctx: "f({1, 2}, ", Open: 1, Arg: 1},
+		{Ctx: "f({1, ", Open: 1, Arg: 0},
+		{Ctx: "t[f(", Open: 3, Arg: 0},
+		{Ctx: "f(a)[1](", Open: 7, Arg: 0},
+		// an unfinished string is the argument being typed
+		{Ctx: "f('unclosed (", Open: 1, Arg: 0},
+		{Ctx: "f(1, -2, ", Open: 1, Arg: 2},
+		{Ctx: "f(1, -- note\n", Open: 1, Arg: 1},
+		{Ctx: "f(x) ", Fail: true},
+		{Ctx: "-- f(", Fail: true},
+		{Ctx: "{a, ", Fail: true},
+		{Ctx: "", Fail: true},
+	}
+
+	for _, test := range tests {
+		open, arg, ok := parseLuaCall(test.Ctx)
+		if test.Fail {
+			if ok {
+				t.Errorf("%q: expected no call, got one at %d", test.Ctx, open)
+			}
+			continue
+		}
+		if !ok || open != test.Open || arg != test.Arg {
+			t.Errorf("%q: expected a call at %d with argument %d, got one at %d with argument %d (%v)", test.Ctx, test.Open, test.Arg, open, arg, ok)
+		}
+	}
+}
+
+func TestLuaSignatureParam(t *testing.T) {
+	sig := luaSignature{
+		Signature: "f(a, b)",
+		Params: []luaParam{{Name: "a"}, {Name: "b"}},
+	}
+	varargs := luaSignature{
+		Signature: "g(a, ...)",
+		Params: []luaParam{{Name: "a"}, {Name: "...", Type: "any"}},
+	}
+	typedVarargs := luaSignature{
+		Signature: "h(a, args)",
+		Params: []luaParam{{Name: "a"}, {Name: "args", Type: "...string"}},
+	}
+
+	type TestLuaSignatureParamT struct {
+		Sig luaSignature
+		Idx int
+		Expected string
+		Found bool
+	}
+
+	tests := []TestLuaSignatureParamT{
+		{Sig: sig, Idx: 0, Expected: "a", Found: true},
+		{Sig: sig, Idx: 1, Expected: "b", Found: true},
+		{Sig: sig, Idx: 2, Found: false},
+		{Sig: varargs, Idx: 5, Expected: "...", Found: true},
+		{Sig: typedVarargs, Idx: 3, Expected: "args", Found: true},
+		{Sig: luaSignature{Signature: "n()"}, Idx: 0, Found: false},
+	}
+
+	for _, test := range tests {
+		p, ok := test.Sig.param(test.Idx)
+		if ok != test.Found || p.Name != test.Expected {
+			t.Errorf("%s %d: expected %q (%v), got %q (%v)", test.Sig.Signature, test.Idx, test.Expected, test.Found, p.Name, ok)
+		}
+	}
+
+	if args := sig.args(); args != "(a, b)" {
+		t.Errorf("expected the args of %s to be (a, b), got %s", sig.Signature, args)
+	}
+}
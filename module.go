@@ -1,8 +1,10 @@
-package main
+package hilbish
 
 import (
+	"errors"
 	"plugin"
 
+	"hilbish/moduleapi"
 	"hilbish/util"
 
 	rt "github.com/arnodel/golua/runtime"
@@ -45,10 +47,37 @@ func Loader(rtm *rt.Runtime) rt.Value {
 
 This can be compiled with `go build -buildmode=plugin plugin.go`.
 If you attempt to require and print the result (`print(require 'plugin')`), it will show "hello world!"
+
+A plugin can also export a Loader which takes a `moduleapi.Host`
+(from the `hilbish/moduleapi` package) instead of the runtime.
+The host gives access to Hilbish hooks and jobs, and allows
+registering commanders, completers and runners from Go:
+```go
+package main
+
+import (
+	"io"
+
+	"hilbish/moduleapi"
+
+	rt "github.com/arnodel/golua/runtime"
+)
+
+func Loader(h moduleapi.Host) rt.Value {
+	h.RegisterCommand("hello", func(args []string, in io.Reader, out, err io.Writer) uint8 {
+		io.WriteString(out, "hello world!\n")
+		return 0
+	})
+
+	return rt.NilValue
+}
+```
+
+If the plugin's Loader has any other signature, loading it throws an error.
 */
-func moduleLoader(rtm *rt.Runtime) *rt.Table {
+func (sh *Shell) moduleLoader(rtm *rt.Runtime) *rt.Table {
 	exports := map[string]util.LuaExport{
-		"load": {moduleLoad, 2, false},
+		"load": {sh.moduleLoad, 2, false},
 	}
 
 	mod := rt.NewTable()
@@ -62,7 +91,7 @@ func moduleLoader(rtm *rt.Runtime) *rt.Table {
 // Loads a module at the designated `path`.
 // It will throw if any error occurs.
 // #param path string 
-func moduleLoad(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+func (sh *Shell) moduleLoad(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	if err := c.CheckNArgs(1); err != nil {
 		return nil, err
 	}
@@ -82,12 +111,12 @@ func moduleLoad(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 		return nil, err
 	}
 
-	loader, ok := value.(func(*rt.Runtime) rt.Value)
-	if !ok {
-		return nil, nil
+	var val rt.Value
+	switch loader := value.(type) {
+		case func(*rt.Runtime) rt.Value: val = loader(t.Runtime)
+		case func(moduleapi.Host) rt.Value: val = loader(sh)
+		default: return nil, errors.New("module at " + path + " has a Loader with an unknown signature")
 	}
 
-	val := loader(t.Runtime)
-
 	return c.PushingNext1(t.Runtime, val), nil
 }
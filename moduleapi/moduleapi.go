@@ -0,0 +1,142 @@
+// Package moduleapi is the stable interface between Hilbish and native
+// (Go plugin) modules.
+/*
+A native module which exports a Loader function with the signature
+`func(moduleapi.Host) rt.Value` will be handed a Host when it is
+required. The Host gives access to Hilbish's hooks, commanders,
+completers, runners and jobs without needing to look them up in the
+`hilbish` Lua table.
+
+```go
+package main
+
+import (
+	"io"
+
+	"hilbish/moduleapi"
+
+	rt "github.com/arnodel/golua/runtime"
+)
+
+func Loader(h moduleapi.Host) rt.Value {
+	h.RegisterCommand("hello", func(args []string, in io.Reader, out, err io.Writer) uint8 {
+		io.WriteString(out, "hello from go!\n")
+		return 0
+	})
+
+	return rt.NilValue
+}
+```
+*/
+package moduleapi
+
+import (
+	"io"
+
+	"hilbish/golibs/bait"
+
+	rt "github.com/arnodel/golua/runtime"
+)
+
+// Host is the interface Hilbish provides to native modules.
+type Host interface {
+	// Runtime returns the Lua runtime used by Hilbish.
+	Runtime() *rt.Runtime
+	// Hooks returns the event emitter Hilbish throws its hooks on.
+	Hooks() *bait.Bait
+	// RegisterCommand adds a commander with the given name.
+	RegisterCommand(name string, cmd Command)
+	// RegisterCompleter adds a completer for a scope, like `command.git`.
+	RegisterCompleter(scope string, completer Completer)
+	// RegisterRunner adds a runner to the list of available runners.
+	// It can then be set with `hilbish.runner.setCurrent`.
+	RegisterRunner(name string, runner Runner) error
+	// Run runs cmd in Hilbish's shell script interpreter and returns its exit code.
+	Run(cmd string) (uint8, error)
+	// Jobs returns the job manager.
+	Jobs() Jobs
+}
+
+// Command is a Go commander. It is passed the arguments (without the
+// command name) and the input and output streams, and returns an exit code.
+type Command func(args []string, stdin io.Reader, stdout, stderr io.Writer) uint8
+
+// Completer is a Go completion handler. It is called with the same
+// parameters a Lua completer gets, and returns the completion groups and prefix.
+type Completer func(query, ctx string, fields []string, comp CompletionContext) ([]CompletionGroup, string)
+
+// CompletionContext is what the cursor is in on the line being completed,
+// which is parsed as shell script. It mirrors the table
+// `hilbish.completion.context` returns.
+type CompletionContext struct {
+	// Command is the command of the simple command the cursor is in, with
+	// commands that run others (like `sudo`) skipped. It is empty if the
+	// cursor isn't in a simple command.
+	Command string
+	// Index is the index of the word being typed in the command, where 0
+	// is the command itself, or -1 if the word isn't an argument.
+	Index int
+	// Args are the words of the command up to the cursor, unquoted.
+	Args []string
+	// Word is the word being typed, as it is typed.
+	Word string
+	// Value is the word unquoted, or the name of the variable after `$`.
+	Value string
+	// Quote is the quote the cursor is in (`'` or `"`), or empty.
+	Quote string
+	// Variable is whether a variable name is being typed after `$`.
+	Variable bool
+	// Redirect is whether the word is the target of a redirection.
+	Redirect bool
+	// Assignment is the name of the variable whose value is being
+	// typed, for `NAME=value`.
+	Assignment string
+}
+
+// CompletionGroup is a group of completion items.
+type CompletionGroup struct {
+	// Type is the display type, either `grid` or `list`.
+	Type string
+	Items []string
+	// Descriptions maps an item to its description. Only used for `list` groups.
+	Descriptions map[string]string
+}
+
+// Runner is a Go runner for interactive input.
+type Runner func(input string) RunnerResult
+
+// RunnerResult is what a Runner returns, it mirrors the table Lua runners return.
+type RunnerResult struct {
+	// Input is the user input, which will be added to history.
+	Input string
+	ExitCode uint8
+	// Err is an error from the runner. It can be in the form of
+	// `cmd: not-found` or `cmd: not-executable` to throw the related hooks.
+	Err error
+	// Continue is whether to prompt the user for more input.
+	Continue bool
+}
+
+// Jobs provides access to Hilbish's background jobs.
+type Jobs interface {
+	// All returns all jobs.
+	All() []Job
+	// Get returns the job with the ID, or nil if it doesn't exist.
+	Get(id int) Job
+	// Last returns the latest added job, or nil if there are none.
+	Last() Job
+	// Add creates a new job. It does not start the job.
+	Add(cmd string, args []string, path string) Job
+}
+
+// Job is a Hilbish job.
+type Job interface {
+	ID() int
+	PID() int
+	// Cmd is the user entered command string for the job.
+	Cmd() string
+	Running() bool
+	ExitCode() int
+	Start() error
+	Stop()
+}
@@ -38,15 +38,35 @@ local function transformHTMLandMD(text)
 	:gsub('%*%*(.-)%*%*', lunacolors.bold)
 end
 
+-- these read a doc file, returning nil if it doesnt exist
+local function readDataDoc(path)
+	local ok, doc = pcall(hilbish.data.read, path)
+	if ok then return doc end
+end
+
+local function readFileDoc(path)
+	local f = io.open(path, 'rb')
+	if not f then return end
+
+	local doc = f:read '*a'
+	f:close()
+	return doc
+end
+
 commander.register('doc', function(args, sinks)
-	local moddocPath = hilbish.dataDir .. '/docs/'
+	-- docs are read from the data dir (or the ones built into hilbish)
+	local moddocPath = 'docs/'
+	local readDoc = readDataDoc
+	local readdir = hilbish.data.readdir
 	local stat = pcall(fs.stat, '.git/refs/heads/extended-job-api')
 	if stat then
 		-- hilbish git
 		moddocPath = './docs/'
+		readDoc = readFileDoc
+		readdir = fs.readdir
 	end
 
-	local modules = table.map(fs.readdir(moddocPath), function(f)
+	local modules = table.map(readdir(moddocPath), function(f)
 		return lunacolors.underline(lunacolors.blue(string.gsub(f, '.md', '')))
 	end)
 	local doc = [[
@@ -88,7 +108,7 @@ Available sections: ]] .. table.concat(modules, ', ')
 	if #args > 0 then
 		local mod = args[1]
 
-		f = io.open(moddocPath .. mod .. '.md', 'rb')
+		f = readDoc(moddocPath .. mod .. '.md')
 		local funcdocs = nil
 		local subdocName = args[2]
 		if not f then
@@ -96,16 +116,16 @@ Available sections: ]] .. table.concat(modules, ', ')
 			if not subdocName then
 				subdocName = '_index'
 			end
-			f = io.open(moddocPath .. subdocName .. '.md', 'rb')
+			f = readDoc(moddocPath .. subdocName .. '.md')
 			local oldmoddocPath = moddocPath
 			if not f then
 				moddocPath = moddocPath .. subdocName:match '%w+' .. '/'
-				f = io.open(moddocPath .. subdocName .. '.md', 'rb')
+				f = readDoc(moddocPath .. subdocName .. '.md')
 			end
 			if not f then
 				moddocPath = oldmoddocPath .. subdocName .. '/'
 				subdocName = args[3] or '_index'
-				f = io.open(moddocPath .. subdocName .. '.md', 'rb')
+				f = readDoc(moddocPath .. subdocName .. '.md')
 			end
 			if not f then
 				sinks.out:writeln('No documentation found for ' .. mod .. '.')
@@ -115,7 +135,7 @@ Available sections: ]] .. table.concat(modules, ', ')
 
 	end
 
-	local moddocs = table.filter(fs.readdir(moddocPath), function(f) return f ~= '_index.md' and f ~= 'index.md' end)
+	local moddocs = table.filter(readdir(moddocPath), function(f) return f ~= '_index.md' and f ~= 'index.md' end)
 	local subdocs = table.map(moddocs, function(fname)
 		return lunacolors.underline(lunacolors.blue(string.gsub(fname, '.md', '')))
 	end)
@@ -164,11 +184,10 @@ Available sections: ]] .. table.concat(modules, ', ')
 	end
 
 
-	local doc, vals = handleYamlInfo(#args == 0 and doc or formatDocText(f:read '*a'))
+	local doc, vals = handleYamlInfo(#args == 0 and doc or formatDocText(f))
 	if #moddocs ~= 0 and f then
 		doc = doc .. '\nSubdocs: ' .. table.concat(subdocs, ', ') .. '\n\n'
 	end
-	if f then f:close() end
 
 	local page = Page(vals.title, doc)
 	page.description = vals.description
@@ -181,8 +200,7 @@ Available sections: ]] .. table.concat(modules, ', ')
 			sdFile = sdName
 		end
 
-		local f = io.open(moddocPath .. sdFile, 'rb')
-		local doc, vals = handleYamlInfo(formatDocText(f:read '*a'))
+		local doc, vals = handleYamlInfo(formatDocText(readDoc(moddocPath .. sdFile)))
 		local page = Page(vals.title or sdName, doc)
 		page.description = vals.description
 		gh:addPage(page)
@@ -0,0 +1,23 @@
+local commander = require 'commander'
+local lunacolors = require 'lunacolors'
+
+commander.register('history', function(args, sinks)
+	if args[1] ~= 'import' or not args[2] then
+		sinks.out:writeln(lunacolors.format [[
+history: manage command history
+
+usage: history import <file> [format]
+
+imports the history of another shell into hilbish's history.
+format can be {green}bash{reset}, {green}zsh{reset} or {green}fish{reset}, and is guessed if not passed]])
+		return args[1] and 1 or 0
+	end
+
+	local ok, res = pcall(hilbish.history.import, args[2], args[3])
+	if not ok then
+		sinks.out:writeln('history: ' .. tostring(res))
+		return 1
+	end
+
+	sinks.out:writeln(string.format('Imported %d commands.', res))
+end)
@@ -9,7 +9,11 @@ local info = debug.getinfo(1)
 local commandDir = fs.dir(info.source)
 if commandDir == '.' then return end
 
-local commands = fs.readdir(commandDir)
+local ok, commands = pcall(fs.readdir, commandDir)
+if not ok then
+	-- not on disk, so we're running the nature built into hilbish
+	commands = hilbish.data.readdir 'nature/commands'
+end
 for _, command in ipairs(commands) do
 	local name = command:gsub('%.lua', '') -- chop off extension
 	if name ~= 'init' then
@@ -1,3 +1,5 @@
+local shellRunners = {sh = true, hybrid = true, hybridRev = true}
+
 function hilbish.completion.handler(line, pos)
 	if type(line) ~= 'string' then error '#1 must be a string' end
 	if type(pos) ~= 'number' then error '#2 must be a number' end
@@ -6,35 +8,64 @@ function hilbish.completion.handler(line, pos)
 	local ctx = line:gsub('^%s*(.-)$', '%1')
 	if ctx:len() == 0 then return {}, '' end
 
-	local res = hilbish.aliases.resolve(ctx)
-	local resFields = string.split(res, ' ')
-	local fields = string.split(ctx, ' ')
-	if #fields > 1 and #resFields > 1 then
-		fields = resFields
+	-- input routed to another runner is completed without its prefix,
+	-- and only as shell commands for the runners that run them
+	local runner, input, routed = hilbish.runner.resolve(ctx)
+	if routed then
+		ctx = input
+	end
+
+	-- Lua is completed as Lua, and so is what looks like it
+	-- (like `hilbish.jo`) in the hybrid runners
+	if runner == 'lua' then
+		local compGroups, pfx = hilbish.completion.lua(ctx)
+		return compGroups or {}, pfx or ''
 	end
-	local query = fields[#fields]
+	if routed and not shellRunners[runner] then return {}, '' end
+	if ctx:len() == 0 then return {}, '' end
+
+	if runner ~= 'sh' and (ctx:match '[%.:][%w_]*$' or ctx:match '%[%s*["\'][^"\']*$') then
+		local compGroups, pfx = hilbish.completion.lua(ctx)
+		if compGroups and #compGroups > 0 then
+			return compGroups, pfx
+		end
+	end
+
+	-- the line is parsed as shell to find the command and word at the cursor
+	local comp = hilbish.completion.context(ctx)
+	local query = comp.word
+	local fields = comp.args
 
-	if #fields == 1 then
+	if comp.variable then
+		local comps, pfx = hilbish.completion.vars(comp.value, ctx, fields)
+		return {{items = comps, type = 'grid'}}, pfx
+	end
+
+	if comp.index == 0 then
 		local comps, pfx = hilbish.completion.bins(query, ctx, fields)
-		local compGroup = {
-			items = comps,
-			type = 'grid'
-		}
+		return {{items = comps, type = 'grid'}}, pfx
+	end
+
+	if comp.index then
+		-- an alias is completed as the command it runs
+		local resFields = string.split(hilbish.aliases.resolve(comp.command), ' ')
+		if resFields[1] ~= comp.command then
+			for i = 2, #fields do
+				table.insert(resFields, fields[i])
+			end
+			comp.index = comp.index + #resFields - #fields
+			comp.command = resFields[1]
+			comp.args = resFields
+			fields = resFields
+		end
 
-		return {compGroup}, pfx
-	else
 		local ok, compGroups, pfx = pcall(hilbish.completion.call,
-		'command.' .. fields[1], query, ctx, fields)
+		'command.' .. comp.command, query, ctx, fields, comp)
 		if ok then
 			return compGroups, pfx
 		end
-
-		local comps, pfx = hilbish.completion.files(query, ctx, fields)
-		local compGroup = {
-			items = comps,
-			type = 'grid'
-		}
-
-		return {compGroup}, pfx
 	end
+
+	local comps, pfx = hilbish.completion.files(query, ctx, fields)
+	return {{items = comps, type = 'grid'}}, pfx
 end
@@ -33,30 +33,31 @@ else
 end
 
 do
-	local virt_G = { }
-
-	setmetatable(_G, {
-		__index = function (_, key)
-			local got_virt = virt_G[key]
-			if got_virt ~= nil then
-				return got_virt
-			end
-
+	-- globals are kept here, and env variables are read into it.
+	-- it's an __index table (not function) so globals can be listed,
+	-- like for Lua completion.
+	local virt_G = setmetatable({}, {
+		__index = function (virt, key)
 			if type(key) == 'string' then
-				virt_G[key] = os.getenv(key)
+				local env = os.getenv(key)
+				rawset(virt, key, env)
+				return env
 			end
-			return virt_G[key]
-		end,
+		end
+	})
+
+	setmetatable(_G, {
+		__index = virt_G,
 
 		__newindex = function (_, key, value)
 			if type(value) == 'string' then
 				os.setenv(key, value)
-				virt_G[key] = value
+				rawset(virt_G, key, value)
 			else
-				if type(virt_G[key]) == 'string' then
+				if type(rawget(virt_G, key)) == 'string' then
 					os.setenv(key, '')
 				end
-				virt_G[key] = value
+				rawset(virt_G, key, value)
 			end
 		end,
 	})
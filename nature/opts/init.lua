@@ -8,12 +8,22 @@ end
 local defaultOpts = {
 	autocd = false,
 	history = true,
+	historyShare = 'shared',
+	historyIgnoreDups = false,
+	historyIgnoreSpace = true,
+	historyIgnore = {},
+	historySize = 0,
+	histExpand = false,
+	autosuggest = true,
 	greeting = string.format([[Welcome to {magenta}Hilbish{reset}, {cyan}%s{reset}.
 The nice lil shell for {blue}Lua{reset} fanatics!
 ]], hilbish.user),
 	motd = true,
 	fuzzy = false,
+	finder = true,
 	notifyJobFinish = true,
+	luaEcho = true,
+	signatureHints = true,
 	crimmas = true
 }
 
@@ -1,6 +1,8 @@
 --- hilbish.runner
+local lunacolors = require 'lunacolors'
 local currentRunner = 'hybrid'
 local runners = {}
+local routes = {}
 
 -- lsp shut up
 hilbish = hilbish
@@ -81,6 +83,108 @@ function hilbish.runner.getCurrent()
 	return currentRunner
 end
 
+--- Routes input to the runner called `runnerName` instead of the current
+--- runner. `match` is a prefix, which is removed from the input before
+--- it's run, or a Lua pattern if it starts with `^`, in which case the
+--- input is run as it is. Routes are checked in the order they were added.
+--- Passing nil as the runner name removes the route.
+--- @param match string
+--- @param runnerName string
+function hilbish.runner.route(match, runnerName)
+	if type(match) ~= 'string' or match == '' then
+		error 'expected route to be a non-empty string'
+	end
+	if runnerName ~= nil and type(runnerName) ~= 'string' then
+		error 'expected runner name to be a string'
+	end
+
+	for i, r in ipairs(routes) do
+		if r.match == match then
+			table.remove(routes, i)
+			break
+		end
+	end
+
+	if runnerName then
+		table.insert(routes, {match = match, runner = runnerName})
+	end
+end
+
+--- Returns the name of the runner that input goes to, the input that
+--- runner is passed and whether it was routed there with `route`.
+--- Input that isn't routed goes to the current runner. Highlighters and
+--- completion handlers can use this to follow which language a line is in.
+--- @param input string
+--- @return string, string, boolean
+function hilbish.runner.resolve(input)
+	for _, r in ipairs(routes) do
+		if r.match:sub(1, 1) == '^' then
+			if input:match(r.match) then
+				return r.runner, input, true
+			end
+		elseif input:sub(1, #r.match) == r.match then
+			return r.runner, input:sub(#r.match + 1), true
+		end
+	end
+
+	return currentRunner, input, false
+end
+
+-- colorize adds colors to the text of a value from inspect:
+-- strings, numbers, true, false and nil, and things like <function 1>.
+local function colorize(text)
+	local out = {}
+	local i = 1
+	while i <= #text do
+		local c = text:sub(i, i)
+		local piece = c
+		local color
+
+		if c == '"' or c == '\'' then
+			local j = i + 1
+			while j <= #text and text:sub(j, j) ~= c do
+				if text:sub(j, j) == '\\' then j = j + 1 end
+				j = j + 1
+			end
+			piece = text:sub(i, j)
+			color = lunacolors.green
+		elseif c == '<' then
+			piece = text:match('^<[^>]*>', i) or c
+			color = lunacolors.magenta
+		elseif c:match '[%a_]' then
+			piece = text:match('^[%a_][%w_]*', i)
+			if piece == 'true' or piece == 'false' or piece == 'nil' then
+				color = lunacolors.blue
+			end
+		elseif text:match('^%-?%d', i) then
+			piece = text:match('^%-?[%w%.]+', i)
+			color = lunacolors.yellow
+		end
+
+		table.insert(out, color and color(piece) or piece)
+		i = i + #piece
+	end
+
+	return table.concat(out)
+end
+
+--- Shows the values of a Lua expression that was run interactively, like
+--- `1 + 2`. Values are pretty printed with inspect and colored.
+--- This is only called when the `luaEcho` opt is enabled, and can be
+--- overridden to show them differently.
+--- @param ... any
+function hilbish.runner.echo(...)
+	-- inspect is only loaded when something is shown
+	local inspect = require 'inspect'
+	local vals = table.pack(...)
+	local shown = {}
+	for i = 1, vals.n do
+		shown[i] = colorize(inspect(vals[i]))
+	end
+
+	print(table.concat(shown, '\t'))
+end
+
 hilbish.runner.add('hybrid', function(input)
 	local cmdStr = hilbish.aliases.resolve(input)
 
@@ -88,17 +192,25 @@ hilbish.runner.add('hybrid', function(input)
 	if not res.err then
 		return res
 	end
+	-- unfinished lua is prompted for more of, unless it's a whole
+	-- sh command (like `CC=clang make`)
+	if res.continue and hilbish.runner.incomplete(cmdStr, 'hybrid') then
+		return res
+	end
 
 	return hilbish.runner.sh(input)
 end)
 
 hilbish.runner.add('hybridRev', function(input)
 	local res = hilbish.runner.sh(input)
+	local cmdStr = hilbish.aliases.resolve(input)
 	if not res.err then
 		return res
 	end
+	if res.continue and hilbish.runner.incomplete(cmdStr, 'hybridRev') then
+		return res
+	end
 
-	local cmdStr = hilbish.aliases.resolve(input)
 	return hilbish.runner.lua(cmdStr)
 end)
 
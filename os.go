@@ -1,4 +1,4 @@
-package main
+package hilbish
 
 import (
 	"hilbish/util"
@@ -0,0 +1,506 @@
+package hilbish
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	rt "github.com/arnodel/golua/runtime"
+	"github.com/maxlandon/readline"
+	"github.com/sahilm/fuzzy"
+)
+
+var rxEscape = regexp.MustCompile("\x1b\\[[0-9;?]*[a-zA-Z]")
+
+// picker is a full screen fuzzy finder, like fzf.
+// It lists items which are filtered and ranked by what is typed,
+// and returns the ones that are picked.
+type picker struct {
+	items []string
+	prompt string
+	multi bool
+	// preview returns the text to show beside the list
+	// for the item at an index. The pane is hidden if it is nil.
+	preview func(idx int) string
+
+	query []rune
+	matches []pickMatch
+	cur int // current match
+	top int // first match on screen
+	selected []int // indexes of the picked items, in the order they were picked
+
+	previewIdx int
+	previewText []string
+
+	pasting bool // between the start and end of a bracketed paste
+}
+
+type pickMatch struct {
+	idx int
+	positions map[int]bool // byte offsets of the matched characters
+}
+
+func newPicker(items []string) *picker {
+	return &picker{
+		items: items,
+		prompt: "> ",
+		previewIdx: -1,
+	}
+}
+
+// pick shows the picker and returns the indexes of the picked items,
+// or false if it was cancelled. If nothing was marked with Tab,
+// the item under the cursor is picked.
+func (p *picker) pick() ([]int, bool) {
+	fd := int(os.Stdin.Fd())
+	state, err := readline.MakeRaw(fd)
+	if err != nil {
+		return nil, false
+	}
+	defer readline.Restore(fd, state)
+
+	// the alternate screen leaves what was on the screen untouched
+	os.Stdout.WriteString("\x1b[?1049h")
+	defer os.Stdout.WriteString("\x1b[?1049l")
+
+	p.filter()
+	buf := make([]byte, 1024)
+	for {
+		p.draw()
+
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return nil, false
+		}
+
+		done, ok := p.input(string(buf[:n]))
+		if !done {
+			continue
+		}
+		if !ok {
+			return nil, false
+		}
+
+		if len(p.selected) != 0 {
+			return p.selected, true
+		}
+		if len(p.matches) == 0 {
+			return nil, false
+		}
+		return []int{p.matches[p.cur].idx}, true
+	}
+}
+
+// input handles keys the user pressed. It returns whether
+// the picker is done, and if so whether something was picked.
+func (p *picker) input(s string) (done bool, ok bool) {
+	filter := false
+
+	for len(s) != 0 {
+		if strings.HasPrefix(s, "\x1b[") || strings.HasPrefix(s, "\x1bO") {
+			// CSI sequence: parameters, then a final byte
+			end := 2
+			for end < len(s) && (s[end] < 0x40 || s[end] > 0x7e) {
+				end++
+			}
+			if end == len(s) {
+				return false, false
+			}
+			switch s[:end + 1] {
+			case "\x1b[A", "\x1bOA":
+				p.move(-1)
+			case "\x1b[B", "\x1bOB":
+				p.move(1)
+			case "\x1b[5~":
+				p.move(-p.listHeight())
+			case "\x1b[6~":
+				p.move(p.listHeight())
+			case "\x1b[Z":
+				p.toggle()
+				p.move(-1)
+			case "\x1b[200~":
+				p.pasting = true
+			case "\x1b[201~":
+				p.pasting = false
+			}
+			s = s[end + 1:]
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s)
+		s = s[size:]
+		// pasted text only goes in the query
+		if p.pasting {
+			if unicode.IsPrint(r) {
+				p.query = append(p.query, r)
+				filter = true
+			}
+			continue
+		}
+
+		switch r {
+		case '\x1b':
+			if len(s) == 0 {
+				return true, false
+			}
+			// ignore Alt- keys
+			_, size = utf8.DecodeRuneInString(s)
+			s = s[size:]
+		case 3, 7: // Ctrl-C, Ctrl-G
+			return true, false
+		case 4: // Ctrl-D
+			if len(p.query) == 0 {
+				return true, false
+			}
+		case '\r', '\n':
+			return true, true
+		case 16, 11: // Ctrl-P, Ctrl-K
+			p.move(-1)
+		case 14: // Ctrl-N
+			p.move(1)
+		case '\t':
+			p.toggle()
+			p.move(1)
+		case 127, 8: // Backspace
+			if len(p.query) != 0 {
+				p.query = p.query[:len(p.query) - 1]
+				filter = true
+			}
+		case 21: // Ctrl-U
+			p.query = p.query[:0]
+			filter = true
+		case 23: // Ctrl-W
+			i := len(p.query)
+			for i > 0 && unicode.IsSpace(p.query[i - 1]) {
+				i--
+			}
+			for i > 0 && !unicode.IsSpace(p.query[i - 1]) {
+				i--
+			}
+			p.query = p.query[:i]
+			filter = true
+		case 1: // Ctrl-A, select all
+			if p.multi {
+				p.selected = p.selected[:0]
+				for _, m := range p.matches {
+					p.selected = append(p.selected, m.idx)
+				}
+			}
+		default:
+			if unicode.IsPrint(r) {
+				p.query = append(p.query, r)
+				filter = true
+			}
+		}
+	}
+
+	if filter {
+		p.filter()
+	}
+	return false, false
+}
+
+// filter matches the items against the query. The items are fuzzy matched
+// and ranked by score, with items that score the same in their original order.
+func (p *picker) filter() {
+	p.cur, p.top = 0, 0
+	query := strings.TrimSpace(string(p.query))
+
+	if query == "" {
+		p.matches = make([]pickMatch, len(p.items))
+		for i := range p.items {
+			p.matches[i] = pickMatch{idx: i}
+		}
+		return
+	}
+
+	found := fuzzy.FindNoSort(query, p.items)
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Score > found[j].Score
+	})
+
+	p.matches = make([]pickMatch, len(found))
+	for i, m := range found {
+		positions := make(map[int]bool, len(m.MatchedIndexes))
+		for _, pos := range m.MatchedIndexes {
+			positions[pos] = true
+		}
+		p.matches[i] = pickMatch{idx: m.Index, positions: positions}
+	}
+}
+
+func (p *picker) move(n int) {
+	p.cur += n
+	if p.cur >= len(p.matches) {
+		p.cur = len(p.matches) - 1
+	}
+	if p.cur < 0 {
+		p.cur = 0
+	}
+}
+
+// toggle marks or unmarks the current item to be picked.
+func (p *picker) toggle() {
+	if !p.multi || len(p.matches) == 0 {
+		return
+	}
+
+	idx := p.matches[p.cur].idx
+	for i, sel := range p.selected {
+		if sel == idx {
+			p.selected = append(p.selected[:i], p.selected[i + 1:]...)
+			return
+		}
+	}
+	p.selected = append(p.selected, idx)
+}
+
+func (p *picker) isSelected(idx int) bool {
+	for _, sel := range p.selected {
+		if sel == idx {
+			return true
+		}
+	}
+	return false
+}
+
+// listHeight is the amount of items that fit on the screen,
+// under the query and info lines.
+func (p *picker) listHeight() int {
+	if height := readline.GetTermLength() - 2; height > 1 {
+		return height
+	}
+	return 1
+}
+
+func (p *picker) draw() {
+	width, height := readline.GetTermWidth(), p.listHeight()
+	if p.cur < p.top {
+		p.top = p.cur
+	}
+	if p.cur >= p.top + height {
+		p.top = p.cur - height + 1
+	}
+
+	listWidth := width
+	var preview []string
+	if p.preview != nil && width >= 40 {
+		listWidth = width / 2
+		preview = p.previewLines()
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\x1b[?25l\x1b[H")
+
+	sb.WriteString("\x1b[2K\x1b[1;36m" + p.prompt + "\x1b[0m" + string(p.query) + "\r\n")
+	info := fmt.Sprintf("  %d/%d", len(p.matches), len(p.items))
+	if len(p.selected) != 0 {
+		info += fmt.Sprintf(" (%d)", len(p.selected))
+	}
+	if rule := width - len(info) - 2; rule > 0 {
+		info += " " + strings.Repeat("─", rule)
+	}
+	sb.WriteString("\x1b[2K\x1b[90m" + info + "\x1b[0m")
+
+	for row := 0; row < height; row++ {
+		sb.WriteString("\r\n\x1b[2K")
+
+		i := p.top + row
+		if i < len(p.matches) {
+			sb.WriteString(p.itemLine(p.matches[i], i == p.cur, listWidth - 1))
+		}
+
+		if preview != nil {
+			sb.WriteString("\x1b[" + strconv.Itoa(listWidth) + "G\x1b[90m│\x1b[0m ")
+			if row < len(preview) {
+				sb.WriteString(truncate(preview[row], width - listWidth - 2))
+			}
+		}
+	}
+
+	col := utf8.RuneCountInString(p.prompt) + len(p.query) + 1
+	sb.WriteString("\x1b[1;" + strconv.Itoa(col) + "H\x1b[?25h")
+	os.Stdout.WriteString(sb.String())
+}
+
+// itemLine renders a match, with the matched characters highlighted.
+func (p *picker) itemLine(m pickMatch, current bool, width int) string {
+	var sb strings.Builder
+	switch {
+	case current && p.isSelected(m.idx):
+		sb.WriteString("\x1b[1;31m>\x1b[35m*\x1b[0m\x1b[1m")
+	case current:
+		sb.WriteString("\x1b[1;31m> \x1b[0m\x1b[1m")
+	case p.isSelected(m.idx):
+		sb.WriteString(" \x1b[35m*\x1b[0m")
+	default:
+		sb.WriteString("  ")
+	}
+
+	item := p.items[m.idx]
+	cols := 2
+	for i, r := range item {
+		if cols >= width {
+			break
+		}
+		switch {
+		case r == '\n':
+			r = '↵'
+		case !unicode.IsPrint(r):
+			r = ' '
+		}
+
+		if m.positions[i] {
+			sb.WriteString("\x1b[32m" + string(r) + "\x1b[39m")
+		} else {
+			sb.WriteRune(r)
+		}
+		cols++
+	}
+	sb.WriteString("\x1b[0m")
+
+	return sb.String()
+}
+
+// previewLines returns the preview of the current item,
+// which is only made again once another item is current.
+func (p *picker) previewLines() []string {
+	if len(p.matches) == 0 {
+		return nil
+	}
+
+	idx := p.matches[p.cur].idx
+	if idx != p.previewIdx {
+		p.previewIdx = idx
+		text := strings.ReplaceAll(p.preview(idx), "\t", "    ")
+		p.previewText = strings.Split(strings.TrimRight(text, "\n"), "\n")
+	}
+
+	return p.previewText
+}
+
+// truncate cuts s down to width columns, with escape sequences
+// and other control characters removed.
+func truncate(s string, width int) string {
+	s = rxEscape.ReplaceAllString(s, "")
+	var sb strings.Builder
+	cols := 0
+	for _, r := range s {
+		if cols >= width {
+			break
+		}
+		if !unicode.IsPrint(r) {
+			r = ' '
+		}
+		sb.WriteRune(r)
+		cols++
+	}
+
+	return sb.String()
+}
+
+// pick(items, opts) -> table
+// Shows a fuzzy finder for the user to pick from `items`.
+// This is the same full screen finder used by Ctrl-R, Ctrl-T and Alt-C.
+// Typing filters and ranks the items, Up and Down (or Ctrl-P and Ctrl-N)
+// move through them and Enter picks the highlighted item.
+// Escape or Ctrl-C cancels, in which case nothing is returned.
+// The following `opts` can be set:
+// `prompt` (string): shown before the query, `> ` by default.
+// `query` (string): text to start the query with.
+// `multi` (boolean): if true, Tab marks items so more than one can be picked.
+// `preview` (function): called with the highlighted item, and returns
+// text to show in a pane beside the list.
+// #param items table
+// #param opts table|nil
+// #returns table
+// #example
+/*
+local branches = {}
+for branch in io.popen('git branch --format="%(refname:short)"'):lines() do
+	table.insert(branches, branch)
+end
+
+local picked = hilbish.pick(branches, {
+	prompt = 'branch> ',
+	preview = function(branch)
+		local _, out = hilbish.run('git log --oneline -n 20 ' .. branch, false)
+		return out
+	end
+})
+if picked then
+	hilbish.run('git switch ' .. picked[1])
+end
+*/
+// #example
+func (sh *Shell) hlpick(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+	if err := c.Check1Arg(); err != nil {
+		return nil, err
+	}
+	itemsTbl, err := c.TableArg(0)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []string{}
+	for i := int64(1); ; i++ {
+		v := itemsTbl.Get(rt.IntValue(i))
+		if v.IsNil() {
+			break
+		}
+		item, ok := v.TryString()
+		if !ok {
+			return nil, fmt.Errorf("bad item %d in items to pick (expected string, got %s)", i, v.TypeName())
+		}
+		items = append(items, item)
+	}
+
+	p := newPicker(items)
+	if len(c.Etc()) != 0 && !c.Etc()[0].IsNil() {
+		opts, ok := c.Etc()[0].TryTable()
+		if !ok {
+			return nil, errors.New("bad argument #2 to pick (expected table, got " + c.Etc()[0].TypeName() + ")")
+		}
+
+		if prompt, ok := opts.Get(rt.StringValue("prompt")).TryString(); ok {
+			p.prompt = prompt
+		}
+		if query, ok := opts.Get(rt.StringValue("query")).TryString(); ok {
+			p.query = []rune(query)
+		}
+		p.multi = rt.Truth(opts.Get(rt.StringValue("multi")))
+
+		previewFn := opts.Get(rt.StringValue("preview"))
+		if !previewFn.IsNil() {
+			if _, ok := previewFn.TryCallable(); !ok {
+				return nil, errors.New("bad preview in opts to pick (expected function, got " + previewFn.TypeName() + ")")
+			}
+			p.preview = func(idx int) string {
+				ret, err := rt.Call1(t, previewFn, rt.StringValue(items[idx]))
+				if err != nil {
+					return err.Error()
+				}
+				text, _ := ret.ToString()
+				return text
+			}
+		}
+	}
+
+	picked, ok := p.pick()
+	if !ok {
+		return c.Next(), nil
+	}
+
+	tbl := rt.NewTable()
+	for i, idx := range picked {
+		tbl.Set(rt.IntValue(int64(i + 1)), rt.StringValue(items[idx]))
+	}
+
+	return c.PushingNext1(t.Runtime, rt.TableValue(tbl)), nil
+}
@@ -0,0 +1,92 @@
+package hilbish
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPickerFilter(t *testing.T) {
+	type TestPickerFilterT struct {
+		Items []string
+		Query string
+		Expected []string
+	}
+
+	tests := []TestPickerFilterT{
+		// an empty query lists everything in order
+		{Items: []string{"b", "a", "c"}, Query: " ", Expected: []string{"b", "a", "c"}},
+		{Items: []string{"make", "git", "go"}, Query: "xyz", Expected: []string{}},
+		// characters matched together rank higher
+		{Items: []string{"gxxixxt", "git status"}, Query: "git", Expected: []string{"git status", "gxxixxt"}},
+		{Items: []string{"src/main.go", "main.go"}, Query: "main", Expected: []string{"main.go", "src/main.go"}},
+		// items which score the same keep their order
+		{Items: []string{"git pull", "git push"}, Query: "git", Expected: []string{"git pull", "git push"}},
+	}
+
+	for _, test := range tests {
+		p := newPicker(test.Items)
+		p.query = []rune(test.Query)
+		p.filter()
+
+		matched := []string{}
+		for _, m := range p.matches {
+			matched = append(matched, p.items[m.idx])
+		}
+		if !reflect.DeepEqual(matched, test.Expected) {
+			t.Errorf("%q in %q: expected %q, got %q", test.Query, test.Items, test.Expected, matched)
+		}
+	}
+}
+
+func TestPickerInput(t *testing.T) {
+	type TestPickerInputT struct {
+		Keys []string
+		Multi bool
+		Done bool
+		Ok bool
+		Query string
+		Selected []int
+	}
+
+	tests := []TestPickerInputT{
+		{Keys: []string{"g", "i"}, Query: "gi"},
+		{Keys: []string{"gx", "\x7f"}, Query: "g"},
+		{Keys: []string{"go build", "\x17"}, Query: "go "},
+		{Keys: []string{"go", "\x15"}, Query: ""},
+		{Keys: []string{"\r"}, Done: true, Ok: true},
+		{Keys: []string{"g", "\x03"}, Done: true, Query: "g"},
+		{Keys: []string{"\x1b"}, Done: true},
+		{Keys: []string{"\x04"}, Done: true},
+		// Alt- keys are ignored
+		{Keys: []string{"\x1bb"}},
+		// pasted text goes in the query, without the newline
+		{Keys: []string{"\x1b[200~git\n\x1b[201~"}, Query: "git"},
+		// Tab only marks items when more than one can be picked
+		{Keys: []string{"\t", "\t"}},
+		{Keys: []string{"\t", "\t"}, Multi: true, Selected: []int{0, 1}},
+		{Keys: []string{"\t", "\x1b[A", "\t"}, Multi: true, Selected: []int{}},
+		{Keys: []string{"\x1b[B", "\t"}, Multi: true, Selected: []int{1}},
+		{Keys: []string{"\x01"}, Multi: true, Selected: []int{0, 1, 2}},
+	}
+
+	for _, test := range tests {
+		p := newPicker([]string{"git", "go", "make"})
+		p.multi = test.Multi
+		p.filter()
+
+		var done, ok bool
+		for _, key := range test.Keys {
+			done, ok = p.input(key)
+		}
+
+		if done != test.Done || ok != test.Ok {
+			t.Errorf("%q: expected done and ok to be %v and %v, got %v and %v", test.Keys, test.Done, test.Ok, done, ok)
+		}
+		if string(p.query) != test.Query {
+			t.Errorf("%q: expected query %q, got %q", test.Keys, test.Query, string(p.query))
+		}
+		if len(p.selected) != len(test.Selected) || (len(test.Selected) != 0 && !reflect.DeepEqual(p.selected, test.Selected)) {
+			t.Errorf("%q: expected %v to be selected, got %v", test.Keys, test.Selected, p.selected)
+		}
+	}
+}
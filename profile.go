@@ -0,0 +1,74 @@
+package hilbish
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// startupProfile records how long it takes to load each Lua module
+// while Hilbish starts up.
+type startupProfile struct {
+	w io.Writer
+	start time.Time
+	depth int
+	entries []*profileEntry
+}
+
+type profileEntry struct {
+	name string
+	depth int
+	cached bool
+	dur time.Duration
+}
+
+// WithStartupProfile makes the shell write a report of the time spent
+// loading each Lua module to w, once the config has been run.
+func WithStartupProfile(w io.Writer) Option {
+	return func(sh *Shell) {
+		sh.profile = &startupProfile{
+			w: w,
+			start: time.Now(),
+		}
+	}
+}
+
+// track starts timing the load of a module with the passed name.
+// The returned function has to be called once it is done loading.
+// Modules required while it is loading are shown nested under it.
+func (p *startupProfile) track(name string) func(cached bool) {
+	if p == nil {
+		return func(bool) {}
+	}
+
+	e := &profileEntry{
+		name: name,
+		depth: p.depth,
+	}
+	p.entries = append(p.entries, e)
+	p.depth++
+	start := time.Now()
+
+	return func(cached bool) {
+		e.dur = time.Since(start)
+		e.cached = cached
+		p.depth--
+	}
+}
+
+func (p *startupProfile) report() {
+	if p == nil {
+		return
+	}
+
+	fmt.Fprintln(p.w, "Startup profile:")
+	for _, e := range p.entries {
+		var cached string
+		if e.cached {
+			cached = " (cached)"
+		}
+		fmt.Fprintf(p.w, "%12s  %s%s%s\n", e.dur.Round(time.Microsecond), strings.Repeat("  ", e.depth), e.name, cached)
+	}
+	fmt.Fprintf(p.w, "%12s  total\n", time.Since(p.start).Round(time.Microsecond))
+}
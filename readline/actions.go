@@ -0,0 +1,437 @@
+package readline
+
+import (
+	"fmt"
+	"sort"
+)
+
+// actions are the built-in editor actions keys can be bound to by name.
+var actions = map[string]func(rl *Instance){
+	"accept-hint":              func(rl *Instance) { rl.acceptHint(false) },
+	"accept-line":              func(rl *Instance) { rl.accepted = true },
+	"alt-history-search":       (*Instance).altHistorySearch,
+	"backward-char":            (*Instance).backwardChar,
+	"backward-delete-char":     (*Instance).backwardDeleteChar,
+	"backward-kill-line":       (*Instance).backwardKillLine,
+	"backward-kill-word":       (*Instance).backwardKillWord,
+	"backward-word":            (*Instance).backwardWord,
+	"beginning-of-line":        (*Instance).beginningOfLine,
+	"capitalize-word":          (*Instance).capitalizeWord,
+	"clear-screen":             (*Instance).clearScreen,
+	"complete":                 (*Instance).complete,
+	"delete-char":              (*Instance).deleteChar,
+	"digit-argument":           func(rl *Instance) { rl.digitArgument(rl.actionKey()) },
+	"downcase-word":            (*Instance).downcaseWord,
+	"edit-and-execute-command": func(rl *Instance) { rl.editCommandLine(true) },
+	"edit-command-line":        func(rl *Instance) { rl.editCommandLine(false) },
+	"end-of-line":              (*Instance).endOfLine,
+	"forward-char":             (*Instance).forwardChar,
+	"forward-word":             (*Instance).forwardWord,
+	"history-search":           (*Instance).historySearch,
+	"kill-line":                (*Instance).killLine,
+	"kill-word":                (*Instance).killWord,
+	"next-history":             func(rl *Instance) { rl.walkHistoryLine(-1) },
+	"previous-history":         func(rl *Instance) { rl.walkHistoryLine(1) },
+	"redo":                     (*Instance).redo,
+	"transpose-chars":          (*Instance).transposeChars,
+	"transpose-words":          (*Instance).transposeWords,
+	"undo":                     (*Instance).undo,
+	"upcase-word":              (*Instance).upcaseWord,
+	"vi-insert-mode":           (*Instance).viInsertMode,
+	"vi-normal-mode":           (*Instance).viNormalMode,
+	"yank":                     (*Instance).yank,
+	"yank-last-arg":            func(rl *Instance) { rl.yankArg(true) },
+	"yank-nth-arg":             func(rl *Instance) { rl.yankArg(false) },
+	"yank-pop":                 (*Instance).yankPop,
+}
+
+// AddAction adds an editor action that keys can be bound to by name.
+// The callback works the same as the ones added with AddEvent.
+func (rl *Instance) AddAction(name string, callback func(string, []rune, int) *EventReturn) {
+	rl.customActions[name] = callback
+}
+
+// Actions returns the names of all the editor actions, sorted.
+func (rl *Instance) Actions() []string {
+	names := make([]string, 0, len(actions)+len(rl.customActions))
+	for name := range actions {
+		names = append(names, name)
+	}
+	for name := range rl.customActions {
+		if _, ok := actions[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (rl *Instance) hasAction(name string) bool {
+	if _, ok := actions[name]; ok {
+		return true
+	}
+	_, ok := rl.customActions[name]
+	return ok
+}
+
+// RunAction runs the editor action with the passed name.
+func (rl *Instance) RunAction(name string) error {
+	if !rl.hasAction(name) {
+		return fmt.Errorf("unknown editor action %s", name)
+	}
+	rl.runAction(name, "")
+
+	return nil
+}
+
+// runAction runs an action, for keys if it was bound to them.
+// It returns whether the keys should also be handled like they
+// weren't bound, which actions added with AddAction can ask for.
+func (rl *Instance) runAction(name, keys string) (forward bool) {
+	if action, ok := rl.customActions[name]; ok {
+		rl.clearHelpers()
+		forward, closeLine := rl.applyEvent(action(keys, rl.line, rl.pos))
+		if closeLine {
+			rl.accepted = true
+		}
+		return forward
+	}
+
+	if action, ok := actions[name]; ok {
+		rl.actionKeys = keys
+		action(rl)
+	}
+	return false
+}
+
+// actionKey returns the last character of the keys
+// the action being run was bound to.
+func (rl *Instance) actionKey() rune {
+	keys := []rune(rl.actionKeys)
+	if len(keys) == 0 {
+		return 0
+	}
+	return keys[len(keys)-1]
+}
+
+// applyEvent updates the line as asked by an event callback.
+func (rl *Instance) applyEvent(ret *EventReturn) (forward bool, closeLine bool) {
+	rl.clearLine()
+	rl.line = append(ret.NewLine, []rune{}...)
+	rl.updateHelpers() // rl.echo
+	rl.pos = ret.NewPos
+
+	if ret.ClearHelpers {
+		rl.resetHelpers()
+	} else {
+		rl.updateHelpers()
+	}
+
+	if len(ret.InfoText) > 0 {
+		rl.infoText = ret.InfoText
+		rl.clearHelpers()
+		rl.renderHelpers()
+	}
+
+	return ret.ForwardKey, ret.CloseReadline
+}
+
+func (rl *Instance) beginningOfLine() {
+	if rl.modeTabCompletion {
+		rl.resetVirtualComp(false)
+	}
+	rl.pos = 0
+	rl.updateHelpers()
+}
+
+func (rl *Instance) endOfLine() {
+	if rl.modeTabCompletion {
+		rl.resetVirtualComp(false)
+	}
+	if len(rl.line) > 0 {
+		rl.pos = len(rl.line)
+		if rl.modeViMode != VimInsert {
+			rl.pos--
+		}
+	}
+	rl.updateHelpers()
+}
+
+func (rl *Instance) backwardChar() {
+	rl.moveCursorByAdjust(-1)
+	rl.updateHelpers()
+}
+
+func (rl *Instance) forwardChar() {
+	if (rl.modeViMode == VimInsert && rl.pos < len(rl.line)) ||
+		(rl.modeViMode != VimInsert && rl.pos < len(rl.line)-1) {
+		rl.moveCursorByAdjust(1)
+	}
+	rl.updateHelpers()
+}
+
+func (rl *Instance) backwardWord() {
+	if rl.modeTabCompletion {
+		return
+	}
+
+	move := rl.emacsBackwardWord(tokeniseLine)
+	rl.moveCursorByAdjust(-move)
+	rl.updateHelpers()
+}
+
+func (rl *Instance) forwardWord() {
+	if rl.modeTabCompletion {
+		return
+	}
+
+	move := rl.emacsForwardWord(tokeniseLine)
+	rl.moveCursorByAdjust(move)
+	rl.updateHelpers()
+}
+
+func (rl *Instance) backwardDeleteChar() {
+	rl.resetVirtualComp(false)
+	rl.backspace(false)
+	rl.renderHelpers()
+}
+
+func (rl *Instance) deleteChar() {
+	if rl.modeTabFind {
+		rl.backspaceTabFind()
+		return
+	}
+	if rl.pos < len(rl.line) {
+		rl.deleteBackspace(true)
+	}
+}
+
+// backwardKillLine deletes everything from the beginning of the line to the cursor.
+func (rl *Instance) backwardKillLine() {
+	if rl.modeTabCompletion {
+		rl.resetVirtualComp(true)
+	}
+	rl.kill(rl.line[:rl.pos], true)
+	rl.deleteToBeginning()
+	rl.resetHelpers()
+	rl.updateHelpers()
+}
+
+// killLine deletes everything after the cursor.
+func (rl *Instance) killLine() {
+	if rl.modeTabCompletion {
+		rl.resetVirtualComp(true)
+	}
+	rl.kill(rl.line[rl.pos:], false)
+	rl.deleteToEnd()
+	rl.resetHelpers()
+	rl.updateHelpers()
+}
+
+func (rl *Instance) backwardKillWord() {
+	if rl.modeTabCompletion {
+		rl.resetVirtualComp(false)
+	}
+	adjust := rl.viJumpB(tokeniseLine)
+	rl.kill(rl.line[rl.pos+adjust:rl.pos], true)
+	rl.viDeleteByAdjust(adjust)
+	rl.updateHelpers()
+}
+
+func (rl *Instance) killWord() {
+	if rl.modeTabCompletion {
+		rl.resetVirtualComp(false)
+	}
+	adjust := rl.emacsForwardWord(tokeniseLine)
+	rl.kill(rl.line[rl.pos:rl.pos+adjust], false)
+	// vi delete, emacs forward, funny huh
+	rl.viDeleteByAdjust(adjust)
+	rl.updateHelpers()
+}
+
+// yank pastes after the cursor position.
+func (rl *Instance) yank() {
+	if rl.modeTabCompletion {
+		rl.resetVirtualComp(false)
+	}
+	buffer := rl.pasteFromRegister()
+
+	// yank-pop goes on from the kill ring entry that was yanked, if it was one
+	rl.yankIdx = -1
+	if len(rl.killRing) != 0 && string(rl.killRing[0]) == string(buffer) {
+		rl.yankIdx = 0
+	}
+	rl.yankStart = rl.pos
+	rl.insert(buffer)
+	rl.yankEnd = rl.pos
+	rl.thisCommand = cmdYank
+	rl.updateHelpers()
+}
+
+func (rl *Instance) undo() {
+	rl.undoLast()
+}
+
+func (rl *Instance) redo() {
+	rl.redoLast()
+}
+
+func (rl *Instance) clearScreen() {
+	print(seqClearScreen)
+	print(seqCursorTopLeft)
+	if rl.Multiline {
+		fmt.Println(rl.mainPrompt)
+	}
+	print(seqClearScreenBelow)
+
+	rl.resetInfoText()
+	rl.getInfoText()
+	rl.renderHelpers()
+}
+
+// historySearch opens the menu to search the main history.
+func (rl *Instance) historySearch() {
+	rl.resetVirtualComp(false)
+	// For some modes only, if we are in vim Keys mode,
+	// we toogle back to insert mode. For others, we return
+	// without getting the completions.
+	if rl.modeViMode != VimInsert {
+		rl.modeViMode = VimInsert
+		rl.computePrompt()
+	}
+
+	rl.mainHist = true // false before
+	rl.searchMode = HistoryFind
+	rl.modeAutoFind = true
+	rl.modeTabCompletion = true
+
+	rl.modeTabFind = true
+	rl.updateTabFind([]rune{})
+	rl.updateVirtualComp()
+	rl.renderHelpers()
+}
+
+// altHistorySearch opens the menu to search the alternative history.
+func (rl *Instance) altHistorySearch() {
+	rl.resetVirtualComp(false)
+	// For some modes only, if we are in vim Keys mode,
+	// we toogle back to insert mode. For others, we return
+	// without getting the completions.
+	if rl.modeViMode != VimInsert {
+		rl.modeViMode = VimInsert
+	}
+
+	rl.mainHist = false // true before
+	rl.searchMode = HistoryFind
+	rl.modeAutoFind = true
+	rl.modeTabCompletion = true
+
+	rl.modeTabFind = true
+	rl.updateTabFind([]rune{})
+}
+
+// walkHistoryLine replaces the line with an older (1) or newer (-1)
+// line from the main history.
+func (rl *Instance) walkHistoryLine(i int) {
+	rl.mainHist = true
+	rl.walkHistory(i)
+	moveCursorForwards(len(rl.line) - rl.pos)
+	rl.pos = len(rl.line)
+}
+
+// complete shows tab completions, or moves to the next one if they are shown.
+func (rl *Instance) complete() {
+	// If we have asked for completions, already printed, and we want to move selection.
+	if rl.modeTabCompletion && !rl.compConfirmWait {
+		rl.tabCompletionSelect = true
+		rl.moveTabCompletionHighlight(1, 0)
+		rl.updateVirtualComp()
+		rl.renderHelpers()
+		return
+	}
+
+	// Else we might be asked to confirm printing (if too many suggestions), or not.
+	rl.getTabCompletion()
+
+	// If too many completions and no yet confirmed, ask user for completion
+	// comps, lines := rl.getCompletionCount()
+	// if ((lines > GetTermLength()) || (lines > rl.MaxTabCompleterRows)) && !rl.compConfirmWait {
+	//         sentence := fmt.Sprintf("%s show all %d completions (%d lines) ? tab to confirm",
+	//                 FOREWHITE, comps, lines)
+	//         rl.promptCompletionConfirm(sentence)
+	//         continue
+	// }
+
+	rl.compConfirmWait = false
+	rl.modeTabCompletion = true
+
+	// Also here, if only one candidate is available, automatically
+	// insert it and don't bother printing completions.
+	// Quit the tab completion mode to avoid asking to the user
+	// to press Enter twice to actually run the command.
+	if rl.hasOneCandidate() {
+		rl.insertCandidate()
+
+		// Refresh first, and then quit the completion mode
+		rl.updateHelpers() // REDUNDANT WITH getTabCompletion()
+		rl.resetTabCompletion()
+		return
+	}
+
+	rl.updateHelpers() // REDUNDANT WITH getTabCompletion()
+}
+
+func (rl *Instance) viNormalMode() {
+	if rl.InputMode != Vim || rl.modeViMode == VimKeys {
+		return
+	}
+	if rl.modeViMode == VimInsert && rl.pos > 0 {
+		rl.pos--
+	}
+	rl.modeViMode = VimKeys
+	rl.viIteration = ""
+	rl.refreshVimStatus()
+}
+
+func (rl *Instance) viInsertMode() {
+	if rl.InputMode != Vim || rl.modeViMode == VimInsert {
+		return
+	}
+	rl.modeViMode = VimInsert
+	rl.viIteration = ""
+	rl.refreshVimStatus()
+}
+
+// editCommandLine opens the line in the user's editor, and puts what was
+// saved back in the line. With execute set, the edited line is run right away.
+// If the editor fails (like quitting Vim with :cq) the line is left as it was.
+func (rl *Instance) editCommandLine(execute bool) {
+	rl.clearHelpers()
+	var multiline []rune
+	if rl.GetMultiLine == nil {
+		multiline = rl.line
+	} else {
+		multiline = rl.GetMultiLine(rl.line)
+	}
+
+	new, err := rl.StartEditorWithBuffer(multiline, "")
+	if err != nil {
+		rl.updateHelpers()
+		rl.SetInfoText(Red("Could not edit the line: " + err.Error()))
+		return
+	}
+
+	// Clean the shell and put the new buffer, with adjusted pos if needed.
+	rl.clearLine()
+	rl.line = new
+	rl.pos = len(rl.line)
+	if rl.modeViMode != VimInsert && rl.pos > 0 {
+		rl.pos--
+	}
+	rl.updateHelpers()
+
+	if execute {
+		rl.accepted = true
+	}
+}
@@ -1,7 +1,5 @@
 package readline
 
-import "os"
-
 // Character codes
 const (
 	charCtrlA = iota + 1
@@ -59,6 +57,13 @@ var (
 	seqAltD         = string([]byte{27, 100})
 	seqAltF         = string([]byte{27, 102})
 	seqAltR         = string([]byte{27, 114}) // Used for alternative history
+	seqAltC         = string([]byte{27, 99})
+	seqAltL         = string([]byte{27, 108})
+	seqAltT         = string([]byte{27, 116})
+	seqAltU         = string([]byte{27, 117})
+	seqAltY         = string([]byte{27, 121})
+	seqAltDot       = string([]byte{27, 46})
+	seqAltCtrlY     = string([]byte{27, 25})
 	seqAltBackspace = string([]byte{27, 127})
 	seqPageUp       = string([]byte{27, 91, 53, 126})
 	seqPageDown       = string([]byte{27, 91, 54, 126})
@@ -79,6 +84,13 @@ const (
 	seqHideCursor = "\x1b[?25l"
 	seqUnhideCursor = "\x1b[?25h"
 
+	// Bracketed paste: while it is on, pasted text is sent
+	// between the paste start and end sequences.
+	seqBracketedPasteOn  = "\x1b[?2004h"
+	seqBracketedPasteOff = "\x1b[?2004l"
+	seqPasteStart        = "\x1b[200~"
+	seqPasteEnd          = "\x1b[201~"
+
 	seqCtrlLeftArrow  = "\x1b[1;5D"
 	seqCtrlRightArrow = "\x1b[1;5C"
 
@@ -92,6 +104,7 @@ const (
 	seqUnderscore = "\x1b[4m"
 	seqBlink      = "\x1b[5m"
 	seqInvert     = "\x1b[7m"
+	seqInvertOff  = "\x1b[27m"
 )
 
 // Text colours
@@ -145,7 +158,7 @@ const (
 // remedies the edge case of someone literally typing Ctrl-A for example.
 func (rl *Instance) ReadChar() string {
 	b := make([]byte, 1024)
-	i, _ := os.Stdin.Read(b)
+	i, _ := rl.read(b)
 	r := []rune(string(b))
 	s := string(r[:i])
 
@@ -178,7 +191,7 @@ func (rl *Instance) ReadChar() string {
 		case '\n': return "Enter"
 		case charEscape:
 			switch s {
-				case string(charEscape): return "Escape"
+				case string(rune(charEscape)): return "Escape"
 				case seqUp: return "Up"
 				case seqDown: return "Down"
 				case seqBackwards: return "Left"
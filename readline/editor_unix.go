@@ -5,12 +5,13 @@ package readline
 import (
 	"os"
 	"os/exec"
+	"strings"
 )
 
 const defaultEditor = "vi"
 
 // StartEditorWithBuffer - Enables a consumer of this console application to
-// open an arbitrary buffer into the system editor. Currently only implemnted
+// open an arbitrary buffer into the system editor ($VISUAL or $EDITOR). Currently only implemnted
 // on *Nix systems. The modified buffer is returned when the editor quits, and
 // depending on the actions taken by the user within it (eg: x or q! in Vim)
 // The filename parameter can be used to pass a specific filename.ext pattern,
@@ -21,23 +22,35 @@ func (rl *Instance) StartEditorWithBuffer(multiline []rune, filename string) ([]
 		return multiline, err
 	}
 
-	editor := os.Getenv("EDITOR")
-	// default editor if $EDITOR not set
-	if editor == "" {
-		editor = defaultEditor
+	// the editor may have arguments, like `code --wait`
+	args := strings.Fields(os.Getenv("VISUAL"))
+	if len(args) == 0 {
+		args = strings.Fields(os.Getenv("EDITOR"))
+	}
+	// default editor if $VISUAL and $EDITOR are not set (or blank)
+	if len(args) == 0 {
+		args = []string{defaultEditor}
 	}
 
-	cmd := exec.Command(editor, name)
+	cmd := exec.Command(args[0], append(args[1:], name)...)
 
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
-	if err := cmd.Start(); err != nil {
-		return multiline, err
+	// the editor needs the terminal how it was before readline made it raw
+	if rl.termState != nil {
+		fd := int(os.Stdin.Fd())
+		Restore(fd, rl.termState)
+		print(seqBracketedPasteOff)
+		defer func() {
+			MakeRaw(fd)
+			print(seqBracketedPasteOn)
+		}()
 	}
 
-	if err := cmd.Wait(); err != nil {
+	if err := cmd.Run(); err != nil {
+		os.Remove(name)
 		return multiline, err
 	}
 
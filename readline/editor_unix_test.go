@@ -0,0 +1,22 @@
+//go:build !windows && !plan9
+
+package readline
+
+import (
+	"testing"
+)
+
+func TestStartEditorBlankVisual(t *testing.T) {
+	// a blank $VISUAL is like it isn't set
+	t.Setenv("VISUAL", "  ")
+	t.Setenv("EDITOR", "true")
+
+	rl := NewInstance()
+	buf, err := rl.StartEditorWithBuffer([]rune("ls -a"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "ls -a" {
+		t.Errorf("expected %q, got %q", "ls -a", string(buf))
+	}
+}
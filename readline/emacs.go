@@ -0,0 +1,336 @@
+package readline
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// killRingSize is how many killed texts the kill ring holds.
+const killRingSize = 60
+
+// Commands that act differently when they are repeated,
+// kept track of with rl.lastCommand.
+const (
+	cmdKill    = "kill"
+	cmdYank    = "yank"
+	cmdYankArg = "yank-arg"
+)
+
+// kill saves killed text to the kill ring, and the unnamed register.
+// Text killed right after other text was killed is added to it,
+// before it if it was killed backwards.
+func (rl *Instance) kill(text []rune, backward bool) {
+	if len(text) == 0 {
+		return
+	}
+	text = append([]rune{}, text...)
+
+	if rl.lastCommand == cmdKill && len(rl.killRing) > 0 {
+		if backward {
+			rl.killRing[0] = append(text, rl.killRing[0]...)
+		} else {
+			rl.killRing[0] = append(rl.killRing[0], text...)
+		}
+		rl.registers.unnamed = rl.killRing[0]
+	} else {
+		rl.killRing = append([][]rune{text}, rl.killRing...)
+		if len(rl.killRing) > killRingSize {
+			rl.killRing = rl.killRing[:killRingSize]
+		}
+		rl.saveBufToRegister(text)
+	}
+
+	rl.thisCommand = cmdKill
+}
+
+// yankPop replaces the text that was just yanked with the next older
+// text in the kill ring. It only works right after a yank.
+func (rl *Instance) yankPop() {
+	if rl.lastCommand != cmdYank || len(rl.killRing) == 0 {
+		return
+	}
+	if rl.modeTabCompletion {
+		rl.resetVirtualComp(false)
+	}
+
+	rl.yankIdx = (rl.yankIdx + 1) % len(rl.killRing)
+	rl.replaceYanked(rl.killRing[rl.yankIdx])
+	rl.thisCommand = cmdYank
+}
+
+// replaceYanked replaces the last yanked (or inserted) text with another.
+func (rl *Instance) replaceYanked(text []rune) {
+	line := append([]rune{}, rl.line[:rl.yankStart]...)
+	line = append(line, text...)
+	rl.line = append(line, rl.line[rl.yankEnd:]...)
+
+	rl.yankEnd = rl.yankStart + len(text)
+	rl.pos = rl.yankEnd
+	rl.updateHelpers()
+}
+
+// yankArg inserts a word of the previous history entry: the last one,
+// or the one at the numeric argument (0 being the command). Doing it
+// again replaces the word with the one from the entry before that.
+func (rl *Instance) yankArg(last bool) {
+	if rl.mainHistory == nil {
+		return
+	}
+	if rl.modeTabCompletion {
+		rl.resetVirtualComp(false)
+	}
+
+	nth, hasArg := rl.takeArgument()
+	if rl.lastCommand == cmdYankArg {
+		rl.yankArgHist++
+		if hasArg {
+			rl.yankArgNth = nth
+		}
+	} else {
+		rl.yankArgHist = 1
+		rl.yankStart, rl.yankEnd = rl.pos, rl.pos
+		switch {
+		case hasArg:
+			rl.yankArgNth = nth
+		case last:
+			rl.yankArgNth = -1
+		default:
+			rl.yankArgNth = 1
+		}
+	}
+	rl.thisCommand = cmdYankArg
+
+	for ; rl.yankArgHist <= rl.mainHistory.Len(); rl.yankArgHist++ {
+		entry, err := rl.mainHistory.GetLine(rl.mainHistory.Len() - rl.yankArgHist)
+		if err != nil {
+			return
+		}
+
+		words := strings.Fields(entry)
+		idx := rl.yankArgNth
+		if idx == -1 {
+			idx = len(words) - 1
+		}
+		if idx >= 0 && idx < len(words) {
+			rl.replaceYanked([]rune(words[idx]))
+			return
+		}
+	}
+}
+
+// digitArgument adds a digit to the numeric argument, which is the
+// number of times to repeat the next key. Some actions use it for
+// something else, like which word yank-nth-arg inserts. A `-` before
+// the digits makes it negative, for the key to act backwards.
+func (rl *Instance) digitArgument(digit rune) {
+	if (digit < '0' || digit > '9') && (digit != '-' || rl.argDigits != "") {
+		return
+	}
+	rl.argDigits += string(digit)
+	rl.thisCommand = rl.lastCommand
+	rl.SetInfoText("(arg: " + rl.argDigits + ")")
+}
+
+// isDigitArgument returns whether the keys type a digit of a numeric argument
+// when they aren't bound: Alt and a digit (or a `-` first), unless a Vim
+// operator is waiting.
+func (rl *Instance) isDigitArgument(keys string) bool {
+	if len(keys) != 2 || keys[0] != charEscape || rl.modeViMode == VimDelete {
+		return false
+	}
+	return keys[1] >= '0' && keys[1] <= '9' || keys[1] == '-' && rl.argDigits == ""
+}
+
+// oppositeKeys are the keys that do the same as others but the other way,
+// which a negative numeric argument uses instead of them.
+var oppositeKeys = map[string]string{
+	string(rune(charEOF)):        string(rune(charBackspace2)),
+	string(rune(charBackspace2)): string(rune(charEOF)),
+	string(rune(charCtrlK)):      string(rune(charCtrlU)),
+	string(rune(charCtrlU)):      string(rune(charCtrlK)),
+	string(rune(charCtrlW)):      seqAltD,
+	seqAltD:                      string(rune(charCtrlW)),
+	seqAltF:                      seqAltB,
+	seqAltB:                      seqAltF,
+}
+
+// repeatArgument uses the numeric argument typed before keys (if any)
+// for them, by queueing them to be handled again until they have been
+// handled as many times as the argument says. With a negative argument,
+// a key that has an opposite one is replaced by it, in which case it
+// returns true, and the key shouldn't be handled itself.
+func (rl *Instance) repeatArgument(keys []string) bool {
+	if rl.argDigits == "" {
+		return false
+	}
+	n, _ := strconv.Atoi(rl.argDigits)
+	if rl.argDigits == "-" {
+		n = -1
+	}
+	rl.argDigits = ""
+	rl.resetInfoText()
+
+	if n < 0 && len(keys) == 1 {
+		if opposite, ok := oppositeKeys[keys[0]]; ok {
+			queue := []queuedKey{}
+			for i := 0; i < -n; i++ {
+				queue = append(queue, queuedKey{key: opposite, repeat: i != 0})
+			}
+			rl.keyQueue = append(queue, rl.keyQueue...)
+			return true
+		}
+	}
+
+	rl.arg, rl.hasArg = n, true
+	count := n
+	if count < 0 {
+		count = -count
+	}
+	queue := []queuedKey{}
+	for i := 1; i < count; i++ {
+		for _, key := range keys {
+			queue = append(queue, queuedKey{key: key, repeat: true})
+		}
+	}
+	rl.argRepeats = len(queue)
+	rl.keyQueue = append(queue, rl.keyQueue...)
+	return false
+}
+
+// takeArgument returns the numeric argument for the key being handled,
+// for an action to use itself instead of having the key repeated.
+func (rl *Instance) takeArgument() (int, bool) {
+	if !rl.hasArg {
+		return 0, false
+	}
+	rl.keyQueue = rl.keyQueue[rl.argRepeats:]
+	rl.hasArg, rl.argRepeats = false, 0
+
+	return rl.arg, true
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// wordEnd returns where the word at or after pos ends.
+func (rl *Instance) wordEnd(pos int) int {
+	for pos < len(rl.line) && !isWordRune(rl.line[pos]) {
+		pos++
+	}
+	for pos < len(rl.line) && isWordRune(rl.line[pos]) {
+		pos++
+	}
+	return pos
+}
+
+// wordStart returns where the word before pos starts.
+func (rl *Instance) wordStart(pos int) int {
+	for pos > 0 && !isWordRune(rl.line[pos-1]) {
+		pos--
+	}
+	for pos > 0 && isWordRune(rl.line[pos-1]) {
+		pos--
+	}
+	return pos
+}
+
+// changeWordCase changes the case of the word after the cursor, and moves
+// to its end. With a numeric argument, it changes that many words, or with
+// a negative one the words before the cursor, which stays where it is.
+func (rl *Instance) changeWordCase(change func(idx int, r rune) rune) {
+	if rl.modeTabCompletion {
+		rl.resetVirtualComp(false)
+	}
+
+	n, _ := rl.takeArgument()
+	if n == 0 {
+		n = 1
+	}
+	start, end := rl.pos, rl.pos
+	for i := 0; i < n; i++ {
+		end = rl.wordEnd(end)
+	}
+	for i := 0; i > n; i-- {
+		start = rl.wordStart(start)
+	}
+
+	idx := 0
+	for i := start; i < end; i++ {
+		if !isWordRune(rl.line[i]) {
+			// each word is changed on its own
+			idx = 0
+			continue
+		}
+		rl.line[i] = change(idx, rl.line[i])
+		idx++
+	}
+	if n > 0 {
+		rl.pos = end
+	}
+	rl.updateHelpers()
+}
+
+func (rl *Instance) upcaseWord() {
+	rl.changeWordCase(func(_ int, r rune) rune { return unicode.ToUpper(r) })
+}
+
+func (rl *Instance) downcaseWord() {
+	rl.changeWordCase(func(_ int, r rune) rune { return unicode.ToLower(r) })
+}
+
+func (rl *Instance) capitalizeWord() {
+	rl.changeWordCase(func(idx int, r rune) rune {
+		if idx == 0 {
+			return unicode.ToUpper(r)
+		}
+		return unicode.ToLower(r)
+	})
+}
+
+// transposeChars swaps the character before the cursor with the one under it,
+// and moves forward. At the end of the line, the last two characters are swapped.
+func (rl *Instance) transposeChars() {
+	if rl.modeTabCompletion {
+		rl.resetVirtualComp(false)
+	}
+	if rl.pos == 0 || len(rl.line) < 2 {
+		return
+	}
+
+	if rl.pos == len(rl.line) {
+		rl.pos--
+	}
+	rl.line[rl.pos-1], rl.line[rl.pos] = rl.line[rl.pos], rl.line[rl.pos-1]
+	rl.pos++
+	rl.updateHelpers()
+}
+
+// transposeWords swaps the word before the cursor with the one after it,
+// and moves after both. At the end of the line, the last two words are swapped.
+func (rl *Instance) transposeWords() {
+	if rl.modeTabCompletion {
+		rl.resetVirtualComp(false)
+	}
+
+	end2 := rl.wordEnd(rl.pos)
+	if strings.IndexFunc(string(rl.line[rl.pos:end2]), isWordRune) == -1 {
+		// no word after the cursor
+		end2 = rl.wordEnd(rl.wordStart(rl.pos))
+	}
+	start2 := rl.wordStart(end2)
+	start1 := rl.wordStart(start2)
+	end1 := rl.wordEnd(start1)
+	if start1 == start2 || end1 > start2 {
+		return
+	}
+
+	line := append([]rune{}, rl.line[:start1]...)
+	line = append(line, rl.line[start2:end2]...)
+	line = append(line, rl.line[end1:start2]...)
+	line = append(line, rl.line[start1:end1]...)
+	rl.line = append(line, rl.line[end2:]...)
+	rl.pos = end2
+	rl.updateHelpers()
+}
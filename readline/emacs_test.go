@@ -0,0 +1,89 @@
+package readline
+
+import (
+	"testing"
+)
+
+func TestEmacsKeys(t *testing.T) {
+	type TestEmacsKeysT struct {
+		Line     string // typed before the keys
+		Keys     string // as parsed by ParseKeys
+		History  []string
+		Expected string
+		Pos      int
+	}
+
+	tests := []TestEmacsKeysT{
+		// the kill ring
+		{Line: "foo bar baz", Keys: "Ctrl-A Alt-d Alt-d Ctrl-E Ctrl-Y", Expected: " bazfoo bar", Pos: 11},
+		{Line: "foo bar baz", Keys: "Ctrl-W Ctrl-W Ctrl-A Ctrl-Y", Expected: "bar bazfoo ", Pos: 7},
+		// kills with something else in between are separate
+		{Line: "foo bar", Keys: "Ctrl-W Ctrl-A Ctrl-K Ctrl-Y", Expected: "foo ", Pos: 4},
+		{Line: "foo bar", Keys: "Ctrl-W Ctrl-A Ctrl-K Ctrl-Y Alt-y", Expected: "bar", Pos: 3},
+		// yank-pop goes around the ring
+		{Line: "a b c", Keys: "Ctrl-W Ctrl-A Ctrl-K Ctrl-Y Alt-y Alt-y", Expected: "a b ", Pos: 4},
+		// and only works right after a yank
+		{Line: "a b", Keys: "Ctrl-W Ctrl-Y x Alt-y", Expected: "a bx", Pos: 4},
+
+		// yank-last-arg
+		{Keys: "e c h o Space Alt-.", History: []string{"ls /tmp", "cd /etc"}, Expected: "echo /etc", Pos: 9},
+		{Keys: "e c h o Space Alt-. Alt-.", History: []string{"ls /tmp", "cd /etc"}, Expected: "echo /tmp", Pos: 9},
+		{Keys: "Alt-0 Alt-.", History: []string{"git commit -m x"}, Expected: "git", Pos: 3},
+		{Keys: "Alt-Ctrl-Y", History: []string{"git commit -m x"}, Expected: "commit", Pos: 6},
+		{Keys: "Alt-.", Expected: "", Pos: 0},
+
+		// transposing
+		{Line: "ab", Keys: "Ctrl-T", Expected: "ba", Pos: 2},
+		{Line: "abc", Keys: "Ctrl-A Ctrl-T", Expected: "abc", Pos: 0},
+		{Line: "abc", Keys: "Ctrl-A Alt-f Ctrl-B", Expected: "abc", Pos: 3},
+		{Line: "abcd", Keys: "Ctrl-A Right Ctrl-T", Expected: "bacd", Pos: 2},
+		{Line: "a", Keys: "Ctrl-T", Expected: "a", Pos: 1},
+		{Line: "one two", Keys: "Alt-t", Expected: "two one", Pos: 7},
+		{Line: "one two three", Keys: "Ctrl-A Alt-f Alt-t", Expected: "two one three", Pos: 7},
+		// there's no word before the first one to swap it with
+		{Line: "one two", Keys: "Ctrl-A Alt-t", Expected: "one two", Pos: 0},
+		{Line: "one", Keys: "Alt-t", Expected: "one", Pos: 3},
+
+		// changing case
+		{Line: "hello world", Keys: "Ctrl-A Alt-u", Expected: "HELLO world", Pos: 5},
+		{Line: "HELLO WORLD", Keys: "Ctrl-A Alt-l Alt-l", Expected: "hello world", Pos: 11},
+		{Line: "hELLO wORLD", Keys: "Ctrl-A Alt-c", Expected: "Hello wORLD", Pos: 5},
+		{Line: "hello world", Keys: "Ctrl-A Alt-2 Alt-c", Expected: "Hello World", Pos: 11},
+		{Line: "hello world", Keys: "Alt-- Alt-u", Expected: "hello WORLD", Pos: 11},
+		{Line: "hello big world", Keys: "Alt-- Alt-2 Alt-c", Expected: "hello Big World", Pos: 15},
+		{Line: "hello", Keys: "Alt-u", Expected: "hello", Pos: 5},
+
+		// numeric arguments
+		{Line: "abcdef", Keys: "Ctrl-A Alt-3 Ctrl-D", Expected: "def", Pos: 0},
+		{Line: "a b c d", Keys: "Alt-3 Ctrl-W", Expected: "a ", Pos: 2},
+		{Line: "ab", Keys: "Alt-3 x", Expected: "abxxx", Pos: 5},
+		{Line: "ab", Keys: "Alt-1 Alt-2 x", Expected: "abxxxxxxxxxxxx", Pos: 14},
+		// negative ones go the other way
+		{Line: "abcdef", Keys: "Alt-- Alt-2 Ctrl-D", Expected: "abcd", Pos: 4},
+		{Line: "abcdef", Keys: "Ctrl-A Alt-- Backspace", Expected: "bcdef", Pos: 0},
+		{Line: "a b c d", Keys: "Ctrl-A Alt-- Alt-2 Ctrl-W", Expected: " c d", Pos: 0},
+		{Line: "abc def", Keys: "Ctrl-A Right Alt-- Ctrl-K", Expected: "bc def", Pos: 0},
+		{Line: "abc def", Keys: "Ctrl-A Alt-f Alt-- Ctrl-U", Expected: "abc", Pos: 3},
+	}
+
+	for _, test := range tests {
+		rl := NewInstance()
+		for _, h := range test.History {
+			rl.mainHistory.Write(h)
+		}
+
+		keys := []string{}
+		for _, r := range test.Line {
+			keys = append(keys, string(r))
+		}
+		parsed, err := ParseKeys(test.Keys)
+		if err != nil {
+			t.Fatalf("%q: %s", test.Keys, err)
+		}
+		readKeys(rl, append(keys, parsed...)...)
+
+		if string(rl.line) != test.Expected || rl.pos != test.Pos {
+			t.Errorf("%q then %q: expected %q at %d, got %q at %d", test.Line, test.Keys, test.Expected, test.Pos, string(rl.line), rl.pos)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package readline
 
-import "regexp"
+import (
+	"strings"
+	"unicode"
+)
 
 // SetHintText - a nasty function to force writing a new hint text. It does not update helpers, it just renders
 // them, so the hint will survive until the helpers (thus including the hint) will be updated/recomputed.
@@ -26,33 +29,51 @@ func (rl *Instance) getHintText() {
 	}
 }
 
-// writeHintText - only writes the hint text and computes its offsets.
+// writeHintText - only writes the hint text. It is kept on the line of the
+// cursor, and cut where it would wrap to the next one.
 func (rl *Instance) writeHintText() {
 	if len(rl.hintText) == 0 {
 		//rl.hintY = 0
 		return
 	}
 
-	width := GetTermWidth()
-
-	// Wraps the line, and counts the number of newlines in the string,
-	// adjusting the offset as well.
-	re := regexp.MustCompile(`\r?\n`)
-	newlines := re.Split(string(rl.hintText), -1)
-	offset := len(newlines)
-
-	wrapped, hintLen := WrapText(string(rl.hintText), width)
-	offset += hintLen
-//	rl.hintY = offset
-
-	hintText := string(wrapped)
-
-	if len(hintText) > 0 {
-		print(rl.HintFormatting + string(hintText) + seqReset)
+	hint := []rune(strings.NewReplacer("\r", "", "\n", " ", "\t", " ").Replace(string(rl.hintText)))
+	space := GetTermWidth() - rl.posX - 1
+	if space <= 0 {
+		return
+	}
+	if len(hint) > space {
+		hint = append(hint[:space - 1], '…')
 	}
+
+	print(rl.HintFormatting + string(hint) + seqReset)
 }
 
 func (rl *Instance) resetHintText() {
 	//rl.hintY = 0
 	rl.hintText = []rune{}
 }
+
+// acceptHint inserts the hint text into the line, if the cursor is at the
+// end of it. If word is true, only the next word of the hint is inserted.
+// It returns whether there was a hint to accept.
+func (rl *Instance) acceptHint(word bool) bool {
+	if len(rl.hintText) == 0 || rl.HintInfo || rl.pos != len(rl.line) || rl.modeTabCompletion {
+		return false
+	}
+
+	hint := rl.hintText
+	if word {
+		i := 0
+		for i < len(hint) && unicode.IsSpace(hint[i]) {
+			i++
+		}
+		for i < len(hint) && !unicode.IsSpace(hint[i]) {
+			i++
+		}
+		hint = hint[:i]
+	}
+
+	rl.insert(append([]rune{}, hint...))
+	return true
+}
@@ -198,7 +198,7 @@ func (rl *Instance) completeHistory() (hist []*CompletionGroup) {
 
 	rl.tcPrefix = string(rl.line) // We use the current full line for filtering
 
-	for i := history.Len() - 1; i >= 1; i-- {
+	for i := history.Len() - 1; i >= 0; i-- {
 		line, err = history.GetLine(i)
 		if err != nil {
 			continue
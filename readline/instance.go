@@ -2,6 +2,7 @@ package readline
 
 import (
 	"bufio"
+	"io"
 	"os"
 	"regexp"
 	"sync"
@@ -30,6 +31,7 @@ type Instance struct {
 
 	Multiline       bool   // If set to true, the shell will have a two-line prompt.
 	MultilinePrompt string // If multiline is true, this is the content of the 2nd line.
+	ContinuePrompt  string // Printed before each line of the input after the first one.
 
 	mainPrompt      string // If multiline true, the full prompt string / If false, the 1st line of the prompt
 	rightPrompt     string
@@ -162,6 +164,9 @@ type Instance struct {
 	// It takes the line input and cursor position.
 	// It returns the hint text to display.
 	HintText func([]rune, int) []rune
+	// HintInfo is whether the hint from HintText is only there to be read,
+	// and can't be accepted into the line.
+	HintInfo bool
 
 	// HintFormatting is just a string to use as the formatting for the hint. By default
 	// this will be a grey color.
@@ -175,8 +180,21 @@ type Instance struct {
 	modeViMode       ViMode //= vimInsert
 	viIteration      string
 	viUndoHistory    []undoItem
-	viUndoSkipAppend bool
+	redoHistory      []undoItem
+	undoBefore       undoItem // the line before the key being handled
+	undoKind         string   // the kind of edit the key made
+	undoBurst        bool     // whether the last edit can be undone with the next
 	viIsYanking      bool
+	viChange         bool    // the operator waiting for a motion is c, not d
+	viPending        rune    // a key waiting for another, like f for the character to find
+	viLastFind       [2]rune // the last f, F, t or T key and its character, for ; and ,
+	viVisualFrom     int     // where the visual selection started
+	viVisualLine     bool    // whether the whole line is selected (V)
+	viRecording      bool    // whether the keys of a change are being kept for `.`
+	viRecKeys        []string
+	viRecLine        string
+	viRecInsert      bool
+	viDotKeys        []string // the keys of the last change, repeated with `.`
 	registers        *registers // All memory text registers, can be consulted with Alt"
 
 	//
@@ -192,11 +210,46 @@ type Instance struct {
 	// then readline will just use the current line.
 	GetMultiLine func([]rune) []rune
 
+	// Paste is called with text that was pasted into the terminal, before
+	// it is inserted at the cursor. It returns the text to insert instead,
+	// and whether to insert anything at all.
+	Paste func([]rune) ([]rune, bool)
+
+	// Incomplete is called with the line when Enter is pressed. If it returns
+	// true, a newline is inserted instead of the line being accepted, so a
+	// command (like a loop) can be written and edited over multiple lines.
+	Incomplete func([]rune) bool
+
+	termState *State // the terminal state from before Readline made it raw
+	input     io.Reader // where keys are read from instead of the terminal, for tests
+
 	EnableGetCursorPos bool
 
 	// event
 	evtKeyPress map[string]func(string, []rune, int) *EventReturn
 
+	// key bindings
+	keymaps       map[string]map[string]binding
+	customActions map[string]func(string, []rune, int) *EventReturn
+	pendingKeys   []string    // keys pressed so far of a bound sequence
+	keyQueue      []queuedKey // keys to handle before reading more input
+	accepted      bool     // set by the accept-line action
+	actionKeys    string   // the keys the action being run is bound to
+
+	// emacs editing
+	killRing    [][]rune // killed texts, newest first
+	lastCommand string   // what the previous key did, if it matters for the next
+	thisCommand string
+	yankStart   int // where the last yanked text is in the line
+	yankEnd     int
+	yankIdx     int // the kill ring entry that was yanked
+	yankArgHist int // how far back in history yank-last-arg got its word from
+	yankArgNth  int // the word yank-last-arg inserted, -1 for the last one
+	argDigits   string // the numeric argument being typed
+	arg         int    // the numeric argument for the key being handled
+	hasArg      bool
+	argRepeats  int // how many times the key was queued for the argument
+
 	// concurency
 	mutex sync.Mutex
 
@@ -233,6 +286,12 @@ func NewInstance() *Instance {
 	rl.InfoFormatting = seqFgBlue
 	rl.HintFormatting = "\x1b[2m"
 	rl.evtKeyPress = make(map[string]func(string, []rune, int) *EventReturn)
+	rl.keymaps = make(map[string]map[string]binding)
+	rl.customActions = make(map[string]func(string, []rune, int) *EventReturn)
+	rl.BindAction(KeymapEmacs, "Ctrl-X Ctrl-E", "edit-command-line")
+	rl.BindAction(KeymapEmacs, "Ctrl-X Ctrl-U", "redo")
+	rl.BindAction(KeymapNormal, "Ctrl-R", "redo")
+	rl.BindAction(KeymapNormal, "Ctrl-X Ctrl-E", "edit-command-line")
 	rl.TempDirectory = os.TempDir()
 	rl.Searcher = func(needle string, haystack []string) []string {
 		suggs := make([]string, 0)
@@ -0,0 +1,264 @@
+package readline
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Keymap modes that keys can be bound in.
+const (
+	KeymapEmacs  = "emacs"  // Emacs input mode
+	KeymapInsert = "insert" // Vim insert mode
+	KeymapNormal = "normal" // Vim normal mode
+)
+
+// binding is what bound keys do: either run a named action, or a function.
+type binding struct {
+	action string
+	fn     func()
+}
+
+// queuedKey is a key to handle before reading more input.
+type queuedKey struct {
+	key     string
+	unbound bool // handle the key like it isn't bound
+	repeat  bool // queued again for a numeric argument
+}
+
+// keyNames are the names of keys that don't type a character.
+var keyNames = map[string]string{
+	"enter":     "\r",
+	"return":    "\r",
+	"tab":       "\t",
+	"escape":    "\x1b",
+	"esc":       "\x1b",
+	"backspace": string(rune(charBackspace2)),
+	"space":     " ",
+	"up":        seqUp,
+	"down":      seqDown,
+	"right":     seqForwards,
+	"left":      seqBackwards,
+	"home":      seqHome,
+	"end":       seqEnd,
+	"delete":    seqDelete,
+	"page-up":   seqPageUp,
+	"pageup":    seqPageUp,
+	"page-down": seqPageDown,
+	"pagedown":  seqPageDown,
+	"shift-tab": seqShiftTab,
+	"ctrl-left": seqCtrlLeftArrow,
+	"ctrl-right": seqCtrlRightArrow,
+}
+
+// keyAliases are other sequences terminals send for the same keys,
+// mapped to the one bindings are made with.
+var keyAliases = map[string]string{
+	seqHomeSc:      seqHome,
+	seqEndSc:       seqEnd,
+	seqDelete2:     seqDelete,
+	"\x1bOA":       seqUp,
+	"\x1bOB":       seqDown,
+	"\x1bOC":       seqForwards,
+	"\x1bOD":       seqBackwards,
+	"\x1bOH":       seqHome,
+	"\x1bOF":       seqEnd,
+	seqCtrlDelete2: seqCtrlDelete,
+}
+
+// ParseKeys parses a key sequence like `Ctrl-X Ctrl-E` into the input
+// sent for each of the keys. Keys are separated by spaces, and can be
+// a character, a name like `Enter`, `Tab`, `Up` or `Page-Down`, or
+// either of those with a `Ctrl-` or `Alt-` modifier.
+func ParseKeys(seq string) ([]string, error) {
+	fields := strings.Fields(seq)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty key sequence")
+	}
+
+	keys := make([]string, len(fields))
+	for i, field := range fields {
+		key, err := parseKey(field)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+	}
+
+	return keys, nil
+}
+
+func parseKey(key string) (string, error) {
+	if utf8.RuneCountInString(key) == 1 {
+		return key, nil
+	}
+
+	lower := strings.ToLower(key)
+	if seq, ok := keyNames[lower]; ok {
+		return seq, nil
+	}
+
+	switch {
+	case strings.HasPrefix(lower, "alt-") || strings.HasPrefix(lower, "m-"):
+		_, rest, _ := strings.Cut(key, "-")
+		seq, err := parseKey(rest)
+		if err != nil {
+			return "", err
+		}
+		return "\x1b" + seq, nil
+
+	case strings.HasPrefix(lower, "ctrl-") || strings.HasPrefix(lower, "c-"):
+		_, rest, _ := strings.Cut(lower, "-")
+		if rest == "space" || rest == "@" {
+			return "\x00", nil
+		}
+		if len(rest) == 1 {
+			c := rest[0]
+			switch {
+			case c >= 'a' && c <= 'z':
+				return string(rune(c - 'a' + 1)), nil
+			case c >= '[' && c <= '_':
+				return string(rune(c - 'A' + 1)), nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("invalid key %s", key)
+}
+
+func checkKeymapMode(mode string) error {
+	switch mode {
+	case KeymapEmacs, KeymapInsert, KeymapNormal:
+		return nil
+	}
+	return fmt.Errorf("invalid keymap mode %s (expected %s, %s or %s)", mode, KeymapEmacs, KeymapInsert, KeymapNormal)
+}
+
+func (rl *Instance) bind(mode, seq string, b binding) error {
+	if err := checkKeymapMode(mode); err != nil {
+		return err
+	}
+	keys, err := ParseKeys(seq)
+	if err != nil {
+		return err
+	}
+
+	if rl.keymaps[mode] == nil {
+		rl.keymaps[mode] = map[string]binding{}
+	}
+	rl.keymaps[mode][strings.Join(keys, "\x00")] = b
+
+	return nil
+}
+
+// Bind binds a key sequence (as parsed by ParseKeys) to a function in
+// a keymap mode. The line is redrawn after the function has been called.
+func (rl *Instance) Bind(mode, seq string, fn func()) error {
+	return rl.bind(mode, seq, binding{fn: fn})
+}
+
+// BindAction binds a key sequence (as parsed by ParseKeys) to
+// the editor action with the passed name in a keymap mode.
+func (rl *Instance) BindAction(mode, seq, action string) error {
+	if !rl.hasAction(action) {
+		return fmt.Errorf("unknown editor action %s", action)
+	}
+
+	return rl.bind(mode, seq, binding{action: action})
+}
+
+// Unbind removes the binding for a key sequence in a keymap mode,
+// so the keys go back to what they do by default.
+func (rl *Instance) Unbind(mode, seq string) error {
+	if err := checkKeymapMode(mode); err != nil {
+		return err
+	}
+	keys, err := ParseKeys(seq)
+	if err != nil {
+		return err
+	}
+
+	delete(rl.keymaps[mode], strings.Join(keys, "\x00"))
+	return nil
+}
+
+// keymapMode returns the keymap mode for the current input mode,
+// or an empty string if keys can't be bound in it (like when a
+// Vim operator is waiting for a motion).
+func (rl *Instance) keymapMode() string {
+	if rl.InputMode != Vim {
+		return KeymapEmacs
+	}
+
+	switch rl.modeViMode {
+	case VimInsert:
+		return KeymapInsert
+	case VimKeys:
+		return KeymapNormal
+	}
+	return ""
+}
+
+// handleBinding runs what is bound to the keys that were pressed, if anything.
+// It returns whether the key was handled. Keys that start a bound sequence
+// are held until the rest of it is pressed.
+func (rl *Instance) handleBinding(key string) bool {
+	keymap := rl.keymaps[rl.keymapMode()]
+	if len(keymap) == 0 {
+		rl.pendingKeys = nil
+		return false
+	}
+
+	if alias, ok := keyAliases[key]; ok {
+		key = alias
+	}
+	seq := strings.Join(append(rl.pendingKeys, key), "\x00")
+
+	if b, ok := keymap[seq]; ok {
+		held := append(rl.pendingKeys, key)
+		rl.pendingKeys = nil
+		if b.action != "digit-argument" && rl.repeatArgument(held) {
+			return true
+		}
+		return rl.runBinding(b, key)
+	}
+
+	for bound := range keymap {
+		if strings.HasPrefix(bound, seq + "\x00") {
+			rl.pendingKeys = append(rl.pendingKeys, key)
+			rl.thisCommand = rl.lastCommand
+			return true
+		}
+	}
+
+	// if the keys held so far don't make up a bound sequence after all,
+	// the first one is handled like it isn't bound, and the rest again
+	held := rl.pendingKeys
+	rl.pendingKeys = nil
+	if len(held) == 0 {
+		return false
+	}
+
+	queue := []queuedKey{{key: held[0], unbound: true}}
+	for _, k := range append(held[1:], key) {
+		queue = append(queue, queuedKey{key: k})
+	}
+	rl.keyQueue = append(queue, rl.keyQueue...)
+
+	return true
+}
+
+func (rl *Instance) runBinding(b binding, key string) bool {
+	if b.action != "" {
+		if rl.runAction(b.action, key) {
+			return false
+		}
+	} else {
+		b.fn()
+		rl.updateHelpers()
+	}
+
+	// what the binding did is undone as one step
+	rl.undoAppendHistory()
+	return true
+}
@@ -0,0 +1,63 @@
+package readline
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKeys(t *testing.T) {
+	type TestParseKeysT struct {
+		Seq      string
+		Expected []string
+		Err      bool
+	}
+
+	tests := []TestParseKeysT{
+		{Seq: "a", Expected: []string{"a"}},
+		{Seq: "é", Expected: []string{"é"}},
+		{Seq: "Ctrl-X Ctrl-E", Expected: []string{"\x18", "\x05"}},
+		{Seq: "  C-x   c-e ", Expected: []string{"\x18", "\x05"}},
+		{Seq: "Ctrl-Space", Expected: []string{"\x00"}},
+		{Seq: "Ctrl-@", Expected: []string{"\x00"}},
+		{Seq: "Ctrl-_", Expected: []string{"\x1f"}},
+		{Seq: "Alt-.", Expected: []string{"\x1b."}},
+		{Seq: "Alt-c", Expected: []string{"\x1bc"}},
+		{Seq: "Alt-C", Expected: []string{"\x1bC"}},
+		{Seq: "M-b", Expected: []string{"\x1bb"}},
+		{Seq: "Alt-Ctrl-Y", Expected: []string{"\x1b\x19"}},
+		{Seq: "Alt-Up", Expected: []string{"\x1b" + seqUp}},
+		{Seq: "Enter", Expected: []string{"\r"}},
+		{Seq: "tab", Expected: []string{"\t"}},
+		{Seq: "Escape", Expected: []string{"\x1b"}},
+		{Seq: "Backspace", Expected: []string{string(rune(charBackspace2))}},
+		{Seq: "Page-Down", Expected: []string{seqPageDown}},
+		{Seq: "Ctrl-Left", Expected: []string{seqCtrlLeftArrow}},
+		{Seq: "Shift-Tab", Expected: []string{seqShiftTab}},
+		{Seq: "Escape d d", Expected: []string{"\x1b", "d", "d"}},
+		{Seq: "", Err: true},
+		{Seq: "   ", Err: true},
+		{Seq: "Ctrl-", Err: true},
+		{Seq: "Ctrl-1", Err: true},
+		{Seq: "Ctrl-Up", Err: true},
+		{Seq: "Hyper-a", Err: true},
+		{Seq: "Alt-Nope", Err: true},
+		{Seq: "Ctrl-X Bogus", Err: true},
+	}
+
+	for _, test := range tests {
+		keys, err := ParseKeys(test.Seq)
+		if test.Err {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %q", test.Seq, keys)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", test.Seq, err)
+			continue
+		}
+		if !reflect.DeepEqual(keys, test.Expected) {
+			t.Errorf("%q: expected %q, got %q", test.Seq, test.Expected, keys)
+		}
+	}
+}
@@ -44,6 +44,7 @@ func (rl *Instance) echo() {
 		// Go back to prompt position, and clear everything below
 		moveCursorBackwards(GetTermWidth())
 		moveCursorUp(rl.posY)
+		rl.bufprint(seqClearScreenBelow)
 
 		// Print the prompt
 		rl.bufprint(string(rl.realPrompt))
@@ -56,12 +57,17 @@ func (rl *Instance) echo() {
 			line = rl.line
 		}
 
-		// Print the input line with optional syntax highlighting
+		// Print the input line with optional syntax highlighting,
+		// and the Vim visual selection
+		display := string(line)
 		if rl.SyntaxHighlighter != nil {
-			rl.bufprint(rl.SyntaxHighlighter(line))
-		} else {
-			rl.bufprint(string(line))
+			display = rl.SyntaxHighlighter(line)
+		}
+		if rl.InputMode == Vim && rl.modeViMode == VimVisual {
+			start, end := rl.viVisualRange()
+			display = highlightRange(display, start, end)
 		}
+		rl.bufprint(rl.displayLine(display))
 		rl.bufprint(seqClearScreenBelow)
 
 	}
@@ -79,6 +85,60 @@ func (rl *Instance) echo() {
 	unhideCursor()
 }
 
+// displayLine prepares the line for printing: tabs are printed as spaces
+// (as wide as getWidth counts them), and lines after a newline start
+// at the beginning of the terminal line, after the continue prompt.
+func (rl *Instance) displayLine(line string) string {
+	line = strings.ReplaceAll(line, "\t", strings.Repeat(" ", tabWidth))
+	return strings.ReplaceAll(line, "\n", "\r\n"+rl.ContinuePrompt)
+}
+
+// lineStart returns where the line of a multi-line input that pos is on starts.
+func lineStart(line []rune, pos int) int {
+	for pos > 0 && line[pos-1] != '\n' {
+		pos--
+	}
+	return pos
+}
+
+// lineEnd returns where the line of a multi-line input that pos is on ends.
+func lineEnd(line []rune, pos int) int {
+	for pos < len(line) && line[pos] != '\n' {
+		pos++
+	}
+	return pos
+}
+
+// moveLine moves the cursor to the line above (-1) or below (1) it in
+// a multi-line input, keeping to the same column when the line is long
+// enough. It returns false if there is no line there.
+func (rl *Instance) moveLine(dir int) bool {
+	start := lineStart(rl.line, rl.pos)
+	col := rl.pos - start
+
+	var target int
+	if dir < 0 {
+		if start == 0 {
+			return false
+		}
+		target = lineStart(rl.line, start-1)
+	} else {
+		end := lineEnd(rl.line, rl.pos)
+		if end == len(rl.line) {
+			return false
+		}
+		target = end + 1
+	}
+
+	rl.pos = target + col
+	if end := lineEnd(rl.line, target); rl.pos > end {
+		rl.pos = end
+	}
+	rl.updateHelpers()
+
+	return true
+}
+
 func (rl *Instance) insert(r []rune) {
 	for {
 		// I don't really understand why `0` is creaping in at the end of the
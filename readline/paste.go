@@ -0,0 +1,89 @@
+package readline
+
+import (
+	"strings"
+)
+
+// readPaste reads the rest of a bracketed paste, of which data (after the
+// paste start sequence) has been read already. It returns the pasted text
+// and any input that came after the paste end sequence.
+func (rl *Instance) readPaste(data string) (text, rest string, err error) {
+	for {
+		if idx := strings.Index(data, seqPasteEnd); idx != -1 {
+			return data[:idx], data[idx+len(seqPasteEnd):], nil
+		}
+
+		b := make([]byte, 1024)
+		i, err := rl.read(b)
+		if err != nil {
+			return "", "", err
+		}
+		data += string(b[:i])
+	}
+}
+
+// cleanPaste makes pasted text fit for inserting in the line: line endings
+// become newlines, trailing ones are removed, and control characters
+// other than tabs and newlines are dropped.
+func cleanPaste(text string) []rune {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	text = strings.TrimRight(text, "\n")
+
+	cleaned := make([]rune, 0, len(text))
+	for _, r := range text {
+		if (r < 32 && r != '\t' && r != '\n') || r == charBackspace2 {
+			continue
+		}
+		cleaned = append(cleaned, r)
+	}
+
+	return cleaned
+}
+
+// paste inserts pasted text at the cursor as it is, without handling
+// any of it as keys. Pasted newlines stay in the line, so a multi-line
+// paste is only run once the line is accepted.
+func (rl *Instance) paste(text string) {
+	pasted := cleanPaste(text)
+	if rl.Paste != nil {
+		var ok bool
+		pasted, ok = rl.Paste(pasted)
+		if !ok {
+			rl.updateHelpers()
+			return
+		}
+	}
+	if len(pasted) == 0 {
+		rl.updateHelpers()
+		return
+	}
+
+	// a paste into a completion search is part of what's searched for
+	if rl.modeAutoFind || rl.modeTabFind {
+		rl.resetVirtualComp(false)
+		rl.updateTabFind([]rune(strings.ReplaceAll(string(pasted), "\n", " ")))
+		rl.renderHelpers()
+		return
+	}
+
+	rl.resetVirtualComp(false)
+	rl.clearHelpers()
+	rl.resetHelpers()
+
+	rl.histNavIdx = 0
+	if rl.InputMode == Vim && rl.modeViMode != VimInsert {
+		// like `p`, paste after the character the cursor is on
+		if len(rl.line) > 0 {
+			rl.pos++
+		}
+		rl.modeViMode = VimKeys
+		rl.insert(pasted)
+		rl.pos--
+		rl.refreshVimStatus()
+		rl.updateHelpers()
+		return
+	}
+
+	rl.insert(pasted)
+}
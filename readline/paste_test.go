@@ -0,0 +1,56 @@
+package readline
+
+import (
+	"testing"
+)
+
+func TestReadPaste(t *testing.T) {
+	type TestReadPasteT struct {
+		Data     string
+		Expected string
+		Rest     string
+	}
+
+	tests := []TestReadPasteT{
+		{Data: "ls" + seqPasteEnd, Expected: "ls"},
+		{Data: seqPasteEnd, Expected: ""},
+		{Data: "echo a\necho b" + seqPasteEnd + "\r", Expected: "echo a\necho b", Rest: "\r"},
+		// keys in a paste aren't handled, only the end of it
+		{Data: "\x1b[A\t" + seqPasteEnd + "x" + seqPasteEnd, Expected: "\x1b[A\t", Rest: "x" + seqPasteEnd},
+	}
+
+	for _, test := range tests {
+		text, rest, err := NewInstance().readPaste(test.Data)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", test.Data, err)
+			continue
+		}
+		if text != test.Expected || rest != test.Rest {
+			t.Errorf("%q: expected %q and %q after it, got %q and %q", test.Data, test.Expected, test.Rest, text, rest)
+		}
+	}
+}
+
+func TestCleanPaste(t *testing.T) {
+	type TestCleanPasteT struct {
+		Text     string
+		Expected string
+	}
+
+	tests := []TestCleanPasteT{
+		{Text: "ls -la", Expected: "ls -la"},
+		{Text: "echo a\r\necho b\r\n", Expected: "echo a\necho b"},
+		{Text: "echo a\recho b\r", Expected: "echo a\necho b"},
+		{Text: "\n\nls\n\n", Expected: "\n\nls"},
+		{Text: "a\tb", Expected: "a\tb"},
+		{Text: "a\x1b[31mb\x7f\x00c", Expected: "a[31mbc"},
+		{Text: "héllo wörld", Expected: "héllo wörld"},
+		{Text: "", Expected: ""},
+	}
+
+	for _, test := range tests {
+		if cleaned := string(cleanPaste(test.Text)); cleaned != test.Expected {
+			t.Errorf("%q: expected %q, got %q", test.Text, test.Expected, cleaned)
+		}
+	}
+}
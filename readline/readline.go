@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"strings"
 	"syscall"
 )
 
@@ -14,12 +15,20 @@ var rxMultiline = regexp.MustCompile(`[\r\n]+`)
 // Readline displays the readline prompt.
 // It will return a string (user entered data) or an error.
 func (rl *Instance) Readline() (string, error) {
-	fd := int(os.Stdin.Fd())
-	state, err := MakeRaw(fd)
-	if err != nil {
-		return "", err
+	if rl.input == nil {
+		fd := int(os.Stdin.Fd())
+		state, err := MakeRaw(fd)
+		if err != nil {
+			return "", err
+		}
+		rl.termState = state
+		print(seqBracketedPasteOn)
+		defer func() {
+			print(seqBracketedPasteOff)
+			Restore(fd, state)
+			rl.termState = nil
+		}()
 	}
-	defer Restore(fd, state)
 
 	// In Vim mode, we always start in Input mode. The prompt needs this.
 	rl.modeViMode = VimInsert
@@ -41,6 +50,14 @@ func (rl *Instance) Readline() (string, error) {
 	rl.pos = 0
 	rl.posY = 0
 	rl.tcPrefix = ""
+	rl.pendingKeys = nil
+	rl.keyQueue = nil
+	rl.accepted = false
+	rl.lastCommand, rl.thisCommand = "", ""
+	rl.argDigits = ""
+	rl.viPending = 0
+	rl.viChange = false
+	rl.viRecording = false
 
 	// Completion && infos init
 	rl.resetInfoText()
@@ -50,7 +67,9 @@ func (rl *Instance) Readline() (string, error) {
 	// History Init
 	// We need this set to the last command, so that we can access it quickly
 	rl.histOffset = 0
-	rl.viUndoHistory = []undoItem{{line: "", pos: 0}}
+	rl.viUndoHistory = nil
+	rl.redoHistory = nil
+	rl.undoBurst = false
 
 	// Multisplit
 	if len(rl.multisplit) > 0 {
@@ -74,13 +93,23 @@ func (rl *Instance) Readline() (string, error) {
 
 	// Start handling keystrokes. Classified by subject for most.
 	for {
-		rl.viUndoSkipAppend = false
+		// keys repeated for a numeric argument are undone with the first one
+		if !rl.undoRepeatPending() {
+			rl.undoStart()
+		}
+		rl.lastCommand, rl.thisCommand = rl.thisCommand, ""
+		rl.hasArg, rl.argRepeats = false, 0
 		b := make([]byte, 1024)
 		var i int
 
-		if !rl.skipStdinRead {
+		var queued *queuedKey
+		if len(rl.keyQueue) != 0 {
+			queued = &rl.keyQueue[0]
+			rl.keyQueue = rl.keyQueue[1:]
+			i = copy(b, queued.key)
+		} else if !rl.skipStdinRead {
 			var err error
-			i, err = os.Stdin.Read(b)
+			i, err = rl.read(b)
 			if err != nil {
 				if errors.Is(err, syscall.EAGAIN) {
 					err = syscall.SetNonblock(syscall.Stdin, false)
@@ -93,6 +122,29 @@ func (rl *Instance) Readline() (string, error) {
 		}
 
 		rl.skipStdinRead = false
+
+		// Pasted text is inserted as it is, instead of being handled as keys.
+		// Keys pressed right before a paste are handled first.
+		if idx := strings.Index(string(b[:i]), seqPasteStart); idx > 0 {
+			rl.keyQueue = append([]queuedKey{{key: string(b[idx:i])}}, rl.keyQueue...)
+			for j := idx; j < i; j++ {
+				b[j] = 0
+			}
+			i = idx
+		} else if idx == 0 {
+			text, rest, err := rl.readPaste(string(b[len(seqPasteStart):i]))
+			if err != nil {
+				return "", err
+			}
+			if rest != "" {
+				rl.keyQueue = append([]queuedKey{{key: rest}}, rl.keyQueue...)
+			}
+
+			rl.paste(text)
+			rl.undoAppendHistory()
+			continue
+		}
+
 		r := []rune(string(b))
 		if rl.RawInputCallback != nil {
 			rl.RawInputCallback(r[:i])
@@ -126,34 +178,31 @@ func (rl *Instance) Readline() (string, error) {
 		}
 
 		s := string(r[:i])
-		if rl.evtKeyPress[s] != nil {
-			rl.clearHelpers()
-
-			ret := rl.evtKeyPress[s](s, rl.line, rl.pos)
+		rl.viRecord(s)
+		if (queued == nil || !queued.unbound) && rl.handleBinding(s) {
+			if rl.accepted {
+				rl.accepted = false
+				rl.carridgeReturn()
+				return string(rl.line), nil
+			}
+			continue
+		}
 
-			rl.clearLine()
-			rl.line = append(ret.NewLine, []rune{}...)
-			rl.updateHelpers() // rl.echo
-			rl.pos = ret.NewPos
+		if !rl.isDigitArgument(s) && rl.repeatArgument([]string{s}) {
+			continue
+		}
 
-			if ret.ClearHelpers {
-				rl.resetHelpers()
-			} else {
-				rl.updateHelpers()
-			}
+		if rl.evtKeyPress[s] != nil {
+			rl.clearHelpers()
 
-			if len(ret.InfoText) > 0 {
-				rl.infoText = ret.InfoText
-				rl.clearHelpers()
-				rl.renderHelpers()
+			forward, closeLine := rl.applyEvent(rl.evtKeyPress[s](s, rl.line, rl.pos))
+			if closeLine {
+				rl.carridgeReturn()
+				return string(rl.line), nil
 			}
-			if !ret.ForwardKey {
+			if !forward {
 				continue
 			}
-			if ret.CloseReadline {
-				rl.clearHelpers()
-				return string(rl.line), nil
-			}
 		}
 
 		// Before anything: we can never be both in modeTabCompletion and compConfirmWait,
@@ -190,37 +239,14 @@ func (rl *Instance) Readline() (string, error) {
 
 		// Clear screen
 		case charCtrlL:
-			print(seqClearScreen)
-			print(seqCursorTopLeft)
-			if rl.Multiline {
-				fmt.Println(rl.mainPrompt)
-			}
-			print(seqClearScreenBelow)
-
-			rl.resetInfoText()
-			rl.getInfoText()
-			rl.renderHelpers()
+			rl.clearScreen()
 
 		// Line Editing ------------------------------------------------------------------------------------
 		case charCtrlU:
-			if rl.modeTabCompletion {
-				rl.resetVirtualComp(true)
-			}
-			// Delete everything from the beginning of the line to the cursor position
-			rl.saveBufToRegister(rl.line[:rl.pos])
-			rl.deleteToBeginning()
-			rl.resetHelpers()
-			rl.updateHelpers()
+			rl.backwardKillLine()
 
 		case charCtrlK:
-			if rl.modeTabCompletion {
-				rl.resetVirtualComp(true)
-			}
-			// Delete everything after the cursor position
-			rl.saveBufToRegister(rl.line[rl.pos:])
-			rl.deleteToEnd()
-			rl.resetHelpers()
-			rl.updateHelpers()
+			rl.killLine()
 
 		case charBackspace, charBackspace2:
 			// When currently in history completion, we refresh and automatically
@@ -232,7 +258,6 @@ func (rl *Instance) Readline() (string, error) {
 				// Then update the printing, with the new candidate
 				rl.updateVirtualComp()
 				rl.renderHelpers()
-				rl.viUndoSkipAppend = true
 				continue
 			}
 
@@ -241,7 +266,6 @@ func (rl *Instance) Readline() (string, error) {
 				rl.resetVirtualComp(false)
 				rl.backspaceTabFind()
 				rl.renderHelpers()
-				rl.viUndoSkipAppend = true
 			} else {
 				// Always cancel any virtual completion
 				rl.resetVirtualComp(false)
@@ -258,58 +282,43 @@ func (rl *Instance) Readline() (string, error) {
 				}
 
 				// Else emacs deletes a character
-				rl.backspace(false)
-				rl.renderHelpers()
+				rl.backwardDeleteChar()
 			}
 
 		// Emacs Bindings ----------------------------------------------------------------------------------
 		case charCtrlW:
-			if rl.modeTabCompletion {
-				rl.resetVirtualComp(false)
-			}
 			// This is only available in Insert mode
 			if rl.modeViMode != VimInsert {
 				continue
 			}
-			rl.saveToRegister(rl.viJumpB(tokeniseLine))
-			rl.viDeleteByAdjust(rl.viJumpB(tokeniseLine))
-			rl.updateHelpers()
+			rl.backwardKillWord()
 
 		case charCtrlY:
-			if rl.modeTabCompletion {
-				rl.resetVirtualComp(false)
+			rl.yank()
+
+		case charCtrlT:
+			// This is only available in Insert mode
+			if rl.modeViMode != VimInsert {
+				continue
 			}
-			// paste after the cursor position
-			rl.viUndoSkipAppend = true
-			buffer := rl.pasteFromRegister()
-			rl.insert(buffer)
-			rl.updateHelpers()
+			rl.transposeChars()
 
 		case charCtrlE:
-			if rl.modeTabCompletion {
-				rl.resetVirtualComp(false)
-			}
 			// This is only available in Insert mode
 			if rl.modeViMode != VimInsert {
 				continue
 			}
-			if len(rl.line) > 0 {
-				rl.pos = len(rl.line)
+			if rl.acceptHint(false) {
+				continue
 			}
-			rl.viUndoSkipAppend = true
-			rl.updateHelpers()
+			rl.endOfLine()
 
 		case charCtrlA:
-			if rl.modeTabCompletion {
-				rl.resetVirtualComp(false)
-			}
 			// This is only available in Insert mode
 			if rl.modeViMode != VimInsert {
 				continue
 			}
-			rl.viUndoSkipAppend = true
-			rl.pos = 0
-			rl.updateHelpers()
+			rl.beginningOfLine()
 
 		// Command History ---------------------------------------------------------------------------------
 
@@ -317,25 +326,7 @@ func (rl *Instance) Readline() (string, error) {
 		// but because this is a sequence, the alternative history code
 		// trigger is in the below rl.escapeSeq(r) function.
 		case charCtrlR:
-			rl.resetVirtualComp(false)
-			// For some modes only, if we are in vim Keys mode,
-			// we toogle back to insert mode. For others, we return
-			// without getting the completions.
-			if rl.modeViMode != VimInsert {
-				rl.modeViMode = VimInsert
-				rl.computePrompt()
-			}
-
-			rl.mainHist = true // false before
-			rl.searchMode = HistoryFind
-			rl.modeAutoFind = true
-			rl.modeTabCompletion = true
-
-			rl.modeTabFind = true
-			rl.updateTabFind([]rune{})
-			rl.updateVirtualComp()
-			rl.renderHelpers()
-			rl.viUndoSkipAppend = true
+			rl.historySearch()
 
 		// Tab Completion & Completion Search ---------------------------------------------------------------
 		case charTab:
@@ -343,48 +334,7 @@ func (rl *Instance) Readline() (string, error) {
 			if rl.InputMode == Vim && rl.modeViMode != VimInsert {
 				continue
 			}
-
-			// If we have asked for completions, already printed, and we want to move selection.
-			if rl.modeTabCompletion && !rl.compConfirmWait {
-				rl.tabCompletionSelect = true
-				rl.moveTabCompletionHighlight(1, 0)
-				rl.updateVirtualComp()
-				rl.renderHelpers()
-				rl.viUndoSkipAppend = true
-			} else {
-				// Else we might be asked to confirm printing (if too many suggestions), or not.
-				rl.getTabCompletion()
-
-				// If too many completions and no yet confirmed, ask user for completion
-				// comps, lines := rl.getCompletionCount()
-				// if ((lines > GetTermLength()) || (lines > rl.MaxTabCompleterRows)) && !rl.compConfirmWait {
-				//         sentence := fmt.Sprintf("%s show all %d completions (%d lines) ? tab to confirm",
-				//                 FOREWHITE, comps, lines)
-				//         rl.promptCompletionConfirm(sentence)
-				//         continue
-				// }
-
-				rl.compConfirmWait = false
-				rl.modeTabCompletion = true
-
-				// Also here, if only one candidate is available, automatically
-				// insert it and don't bother printing completions.
-				// Quit the tab completion mode to avoid asking to the user
-				// to press Enter twice to actually run the command.
-				if rl.hasOneCandidate() {
-					rl.insertCandidate()
-
-					// Refresh first, and then quit the completion mode
-					rl.updateHelpers() // REDUNDANT WITH getTabCompletion()
-					rl.viUndoSkipAppend = true
-					rl.resetTabCompletion()
-					continue
-				}
-
-				rl.updateHelpers() // REDUNDANT WITH getTabCompletion()
-				rl.viUndoSkipAppend = true
-				continue
-			}
+			rl.complete()
 
 		case charCtrlF:
 			rl.resetVirtualComp(true)
@@ -408,7 +358,6 @@ func (rl *Instance) Readline() (string, error) {
 			}
 
 			rl.updateTabFind([]rune{})
-			rl.viUndoSkipAppend = true
 
 		case charCtrlG:
 			if rl.modeAutoFind && rl.searchMode == HistoryFind {
@@ -426,8 +375,7 @@ func (rl *Instance) Readline() (string, error) {
 			}
 
 		case charCtrlUnderscore:
-			rl.undoLast()
-			rl.viUndoSkipAppend = true
+			rl.undo()
 
 		case '\r':
 			fallthrough
@@ -470,6 +418,18 @@ func (rl *Instance) Readline() (string, error) {
 
 				continue
 			}
+
+			// An unfinished command goes on to the next line.
+			if rl.Incomplete != nil && rl.Incomplete(rl.line) {
+				if rl.InputMode == Vim && rl.modeViMode != VimInsert {
+					rl.pos = len(rl.line)
+					rl.modeViMode = VimInsert
+					rl.refreshVimStatus()
+				}
+				rl.insert([]rune{'\n'})
+				rl.undoAppendHistory()
+				continue
+			}
 			rl.carridgeReturn()
 			return string(rl.line), nil
 
@@ -516,7 +476,6 @@ func (rl *Instance) Readline() (string, error) {
 				rl.updateTabFind(r[:i])
 				rl.updateVirtualComp()
 				rl.renderHelpers()
-				rl.viUndoSkipAppend = true
 				continue
 			}
 
@@ -525,7 +484,6 @@ func (rl *Instance) Readline() (string, error) {
 				rl.resetVirtualComp(false)
 				rl.updateTabFind(r[:i])
 				rl.renderHelpers()
-				rl.viUndoSkipAppend = true
 				continue
 			} else {
 				rl.resetVirtualComp(false)
@@ -538,6 +496,12 @@ func (rl *Instance) Readline() (string, error) {
 			rl.clearHelpers()
 		}
 
+		if rl.accepted {
+			rl.accepted = false
+			rl.carridgeReturn()
+			return string(rl.line), nil
+		}
+
 		rl.undoAppendHistory()
 	}
 }
@@ -559,6 +523,10 @@ func (rl *Instance) editorInput(r []rune) {
 		rl.viDelete(r[0])
 		rl.refreshVimStatus()
 
+	case VimVisual:
+		rl.viVisual(r[0])
+		rl.refreshVimStatus()
+
 	case VimReplaceOnce:
 		rl.modeViMode = VimKeys
 		rl.deleteX()
@@ -572,6 +540,7 @@ func (rl *Instance) editorInput(r []rune) {
 			}
 			rl.insert([]rune{char})
 		}
+		rl.undoKind = undoInsert
 		rl.refreshVimStatus()
 
 	default:
@@ -583,6 +552,7 @@ func (rl *Instance) editorInput(r []rune) {
 		// We don't need it when inserting text.
 		rl.histNavIdx = 0
 		rl.insert(r)
+		rl.undoKind = undoInsert
 		rl.writeHintText()
 	}
 
@@ -609,12 +579,24 @@ func (rl *Instance) viEscape(r []rune) {
 		rl.refreshVimStatus()
 		return
 	}
+
+	// Escape cancels a key waiting for another, and visual mode.
+	if len(r) == 1 && r[0] == 27 {
+		rl.viPending = 0
+		rl.viIsYanking = false
+		rl.viChange = false
+		rl.viIteration = ""
+		if rl.modeViMode == VimVisual || rl.modeViMode == VimDelete {
+			rl.viVisualExit()
+			rl.refreshVimStatus()
+		}
+	}
 }
 
 func (rl *Instance) escapeSeq(r []rune) {
 	switch string(r) {
 	// Vim escape sequences & dispatching --------------------------------------------------------
-	case string(charEscape):
+	case string(rune(charEscape)):
 		switch {
 		case rl.modeAutoFind:
 			rl.resetVirtualComp(true)
@@ -657,7 +639,6 @@ func (rl *Instance) escapeSeq(r []rune) {
 			rl.renderHelpers()
 
 		}
-		rl.viUndoSkipAppend = true
 
 	// Tab completion movements ------------------------------------------------------------------
 	case seqShiftTab:
@@ -667,7 +648,6 @@ func (rl *Instance) escapeSeq(r []rune) {
 			rl.updateVirtualComp()
 			rl.tabCompletionReverse = false
 			rl.renderHelpers()
-			rl.viUndoSkipAppend = true
 			return
 		}
 
@@ -681,10 +661,10 @@ func (rl *Instance) escapeSeq(r []rune) {
 			rl.renderHelpers()
 			return
 		}
-		rl.mainHist = true
-		rl.walkHistory(1)
-		moveCursorForwards(len(rl.line) - rl.pos)
-		rl.pos = len(rl.line)
+		if rl.moveLine(-1) {
+			return
+		}
+		rl.walkHistoryLine(1)
 
 	case seqDown:
 		if rl.modeTabCompletion {
@@ -694,10 +674,10 @@ func (rl *Instance) escapeSeq(r []rune) {
 			rl.renderHelpers()
 			return
 		}
-		rl.mainHist = true
-		rl.walkHistory(-1)
-		moveCursorForwards(len(rl.line) - rl.pos)
-		rl.pos = len(rl.line)
+		if rl.moveLine(1) {
+			return
+		}
+		rl.walkHistoryLine(-1)
 
 	case seqForwards:
 		if rl.modeTabCompletion {
@@ -707,12 +687,10 @@ func (rl *Instance) escapeSeq(r []rune) {
 			rl.renderHelpers()
 			return
 		}
-		if (rl.modeViMode == VimInsert && rl.pos < len(rl.line)) ||
-			(rl.modeViMode != VimInsert && rl.pos < len(rl.line)-1) {
-			rl.moveCursorByAdjust(1)
+		if rl.acceptHint(false) {
+			return
 		}
-		rl.updateHelpers()
-		rl.viUndoSkipAppend = true
+		rl.forwardChar()
 
 	case seqBackwards:
 		if rl.modeTabCompletion {
@@ -724,9 +702,7 @@ func (rl *Instance) escapeSeq(r []rune) {
 			rl.renderHelpers()
 			return
 		}
-		rl.moveCursorByAdjust(-1)
-		rl.viUndoSkipAppend = true
-		rl.updateHelpers()
+		rl.backwardChar()
 
 	// Registers -------------------------------------------------------------------------------
 	case seqAltQuote:
@@ -738,7 +714,6 @@ func (rl *Instance) escapeSeq(r []rune) {
 		rl.searchMode = RegisterFind
 		// Else we might be asked to confirm printing (if too many suggestions), or not.
 		rl.getTabCompletion()
-		rl.viUndoSkipAppend = true
 		rl.renderHelpers()
 
 	// Movement -------------------------------------------------------------------------------
@@ -747,19 +722,15 @@ func (rl *Instance) escapeSeq(r []rune) {
 		rl.updateHelpers()
 		return
 	case seqCtrlRightArrow:
-		rl.insert(rl.hintText)
+		if !rl.HintInfo {
+			rl.insert(rl.hintText)
+		}
 		rl.moveCursorByAdjust(rl.viJumpW(tokeniseLine))
 		rl.updateHelpers()
 		return
 
 	case seqDelete,seqDelete2:
-		if rl.modeTabFind {
-			rl.backspaceTabFind()
-		} else {
-			if (rl.pos < len(rl.line)) {
-				rl.deleteBackspace(true)
-			}
-		}
+		rl.deleteChar()
 
 	case seqHome, seqHomeSc:
 		if rl.modeTabCompletion {
@@ -767,15 +738,16 @@ func (rl *Instance) escapeSeq(r []rune) {
 		}
 		rl.moveCursorByAdjust(-rl.pos)
 		rl.updateHelpers()
-		rl.viUndoSkipAppend = true
 
 	case seqEnd, seqEndSc:
 		if rl.modeTabCompletion {
 			return
 		}
+		if rl.acceptHint(false) {
+			return
+		}
 		rl.moveCursorByAdjust(len(rl.line) - rl.pos)
 		rl.updateHelpers()
-		rl.viUndoSkipAppend = true
 
 	case seqAltB:
 		if rl.modeTabCompletion {
@@ -786,10 +758,7 @@ func (rl *Instance) escapeSeq(r []rune) {
 		if rl.modeViMode != VimInsert {
 			return
 		}
-
-		move := rl.emacsBackwardWord(tokeniseLine)
-		rl.moveCursorByAdjust(-move)
-		rl.updateHelpers()
+		rl.backwardWord()
 
 	case seqAltF:
 		if rl.modeTabCompletion {
@@ -801,27 +770,13 @@ func (rl *Instance) escapeSeq(r []rune) {
 			return
 		}
 
-		move := rl.emacsForwardWord(tokeniseLine)
-		rl.moveCursorByAdjust(move)
-		rl.updateHelpers()
-
-	case seqAltR:
-		rl.resetVirtualComp(false)
-		// For some modes only, if we are in vim Keys mode,
-		// we toogle back to insert mode. For others, we return
-		// without getting the completions.
-		if rl.modeViMode != VimInsert {
-			rl.modeViMode = VimInsert
+		if rl.acceptHint(true) {
+			return
 		}
+		rl.forwardWord()
 
-		rl.mainHist = false // true before
-		rl.searchMode = HistoryFind
-		rl.modeAutoFind = true
-		rl.modeTabCompletion = true
-
-		rl.modeTabFind = true
-		rl.updateTabFind([]rune{})
-		rl.viUndoSkipAppend = true
+	case seqAltR:
+		rl.altHistorySearch()
 
 	case seqAltBackspace:
 		if rl.modeTabCompletion {
@@ -832,57 +787,62 @@ func (rl *Instance) escapeSeq(r []rune) {
 			return
 		}
 
-		rl.saveToRegister(rl.viJumpB(tokeniseLine))
-		rl.viDeleteByAdjust(rl.viJumpB(tokeniseLine))
-		rl.updateHelpers()
+		rl.backwardKillWord()
 
 	case seqCtrlDelete, seqCtrlDelete2, seqAltD:
+		rl.killWord()
+
+	case seqAltDelete:
 		if rl.modeTabCompletion {
 			rl.resetVirtualComp(false)
 		}
-		rl.saveToRegister(rl.emacsForwardWord(tokeniseLine))
-		// vi delete, emacs forward, funny huh
-		rl.viDeleteByAdjust(rl.emacsForwardWord(tokeniseLine))
+		adjust := rl.emacsBackwardWord(tokeniseLine)
+		rl.kill(rl.line[rl.pos-adjust:rl.pos], true)
+		rl.viDeleteByAdjust(-adjust)
 		rl.updateHelpers()
 
-	case seqAltDelete:
+	// Emacs editing -------------------------------------------------------------------------
+	case seqAltY, seqAltDot, seqAltCtrlY, seqAltU, seqAltL, seqAltC, seqAltT:
 		if rl.modeTabCompletion {
 			rl.resetVirtualComp(false)
 		}
-		rl.saveToRegister(-rl.emacsBackwardWord(tokeniseLine))
-		rl.viDeleteByAdjust(-rl.emacsBackwardWord(tokeniseLine))
-		rl.updateHelpers()
+		// This is only available in Insert mode
+		if rl.modeViMode != VimInsert {
+			return
+		}
+
+		switch string(r) {
+		case seqAltY:
+			rl.yankPop()
+		case seqAltDot:
+			rl.yankArg(true)
+		case seqAltCtrlY:
+			rl.yankArg(false)
+		case seqAltU:
+			rl.upcaseWord()
+		case seqAltL:
+			rl.downcaseWord()
+		case seqAltC:
+			rl.capitalizeWord()
+		case seqAltT:
+			rl.transposeWords()
+		}
 
 	default:
 		if rl.modeTabFind {
 			return
 		}
-		// alt+numeric append / delete
-		if len(r) == 2 && '1' <= r[1] && r[1] <= '9' {
+		if rl.isDigitArgument(string(r)) && r[1] == '-' {
+			rl.digitArgument('-')
+			return
+		}
+		// alt+numeric delete, or numeric argument
+		if len(r) == 2 && '0' <= r[1] && r[1] <= '9' {
 			if rl.modeViMode == VimDelete {
 				rl.viDelete(r[1])
 				return
 			}
-
-			line, err := rl.mainHistory.GetLine(rl.mainHistory.Len() - 1)
-			if err != nil {
-				return
-			}
-			if !rl.mainHist && rl.altHistory != nil {
-				line, err = rl.altHistory.GetLine(rl.altHistory.Len() - 1)
-				if err != nil {
-					return
-				}
-			}
-
-			tokens, _, _ := tokeniseSplitSpaces([]rune(line), 0)
-			pos := int(r[1]) - 48 // convert ASCII to integer
-			if pos > len(tokens) {
-				return
-			}
-			rl.insert([]rune(tokens[pos-1]))
-		} else {
-			rl.viUndoSkipAppend = true
+			rl.digitArgument(r[1])
 		}
 	}
 }
@@ -929,7 +889,7 @@ func (rl *Instance) allowMultiline(data []byte) bool {
 
 		b := make([]byte, 1024)
 
-		i, err := os.Stdin.Read(b)
+		i, err := rl.read(b)
 		if err != nil {
 			return false
 		}
@@ -0,0 +1,46 @@
+package readline
+
+import (
+	"io"
+	"strings"
+)
+
+// keyInput has keys for Readline to read, one at a time like they
+// are typed. Reading after the last key fails with io.EOF.
+type keyInput []string
+
+func (k *keyInput) Read(b []byte) (int, error) {
+	if len(*k) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(b, (*k)[0])
+	*k = (*k)[1:]
+
+	return n, nil
+}
+
+// readKeys runs Readline with keys typed into it. The line it returns
+// is only there if the keys end with Enter, otherwise what was typed
+// is left in rl.line.
+func readKeys(rl *Instance, keys ...string) (string, error) {
+	in := keyInput(keys)
+	rl.input = &in
+
+	return rl.Readline()
+}
+
+// splitKeys splits s into keys, where every character is a key
+// and <Esc> is the escape key.
+func splitKeys(s string) []string {
+	keys := []string{}
+	for i, part := range strings.Split(s, "<Esc>") {
+		if i != 0 {
+			keys = append(keys, string(rune(charEscape)))
+		}
+		for _, r := range part {
+			keys = append(keys, string(r))
+		}
+	}
+
+	return keys
+}
@@ -96,7 +96,6 @@ func (rl *Instance) updateVirtualComp() {
 			// Quit the tab completion mode to avoid asking to the user to press
 			// Enter twice to actually run the command
 			// Refresh first, and then quit the completion mode
-			rl.viUndoSkipAppend = true
 			rl.resetTabCompletion()
 		} else {
 
@@ -178,6 +177,9 @@ func (rl *Instance) resetVirtualComp(drop bool) {
 
 	// Reset virtual
 	rl.clearVirtualComp()
+
+	// the completion is undone by itself
+	rl.undoCheckpoint()
 }
 
 // trimTrailing - When the group to which the current candidate
@@ -209,7 +211,6 @@ func (rl *Instance) viDeleteByAdjustVirtual(adjust int) {
 
 	switch {
 	case adjust == 0:
-		rl.viUndoSkipAppend = true
 		return
 	case rl.pos+adjust == len(rl.lineComp)-1:
 		newLine = rl.lineComp[:rl.pos]
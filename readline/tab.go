@@ -513,7 +513,6 @@ func (rl *Instance) promptCompletionConfirm(sentence string) {
 	rl.infoText = []rune(sentence)
 
 	rl.compConfirmWait = true
-	rl.viUndoSkipAppend = true
 
 	rl.renderHelpers()
 }
@@ -42,6 +42,14 @@ func print(s string) {
 	os.Stdout.WriteString(s)
 }
 
+// read reads keys typed in the terminal.
+func (rl *Instance) read(b []byte) (int, error) {
+	if rl.input != nil {
+		return rl.input.Read(b)
+	}
+	return os.Stdin.Read(b)
+}
+
 var rxAnsiSgr = regexp.MustCompile("\x1b\\[[:;0-9]+m")
 
 // Gets the number of runes in a string
@@ -5,38 +5,103 @@ type undoItem struct {
 	pos  int
 }
 
+// Kinds of edits, so consecutive ones of the same kind can be
+// undone together.
+const (
+	undoInsert = "insert" // typed characters
+)
+
+// undoStart remembers the line before a key is handled,
+// to know whether the key changed it.
+func (rl *Instance) undoStart() {
+	rl.undoBefore = undoItem{line: string(rl.line), pos: rl.pos}
+	rl.undoKind = ""
+}
+
+// undoAppendHistory adds the line from before the key that was handled
+// (and its repeats for a numeric argument) to the undo history, if the key
+// changed it. Characters typed one after the other are undone together,
+// so only the line before the first is added.
 func (rl *Instance) undoAppendHistory() {
-	defer func() { rl.viUndoSkipAppend = false }()
+	if rl.undoRepeatPending() {
+		return
+	}
+	if string(rl.line) == rl.undoBefore.line {
+		if rl.pos != rl.undoBefore.pos {
+			rl.undoBurst = false
+		}
+		return
+	}
+
+	if rl.undoKind != undoInsert || !rl.undoBurst {
+		rl.viUndoHistory = append(rl.viUndoHistory, rl.undoBefore)
+	}
+	rl.undoBurst = rl.undoKind == undoInsert
+	rl.redoHistory = nil
+}
 
-	if rl.viUndoSkipAppend {
+// undoCheckpoint ends an undo step in the middle of handling a key,
+// so the change made so far (like inserting a completion) is undone
+// separately from what the key does after it.
+func (rl *Instance) undoCheckpoint() {
+	if rl.undoRepeatPending() {
 		return
 	}
+	rl.undoKind = ""
+	rl.undoAppendHistory()
+	rl.undoBurst = false
+	rl.undoStart()
+}
 
-	rl.viUndoHistory = append(rl.viUndoHistory, undoItem{
-		line: string(rl.line),
-		pos:  rl.pos,
-	})
+// undoRepeatPending returns whether the key being handled is still to be
+// repeated for a numeric argument, so the repeats are one step with it.
+func (rl *Instance) undoRepeatPending() bool {
+	return len(rl.keyQueue) != 0 && rl.keyQueue[0].repeat
 }
 
+// undoLast goes back to how the line was before the last edit.
 func (rl *Instance) undoLast() {
-	var undo undoItem
-	for {
-		if len(rl.viUndoHistory) == 0 {
-			return
-		}
-		undo = rl.viUndoHistory[len(rl.viUndoHistory)-1]
-		rl.viUndoHistory = rl.viUndoHistory[:len(rl.viUndoHistory)-1]
-		if string(undo.line) != string(rl.line) {
-			break
-		}
+	if len(rl.viUndoHistory) == 0 {
+		return
+	}
+	undo := rl.viUndoHistory[len(rl.viUndoHistory)-1]
+	rl.viUndoHistory = rl.viUndoHistory[:len(rl.viUndoHistory)-1]
+
+	rl.redoHistory = append(rl.redoHistory, undoItem{line: string(rl.line), pos: rl.pos})
+	rl.restoreUndoItem(undo)
+}
+
+// redoLast makes the last edit that was undone again.
+func (rl *Instance) redoLast() {
+	if len(rl.redoHistory) == 0 {
+		return
 	}
+	redo := rl.redoHistory[len(rl.redoHistory)-1]
+	rl.redoHistory = rl.redoHistory[:len(rl.redoHistory)-1]
+
+	rl.viUndoHistory = append(rl.viUndoHistory, undoItem{line: string(rl.line), pos: rl.pos})
+	rl.restoreUndoItem(redo)
+}
 
-	rl.line = []rune(undo.line)
-	rl.pos = undo.pos
+func (rl *Instance) restoreUndoItem(item undoItem) {
+	if rl.modeTabCompletion {
+		rl.resetVirtualComp(true)
+		rl.resetTabCompletion()
+	}
+	rl.clearHelpers()
 
+	rl.line = []rune(item.line)
+	rl.pos = item.pos
+	if rl.pos > len(rl.line) {
+		rl.pos = len(rl.line)
+	}
 	if rl.modeViMode != VimInsert && len(rl.line) > 0 && rl.pos == len(rl.line) {
 		rl.pos--
 	}
 
+	// undoing isn't an edit itself
+	rl.undoStart()
+	rl.undoBurst = false
+
 	rl.updateHelpers()
 }
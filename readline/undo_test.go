@@ -0,0 +1,127 @@
+package readline
+
+import (
+	"testing"
+)
+
+const keyUndo = string(rune(charCtrlUnderscore))
+
+func TestUndoGrouping(t *testing.T) {
+	rl := NewInstance()
+	if err := rl.BindAction(KeymapEmacs, "Alt-a", "beginning-of-line"); err != nil {
+		t.Fatal(err)
+	}
+
+	// typing somewhere else starts a new undo step
+	readKeys(rl, "e", "c", "h", "o", "\x1ba", "x", keyUndo)
+	if string(rl.line) != "echo" {
+		t.Errorf("expected %q after one undo, got %q", "echo", string(rl.line))
+	}
+
+	readKeys(rl, "e", "c", "h", "o", "\x1ba", "x", keyUndo, keyUndo)
+	if string(rl.line) != "" {
+		t.Errorf("expected an empty line after two undos, got %q", string(rl.line))
+	}
+
+	readKeys(rl, "e", "c", "h", "o", "\x1ba", "x", keyUndo, keyUndo, "\x18", "\x15")
+	if string(rl.line) != "echo" {
+		t.Errorf("expected %q after redo, got %q", "echo", string(rl.line))
+	}
+}
+
+func TestUndoBinding(t *testing.T) {
+	rl := NewInstance()
+	if err := rl.BindAction(KeymapEmacs, "Alt-z", "backward-kill-line"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rl.Bind(KeymapEmacs, "Alt-x", func() {
+		rl.line = []rune("replaced")
+		rl.pos = len(rl.line)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	type TestUndoBindingT struct {
+		Keys     []string
+		Expected string
+	}
+
+	typed := []string{"l", "s", " ", "-", "a"}
+	tests := []TestUndoBindingT{
+		{Keys: append(typed, "\x1bz"), Expected: ""},
+		{Keys: append(typed, "\x1bz", keyUndo), Expected: "ls -a"},
+		{Keys: append(typed, "\x1bz", "\x1bx"), Expected: "replaced"},
+		{Keys: append(typed, "\x1bz", "\x1bx", keyUndo), Expected: ""},
+		{Keys: append(typed, "\x1bz", "\x1bx", keyUndo, keyUndo), Expected: "ls -a"},
+	}
+
+	for _, test := range tests {
+		readKeys(rl, test.Keys...)
+		if string(rl.line) != test.Expected {
+			t.Errorf("%q: expected %q, got %q", test.Keys, test.Expected, string(rl.line))
+		}
+	}
+}
+
+func TestUndoSteps(t *testing.T) {
+	type TestUndoStepsT struct {
+		Name     string
+		Keys     []string
+		Expected string
+	}
+
+	tests := []TestUndoStepsT{
+		{
+			Name:     "paste",
+			Keys:     []string{"l", "s", " ", seqPasteStart + "a b\nc" + seqPasteEnd, keyUndo},
+			Expected: "ls ",
+		},
+		{
+			Name:     "paste after typing",
+			Keys:     []string{"l", "s", " ", seqPasteStart + "a b" + seqPasteEnd, keyUndo, keyUndo},
+			Expected: "",
+		},
+		{
+			// the key repeated for the argument is one step
+			Name:     "numeric argument",
+			Keys:     []string{"a", " ", "b", " ", "c", "\x1b2", "\x17", keyUndo},
+			Expected: "a b c",
+		},
+		{
+			Name:     "numeric argument repeating a kill",
+			Keys:     []string{"a", " ", "b", " ", "c", "\x1b2", "\x17"},
+			Expected: "a ",
+		},
+		{
+			Name:     "numeric argument typing",
+			Keys:     []string{"a", "\x1ba", "\x1b3", "x", keyUndo},
+			Expected: "a",
+		},
+		{
+			Name:     "completion",
+			Keys:     []string{"e", "c", "\t", keyUndo},
+			Expected: "ec",
+		},
+		{
+			Name:     "typing after completion",
+			Keys:     []string{"e", "c", "\t", "h", "i", keyUndo},
+			Expected: "echo ",
+		},
+	}
+
+	for _, test := range tests {
+		rl := NewInstance()
+		rl.BindAction(KeymapEmacs, "Alt-a", "beginning-of-line")
+		rl.TabCompleter = func(line []rune, pos int, _ DelayedTabContext) (string, []*CompletionGroup) {
+			return string(line[:pos]), []*CompletionGroup{{
+				Suggestions: []string{"echo"},
+				DisplayType: TabDisplayGrid,
+			}}
+		}
+
+		readKeys(rl, test.Keys...)
+		if string(rl.line) != test.Expected {
+			t.Errorf("%s: expected %q, got %q", test.Name, test.Expected, string(rl.line))
+		}
+	}
+}
@@ -2,7 +2,6 @@ package readline
 
 import (
 	"fmt"
-	"strings"
 
 	"golang.org/x/text/width"
 )
@@ -64,43 +63,44 @@ func (rl *Instance) updateReferences() {
 	} else {
 		curLine = rl.line
 	}
-	fullLine := getWidth(curLine)
-	cPosLine := getWidth(curLine[:rl.pos])
+	pos := rl.pos
+	if pos > len(curLine) {
+		pos = len(curLine)
+	}
 
 	// We need the X offset of the whole line
-	toEndLine := rl.promptLen + fullLine
-	fullOffset := toEndLine / GetTermWidth()
-	rl.fullY = fullOffset + strings.Count(string(curLine), "\n")
-	fullRest := toEndLine % GetTermWidth()
-	rl.fullX = fullRest
-
-	if fullRest == 0 && fullOffset > 0 {
+	rl.fullX, rl.fullY = rl.lineCoords(curLine)
+	if rl.fullX == 0 && rl.fullY > 0 && len(curLine) > 0 && curLine[len(curLine)-1] != '\n' {
 		print("\n")
 	}
 
 	// Use rl.pos value to get the offset to go TO/FROM the CURRENT POSITION
-	lineToCursorPos := rl.promptLen + cPosLine
-	offsetToCursor := lineToCursorPos / GetTermWidth()
-	cPosRest := lineToCursorPos % GetTermWidth()
-
-	// If we are at the end of line
-	if fullLine == rl.pos {
-		rl.posY = fullOffset
-
-		if fullRest == 0 {
-			rl.posX = 0
-		} else if fullRest > 0 {
-			rl.posX = fullRest
-		}
-	} else if rl.pos < fullLine {
-		// If we are somewhere in the middle of the line
-		rl.posY = offsetToCursor
+	rl.posX, rl.posY = rl.lineCoords(curLine[:pos])
+}
 
-		if cPosRest == 0 {
-		} else if cPosRest > 0 {
-			rl.posX = cPosRest
+// lineCoords returns the column and row (counted from the prompt's)
+// that the cursor is at after the prompt and line are printed.
+// The line wraps at the terminal width, and after newlines.
+func (rl *Instance) lineCoords(line []rune) (x, y int) {
+	termWidth := GetTermWidth()
+	x = rl.promptLen
+	for _, r := range line {
+		if r == '\n' {
+			// a line as wide as the terminal doesn't wrap before the newline
+			if x > 0 {
+				y += (x - 1) / termWidth
+			}
+			x = getRealLength(rl.ContinuePrompt)
+			y++
+			continue
 		}
+		x += getWidth([]rune{r})
 	}
+	// the last line may wrap too
+	y += x / termWidth
+	x = x % termWidth
+
+	return x, y
 }
 
 func (rl *Instance) resetHelpers() {
@@ -1,8 +1,8 @@
 package readline
 
 import (
-	"fmt"
 	"strconv"
+	"strings"
 )
 
 // InputMode - The shell input mode
@@ -23,6 +23,7 @@ const (
 	VimReplaceMany
 	VimDelete
 	VimKeys
+	VimVisual
 )
 
 var (
@@ -31,12 +32,17 @@ var (
 	VimReplaceManyStr = "[R]"
 	VimDeleteStr      = "[D]"
 	VimKeysStr        = "[N]"
+	VimVisualStr      = "[S]"
 )
 
 type ViAction int
 const (
 	VimActionYank = iota
 	VimActionPaste
+	VimActionDelete
+	VimActionChange
+	VimActionVisual
+	VimActionRepeat
 )
 
 var (
@@ -66,6 +72,22 @@ func (rl *Instance) vi(r rune) {
 		}
 	}
 
+	// A key waiting for another one (like `f` for the character
+	// to find) takes this one, and so does `y` for a text object.
+	if rl.viPending != 0 {
+		op := rune(0)
+		if rl.viIsYanking {
+			op = 'y'
+			rl.viIsYanking = false
+		}
+		rl.viPendingKey(r, op)
+		return
+	}
+	if rl.viIsYanking && isViPendingKey(r) {
+		rl.viPending = r
+		return
+	}
+
 	// If we are on register mode and one is already selected,
 	// check if the key stroke to be evaluated is acting on it
 	// or not: if not, we cancel the active register now.
@@ -85,7 +107,6 @@ func (rl *Instance) vi(r rune) {
 		}
 		rl.modeViMode = VimInsert
 		rl.viIteration = ""
-		rl.viUndoSkipAppend = true
 
 	case 'A':
 		if len(rl.line) > 0 {
@@ -93,7 +114,6 @@ func (rl *Instance) vi(r rune) {
 		}
 		rl.modeViMode = VimInsert
 		rl.viIteration = ""
-		rl.viUndoSkipAppend = true
 
 	case 'b':
 		if rl.viIsYanking {
@@ -102,7 +122,6 @@ func (rl *Instance) vi(r rune) {
 			rl.viIsYanking = false
 			return
 		}
-		rl.viUndoSkipAppend = true
 		vii := rl.getViIterations()
 		for i := 1; i <= vii; i++ {
 			rl.moveCursorByAdjust(rl.viJumpB(tokeniseLine))
@@ -115,15 +134,21 @@ func (rl *Instance) vi(r rune) {
 			rl.viIsYanking = false
 			return
 		}
-		rl.viUndoSkipAppend = true
 		vii := rl.getViIterations()
 		for i := 1; i <= vii; i++ {
 			rl.moveCursorByAdjust(rl.viJumpB(tokeniseSplitSpaces))
 		}
 
+	case 'c':
+		rl.modeViMode = VimDelete
+		rl.viChange = true
+
+	case 'C':
+		rl.getViIterations()
+		rl.viOperate('c', rl.pos, len(rl.line))
+
 	case 'd':
 		rl.modeViMode = VimDelete
-		rl.viUndoSkipAppend = true
 
 	case 'D':
 		rl.saveBufToRegister(rl.line[rl.pos-1:])
@@ -144,7 +169,6 @@ func (rl *Instance) vi(r rune) {
 			return
 		}
 
-		rl.viUndoSkipAppend = true
 		vii := rl.getViIterations()
 		for i := 1; i <= vii; i++ {
 			rl.moveCursorByAdjust(rl.viJumpE(tokeniseLine))
@@ -158,35 +182,40 @@ func (rl *Instance) vi(r rune) {
 			return
 		}
 
-		rl.viUndoSkipAppend = true
 		vii := rl.getViIterations()
 		for i := 1; i <= vii; i++ {
 			rl.moveCursorByAdjust(rl.viJumpE(tokeniseSplitSpaces))
 		}
 
+	case 'f', 'F', 't', 'T':
+		rl.viPending = r
+
 	case 'h':
 		if rl.pos > 0 {
 			rl.pos--
 		}
-		rl.viUndoSkipAppend = true
 
 	case 'i':
 		rl.modeViMode = VimInsert
 		rl.viIteration = ""
-		rl.viUndoSkipAppend = true
 		rl.registers.resetRegister()
 
 	case 'I':
 		rl.modeViMode = VimInsert
 		rl.viIteration = ""
-		rl.viUndoSkipAppend = true
 		rl.pos = 0
 
 	case 'j':
+		if rl.moveLine(1) {
+			return
+		}
 		// Set the main history as the one we navigate, by default
 		rl.mainHist = true
 		rl.walkHistory(-1)
 	case 'k':
+		if rl.moveLine(-1) {
+			return
+		}
 		// Set the main history as the one we navigate, by default
 		rl.mainHist = true
 		rl.walkHistory(1)
@@ -196,11 +225,9 @@ func (rl *Instance) vi(r rune) {
 			(rl.modeViMode != VimInsert && rl.pos < len(rl.line)-1) {
 			rl.pos++
 		}
-		rl.viUndoSkipAppend = true
 
 	case 'p':
 		// paste after the cursor position
-		rl.viUndoSkipAppend = true
 		rl.pos++
 
 		buffer := rl.pasteFromRegister()
@@ -213,7 +240,6 @@ func (rl *Instance) vi(r rune) {
 
 	case 'P':
 		// paste before
-		rl.viUndoSkipAppend = true
 		buffer := rl.pasteFromRegister()
 		vii := rl.getViIterations()
 		rl.ViActionCallback(VimActionPaste, []string{activeRegister, string(buffer)})
@@ -224,48 +250,30 @@ func (rl *Instance) vi(r rune) {
 	case 'r':
 		rl.modeViMode = VimReplaceOnce
 		rl.viIteration = ""
-		rl.viUndoSkipAppend = true
 
 	case 'R':
 		rl.modeViMode = VimReplaceMany
 		rl.viIteration = ""
-		rl.viUndoSkipAppend = true
 
-	case 'u':
-		rl.undoLast()
-		rl.viUndoSkipAppend = true
-
-	case 'v':
-		rl.clearHelpers()
-		var multiline []rune
-		if rl.GetMultiLine == nil {
-			multiline = rl.line
-		} else {
-			multiline = rl.GetMultiLine(rl.line)
+	case 's':
+		end := rl.pos + rl.getViIterations()
+		if end > len(rl.line) {
+			end = len(rl.line)
 		}
+		rl.viOperate('c', rl.pos, end)
 
-		// Keep the previous cursor position
-		//prev := rl.pos
+	case 'S':
+		rl.getViIterations()
+		rl.viOperate('c', 0, len(rl.line))
 
-		new, err := rl.StartEditorWithBuffer(multiline, "")
-		if err != nil || len(new) == 0 || string(new) == string(multiline) {
-			fmt.Println(err)
-			rl.viUndoSkipAppend = true
-			return
-		}
+	case 'u':
+		rl.undoLast()
 
-		// Clean the shell and put the new buffer, with adjusted pos if needed.
-		rl.clearLine()
-		rl.line = new
-		rl.pos = len(rl.line)
-		/*if prev > len(rl.line) {
-		} else {
-			rl.pos = prev
-		}*/
+	case 'v', 'V':
+		rl.viVisualEnter(r == 'V')
 
 	case 'w':
 		// If we were not yanking
-		rl.viUndoSkipAppend = true
 		// If the input line is empty, we don't do anything
 		if rl.pos == 0 && len(rl.line) == 0 {
 			return
@@ -291,7 +299,6 @@ func (rl *Instance) vi(r rune) {
 		if rl.pos == 0 && len(rl.line) == 0 {
 			return
 		}
-		rl.viUndoSkipAppend = true
 
 		if rl.viIsYanking {
 			vii := rl.getViIterations()
@@ -325,12 +332,10 @@ func (rl *Instance) vi(r rune) {
 			rl.viIsYanking = false
 		}
 		rl.viIsYanking = true
-		rl.viUndoSkipAppend = true
 
 	case 'Y':
 		rl.ViActionCallback(VimActionYank, []string{activeRegister, string(rl.line)})
 		rl.saveBufToRegister(rl.line)
-		rl.viUndoSkipAppend = true
 
 	case '[':
 		if rl.viIsYanking {
@@ -338,7 +343,6 @@ func (rl *Instance) vi(r rune) {
 			rl.viIsYanking = false
 			return
 		}
-		rl.viUndoSkipAppend = true
 		rl.moveCursorByAdjust(rl.viJumpPreviousBrace())
 
 	case ']':
@@ -347,7 +351,6 @@ func (rl *Instance) vi(r rune) {
 			rl.viIsYanking = false
 			return
 		}
-		rl.viUndoSkipAppend = true
 		rl.moveCursorByAdjust(rl.viJumpNextBrace())
 
 	case '$':
@@ -357,7 +360,24 @@ func (rl *Instance) vi(r rune) {
 			return
 		}
 		rl.pos = len(rl.line)
-		rl.viUndoSkipAppend = true
+
+	case ';', ',':
+		op := rune(0)
+		if rl.viIsYanking {
+			op = 'y'
+			rl.viIsYanking = false
+		}
+		rl.viRepeatFind(r == ',', op)
+
+	case '.':
+		rl.viRepeat()
+
+	case '^':
+		rl.viIteration = ""
+		rl.pos = 0
+		for rl.pos < len(rl.line)-1 && (rl.line[rl.pos] == ' ' || rl.line[rl.pos] == '\t') {
+			rl.pos++
+		}
 
 	case '%':
 		if rl.viIsYanking {
@@ -365,7 +385,6 @@ func (rl *Instance) vi(r rune) {
 			rl.viIsYanking = false
 			return
 		}
-		rl.viUndoSkipAppend = true
 		rl.moveCursorByAdjust(rl.viJumpBracket())
 
 	case '"':
@@ -377,13 +396,83 @@ func (rl *Instance) vi(r rune) {
 		rl.registers.registerSelectWait = true
 
 	default:
+		// 0 goes to the start of the line, unless it's part of a count
+		if r == '0' && rl.viIteration == "" {
+			rl.pos = 0
+			return
+		}
 		if r <= '9' && '0' <= r {
 			rl.viIteration += string(r)
 		}
-		rl.viUndoSkipAppend = true
 	}
 }
 
+// viAction calls ViActionCallback, if there is one.
+func (rl *Instance) viAction(action ViAction, args ...string) {
+	if rl.ViActionCallback != nil {
+		rl.ViActionCallback(action, args)
+	}
+}
+
+// viRecord keeps the keys of the change being made in Vim normal mode, from
+// the key that starts it until normal mode is back with nothing waiting,
+// so `.` can repeat it. Keys that didn't change the line are forgotten.
+func (rl *Instance) viRecord(key string) {
+	if rl.InputMode != Vim {
+		return
+	}
+
+	idle := rl.modeViMode == VimKeys && rl.viPending == 0 && !rl.viIsYanking &&
+		rl.viIteration == "" && !rl.registers.registerSelectWait && !rl.registers.onRegister
+	if rl.viRecording && idle {
+		rl.viRecording = false
+		if rl.viRecInsert || string(rl.line) != rl.viRecLine {
+			rl.viDotKeys = rl.viRecKeys
+		}
+	}
+
+	if rl.viRecording {
+		rl.viRecKeys = append(rl.viRecKeys, key)
+		if rl.modeViMode == VimInsert {
+			rl.viRecInsert = true
+		}
+		return
+	}
+
+	if !idle {
+		return
+	}
+	switch key {
+	// moving through history and undoing change the line,
+	// but aren't changes to repeat
+	case "j", "k", "u", ".", string(rune(charCtrlR)):
+		return
+	}
+	rl.viRecording = true
+	rl.viRecKeys = []string{key}
+	rl.viRecLine = string(rl.line)
+	rl.viRecInsert = false
+}
+
+// viRepeat repeats the last change made in normal mode,
+// as many times as the count says.
+func (rl *Instance) viRepeat() {
+	if len(rl.viDotKeys) == 0 {
+		rl.viIteration = ""
+		return
+	}
+
+	vii := rl.getViIterations()
+	queue := []queuedKey{}
+	for i := 1; i <= vii; i++ {
+		for _, key := range rl.viDotKeys {
+			queue = append(queue, queuedKey{key: key})
+		}
+	}
+	rl.keyQueue = append(queue, rl.keyQueue...)
+	rl.viAction(VimActionRepeat, strings.Join(rl.viDotKeys, ""))
+}
+
 func (rl *Instance) getViIterations() int {
 	i, _ := strconv.Atoi(rl.viIteration)
 	if i < 1 {
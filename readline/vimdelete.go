@@ -4,14 +4,55 @@ import (
 	"strings"
 )
 
-// vimDelete -
+// vimDelete - Apply a key to the `d` (or `c`) operator that is waiting for a motion.
 func (rl *Instance) viDelete(r rune) {
+	op := 'd'
+	action := ViAction(VimActionDelete)
+	if rl.viChange {
+		op = 'c'
+		action = VimActionChange
+	}
+
+	// Text objects and finding a character take another key, and act on
+	// the line themselves.
+	if isViPendingKey(r) && rl.viPending == 0 {
+		rl.viPending = r
+		return
+	}
+	if rl.viPending != 0 || r == ';' || r == ',' {
+		rl.modeViMode = VimKeys
+		rl.viChange = false
+		if rl.viPending != 0 {
+			rl.viPendingKey(r, op)
+		} else {
+			rl.viRepeatFind(r == ',', op)
+		}
+		return
+	}
+
+	// `cw` changes to the end of the word, like `ce`
+	if rl.viChange && r == 'w' {
+		r = 'e'
+	} else if rl.viChange && r == 'W' {
+		r = 'E'
+	}
 
 	// We are allowed to type iterations after a delete ('d') command.
 	// in which case we don't exit the delete mode. The next thing typed
 	// will thus be dispatched back here (like "2d4 then w).
 	if !(r <= '9' && '0' <= r) {
-		defer func() { rl.modeViMode = VimKeys }()
+		register := string(rl.registers.currentRegister)
+		before := append([]rune{}, rl.line...)
+		defer func() {
+			rl.modeViMode = VimKeys
+			if rl.viChange {
+				rl.modeViMode = VimInsert
+				rl.viChange = false
+			}
+			if string(rl.line) != string(before) {
+				rl.viAction(action, register, deletedText(before, rl.line))
+			}
+		}()
 	}
 
 	switch r {
@@ -29,7 +70,11 @@ func (rl *Instance) viDelete(r rune) {
 			rl.viDeleteByAdjust(rl.viJumpB(tokeniseSplitSpaces))
 		}
 
-	case 'd':
+	case 'd', 'c':
+		// dd and cc act on the whole line
+		if (r == 'c') != rl.viChange {
+			return
+		}
 		rl.saveBufToRegister(rl.line)
 		rl.clearLine()
 		rl.resetHelpers()
@@ -71,7 +116,7 @@ func (rl *Instance) viDelete(r rune) {
 		rl.saveBufToRegister(rl.line[rl.pos:])
 		rl.viDeleteByAdjust(len(rl.line) - rl.pos)
 		// Only go back if there is an input
-		if len(rl.line) > 0 {
+		if len(rl.line) > 0 && !rl.viChange {
 			rl.pos--
 		}
 
@@ -87,10 +132,24 @@ func (rl *Instance) viDelete(r rune) {
 		if r <= '9' && '0' <= r {
 			rl.viIteration += string(r)
 		}
-		rl.viUndoSkipAppend = true
 	}
 }
 
+// deletedText returns the text that was deleted from before to get after.
+func deletedText(before, after []rune) string {
+	start := 0
+	for start < len(after) && before[start] == after[start] {
+		start++
+	}
+	end, afterEnd := len(before), len(after)
+	for afterEnd > start && before[end-1] == after[afterEnd-1] {
+		end--
+		afterEnd--
+	}
+
+	return string(before[start:end])
+}
+
 func (rl *Instance) viDeleteByAdjust(adjust int) {
 	var (
 		newLine []rune
@@ -104,7 +163,6 @@ func (rl *Instance) viDeleteByAdjust(adjust int) {
 
 	switch {
 	case adjust == 0:
-		rl.viUndoSkipAppend = true
 		return
 	case rl.pos+adjust == len(rl.line)-1:
 		// This case should normally happen only when we met ALL THOSE CONDITIONS:
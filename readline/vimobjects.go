@@ -0,0 +1,365 @@
+package readline
+
+import (
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+)
+
+var rxTag = regexp.MustCompile(`<(/?)([A-Za-z][^\s/>]*)[^>]*?(/?)>`)
+
+// isViPendingKey returns whether a key in normal mode (or after an
+// operator) waits for another key: f, F, t and T for the character
+// to find, and i and a for the text object.
+func isViPendingKey(r rune) bool {
+	switch r {
+	case 'f', 'F', 't', 'T', 'i', 'a':
+		return true
+	}
+	return false
+}
+
+// viPendingKey handles the key typed after one waiting for it. With an
+// operator (d, c or y), it acts on the text up to the found character or
+// on the text object. Without one, the cursor moves to the found character,
+// or the visual selection becomes the text object.
+func (rl *Instance) viPendingKey(r rune, op rune) {
+	key := rl.viPending
+	rl.viPending = 0
+
+	switch key {
+	case 'f', 'F', 't', 'T':
+		rl.viLastFind = [2]rune{key, r}
+		rl.viFindMotion(key, r, false, op)
+
+	case 'i', 'a':
+		rl.getViIterations()
+		start, end, ok := rl.viTextObject(key, r)
+		if !ok {
+			return
+		}
+		if op != 0 {
+			rl.viOperate(op, start, end)
+			return
+		}
+		rl.viVisualFrom = start
+		rl.pos = start
+		if end > start {
+			rl.pos = end - 1
+		}
+	}
+}
+
+// viFind returns where the count-th character is after (f, t) or before
+// (F, T) the cursor, or next to it for t and T. When repeating t or T,
+// the character the cursor is already next to is skipped.
+func (rl *Instance) viFind(key, char rune, count int, repeat bool) (int, bool) {
+	step := 1
+	if key == 'F' || key == 'T' {
+		step = -1
+	}
+	till := key == 't' || key == 'T'
+
+	start := rl.pos + step
+	if repeat && till {
+		start += step
+	}
+	for i := start; i >= 0 && i < len(rl.line); i += step {
+		if rl.line[i] != char {
+			continue
+		}
+		count--
+		if count == 0 {
+			if till {
+				i -= step
+			}
+			return i, true
+		}
+	}
+
+	return rl.pos, false
+}
+
+// viFindMotion moves to the character found with f, F, t or T,
+// or has the operator act on the text up to it.
+func (rl *Instance) viFindMotion(key, char rune, repeat bool, op rune) {
+	target, ok := rl.viFind(key, char, rl.getViIterations(), repeat)
+	if !ok {
+		return
+	}
+
+	switch {
+	case op == 0:
+		rl.pos = target
+	case target >= rl.pos:
+		rl.viOperate(op, rl.pos, target+1)
+	default:
+		rl.viOperate(op, target, rl.pos)
+	}
+}
+
+// viRepeatFind finds the character of the last f, F, t or T again,
+// the other way for `,`.
+func (rl *Instance) viRepeatFind(reverse bool, op rune) {
+	key, char := rl.viLastFind[0], rl.viLastFind[1]
+	if key == 0 {
+		rl.viIteration = ""
+		return
+	}
+
+	if reverse {
+		if unicode.IsUpper(key) {
+			key = unicode.ToLower(key)
+		} else {
+			key = unicode.ToUpper(key)
+		}
+	}
+	rl.viFindMotion(key, char, true, op)
+}
+
+// viOperate has an operator act on the text from start to end:
+// y yanks it, d deletes it and c deletes it and starts insert mode.
+func (rl *Instance) viOperate(op rune, start, end int) {
+	register := string(rl.registers.currentRegister)
+	text := append([]rune{}, rl.line[start:end]...)
+
+	switch op {
+	case 'y':
+		rl.viAction(VimActionYank, register, string(text))
+		rl.saveBufToRegister(text)
+		rl.pos = start
+
+	case 'd', 'c':
+		if op == 'd' {
+			rl.viAction(VimActionDelete, register, string(text))
+		} else {
+			rl.viAction(VimActionChange, register, string(text))
+		}
+		rl.saveBufToRegister(text)
+
+		line := append([]rune{}, rl.line[:start]...)
+		rl.line = append(line, rl.line[end:]...)
+		rl.pos = start
+		if op == 'c' {
+			rl.modeViMode = VimInsert
+		} else if rl.pos == len(rl.line) && rl.pos > 0 {
+			rl.pos--
+		}
+	}
+
+	rl.updateHelpers()
+}
+
+// viTextObject returns where the text object after i (inner) or a (around)
+// starts and ends around the cursor: w or W for a word, a quote for a quoted
+// string, a bracket (or b or B) for text in brackets, or t for a tag.
+func (rl *Instance) viTextObject(kind, obj rune) (start, end int, ok bool) {
+	if len(rl.line) == 0 {
+		return
+	}
+
+	switch obj {
+	case 'w', 'W':
+		return rl.viWordObject(kind, obj == 'W')
+	case '"', '\'', '`':
+		return rl.viQuoteObject(kind, obj)
+	case '(', ')', 'b':
+		return rl.viBracketObject(kind, '(', ')')
+	case '[', ']':
+		return rl.viBracketObject(kind, '[', ']')
+	case '{', '}', 'B':
+		return rl.viBracketObject(kind, '{', '}')
+	case '<', '>':
+		return rl.viBracketObject(kind, '<', '>')
+	case 't':
+		return rl.viTagObject(kind)
+	}
+
+	return
+}
+
+// viWordClass returns the kind of character for word text objects:
+// spaces, word characters or other ones. Big words are all but spaces.
+func viWordClass(r rune, big bool) int {
+	switch {
+	case unicode.IsSpace(r):
+		return 0
+	case big || isWordRune(r) || r == '_':
+		return 1
+	}
+	return 2
+}
+
+// viWordObject returns the word the cursor is on, and with `a` the spaces
+// after it (or before it when there are none after).
+func (rl *Instance) viWordObject(kind rune, big bool) (start, end int, ok bool) {
+	pos := rl.pos
+	if pos >= len(rl.line) {
+		pos = len(rl.line) - 1
+	}
+	class := func(i int) int { return viWordClass(rl.line[i], big) }
+
+	cls := class(pos)
+	start, end = pos, pos+1
+	for start > 0 && class(start-1) == cls {
+		start--
+	}
+	for end < len(rl.line) && class(end) == cls {
+		end++
+	}
+	if kind == 'i' {
+		return start, end, true
+	}
+
+	switch {
+	case cls == 0:
+		// on spaces, they go with the word after them
+		if end < len(rl.line) {
+			next := class(end)
+			for end < len(rl.line) && class(end) == next {
+				end++
+			}
+		}
+	case end < len(rl.line) && class(end) == 0:
+		for end < len(rl.line) && class(end) == 0 {
+			end++
+		}
+	default:
+		for start > 0 && class(start-1) == 0 {
+			start--
+		}
+	}
+
+	return start, end, true
+}
+
+// viQuoteObject returns the quoted string the cursor is in (or the next one
+// on the line), with the quotes and the spaces after it for `a`.
+func (rl *Instance) viQuoteObject(kind, quote rune) (start, end int, ok bool) {
+	quotes := []int{}
+	for i, r := range rl.line {
+		if r == quote && (i == 0 || rl.line[i-1] != '\\') {
+			quotes = append(quotes, i)
+		}
+	}
+
+	open, close := -1, -1
+	for i := 0; i+1 < len(quotes); i += 2 {
+		if quotes[i] <= rl.pos && rl.pos <= quotes[i+1] || quotes[i] > rl.pos {
+			open, close = quotes[i], quotes[i+1]
+			break
+		}
+	}
+	if open == -1 {
+		return
+	}
+
+	if kind == 'i' {
+		return open + 1, close, true
+	}
+	start, end = open, close+1
+	if end < len(rl.line) && unicode.IsSpace(rl.line[end]) {
+		for end < len(rl.line) && unicode.IsSpace(rl.line[end]) {
+			end++
+		}
+	} else {
+		for start > 0 && unicode.IsSpace(rl.line[start-1]) {
+			start--
+		}
+	}
+
+	return start, end, true
+}
+
+// viBracketObject returns the text in the innermost brackets around
+// the cursor, with the brackets for `a`.
+func (rl *Instance) viBracketObject(kind, open, close rune) (start, end int, ok bool) {
+	pos := rl.pos
+	if pos >= len(rl.line) {
+		pos = len(rl.line) - 1
+	}
+
+	openIdx, depth := -1, 0
+	for i := pos; i >= 0; i-- {
+		switch {
+		case rl.line[i] == close && i != pos:
+			depth++
+		case rl.line[i] == open:
+			depth--
+		}
+		if depth < 0 {
+			openIdx = i
+			break
+		}
+	}
+	if openIdx == -1 {
+		return
+	}
+
+	closeIdx, depth := -1, 0
+	for i := openIdx + 1; i < len(rl.line); i++ {
+		switch rl.line[i] {
+		case open:
+			depth++
+		case close:
+			depth--
+		}
+		if depth < 0 {
+			closeIdx = i
+			break
+		}
+	}
+	if closeIdx == -1 {
+		return
+	}
+
+	if kind == 'i' {
+		return openIdx + 1, closeIdx, true
+	}
+	return openIdx, closeIdx + 1, true
+}
+
+// viTagObject returns the text between the innermost pair of
+// tags (like <b> and </b>) around the cursor, with the tags for `a`.
+func (rl *Instance) viTagObject(kind rune) (start, end int, ok bool) {
+	type tag struct {
+		name       string
+		start, end int
+	}
+
+	line := string(rl.line)
+	runeIdx := func(b int) int { return utf8.RuneCountInString(line[:b]) }
+
+	var open []tag
+	inner := -1
+	for _, m := range rxTag.FindAllStringSubmatchIndex(line, -1) {
+		t := tag{line[m[4]:m[5]], runeIdx(m[0]), runeIdx(m[1])}
+		switch {
+		case m[6] != m[7]:
+			// self closing
+			continue
+		case m[2] == m[3]:
+			open = append(open, t)
+			continue
+		}
+
+		for i := len(open) - 1; i >= 0; i-- {
+			if open[i].name != t.name {
+				continue
+			}
+			o := open[i]
+			open = open[:i]
+			if o.start <= rl.pos && rl.pos < t.end && o.start > inner {
+				inner = o.start
+				if kind == 'i' {
+					start, end = o.end, t.start
+				} else {
+					start, end = o.start, t.end
+				}
+			}
+			break
+		}
+	}
+
+	return start, end, inner != -1
+}
@@ -0,0 +1,85 @@
+package readline
+
+import (
+	"testing"
+)
+
+func TestViTextObject(t *testing.T) {
+	type TestViTextObjectT struct {
+		Line  string
+		Pos   int
+		Kind  rune
+		Obj   rune
+		Start int
+		End   int
+		Ok    bool
+	}
+
+	tests := []TestViTextObjectT{
+		{Line: "echo hello world", Pos: 6, Kind: 'i', Obj: 'w', Start: 5, End: 10, Ok: true},
+		{Line: "echo hello world", Pos: 6, Kind: 'a', Obj: 'w', Start: 5, End: 11, Ok: true},
+		// without spaces after the word, the ones before it go with it
+		{Line: "echo hello", Pos: 7, Kind: 'a', Obj: 'w', Start: 4, End: 10, Ok: true},
+		{Line: "echo  hi", Pos: 4, Kind: 'i', Obj: 'w', Start: 4, End: 6, Ok: true},
+		{Line: "echo  hi", Pos: 4, Kind: 'a', Obj: 'w', Start: 4, End: 8, Ok: true},
+		{Line: "foo.bar", Pos: 1, Kind: 'i', Obj: 'w', Start: 0, End: 3, Ok: true},
+		{Line: "foo.bar", Pos: 1, Kind: 'i', Obj: 'W', Start: 0, End: 7, Ok: true},
+		// the cursor can be after the end of the line in insert mode
+		{Line: "ls", Pos: 2, Kind: 'i', Obj: 'w', Start: 0, End: 2, Ok: true},
+
+		{Line: `echo "a b" c`, Pos: 6, Kind: 'i', Obj: '"', Start: 6, End: 9, Ok: true},
+		{Line: `echo "a b" c`, Pos: 6, Kind: 'a', Obj: '"', Start: 5, End: 11, Ok: true},
+		{Line: `echo "a b"`, Pos: 6, Kind: 'a', Obj: '"', Start: 4, End: 10, Ok: true},
+		// on either quote
+		{Line: `echo "a b" c`, Pos: 5, Kind: 'i', Obj: '"', Start: 6, End: 9, Ok: true},
+		{Line: `echo "a b" c`, Pos: 9, Kind: 'i', Obj: '"', Start: 6, End: 9, Ok: true},
+		// before the string, the next one is used
+		{Line: `echo "a b" c`, Pos: 0, Kind: 'i', Obj: '"', Start: 6, End: 9, Ok: true},
+		{Line: `echo "a b" c`, Pos: 11, Kind: 'i', Obj: '"'},
+		{Line: `x "a\"b"`, Pos: 3, Kind: 'i', Obj: '"', Start: 3, End: 7, Ok: true},
+		{Line: `echo "a`, Pos: 6, Kind: 'i', Obj: '"'},
+		{Line: `echo ''`, Pos: 5, Kind: 'i', Obj: '\'', Start: 6, End: 6, Ok: true},
+
+		{Line: "f(a, g(b), c)", Pos: 7, Kind: 'i', Obj: '(', Start: 7, End: 8, Ok: true},
+		{Line: "f(a, g(b), c)", Pos: 7, Kind: 'a', Obj: ')', Start: 6, End: 9, Ok: true},
+		{Line: "f(a, g(b), c)", Pos: 11, Kind: 'i', Obj: 'b', Start: 2, End: 12, Ok: true},
+		{Line: "f(a, g(b), c)", Pos: 1, Kind: 'i', Obj: '(', Start: 2, End: 12, Ok: true},
+		{Line: "f(a, g(b), c)", Pos: 12, Kind: 'i', Obj: '(', Start: 2, End: 12, Ok: true},
+		{Line: "f(a, g(b), c)", Pos: 0, Kind: 'i', Obj: '('},
+		{Line: "f(a", Pos: 2, Kind: 'i', Obj: '('},
+		{Line: "a)b", Pos: 0, Kind: 'i', Obj: '('},
+		{Line: "x) (y", Pos: 4, Kind: 'i', Obj: '('},
+		{Line: "a[1][2]", Pos: 5, Kind: 'i', Obj: '[', Start: 5, End: 6, Ok: true},
+		{Line: "{ {}, x }", Pos: 6, Kind: 'a', Obj: 'B', Start: 0, End: 9, Ok: true},
+		{Line: "()", Pos: 0, Kind: 'i', Obj: '(', Start: 1, End: 1, Ok: true},
+
+		{Line: "<b>hi</b>", Pos: 4, Kind: 'i', Obj: 't', Start: 3, End: 5, Ok: true},
+		{Line: "<b>hi</b>", Pos: 4, Kind: 'a', Obj: 't', Start: 0, End: 9, Ok: true},
+		{Line: "<a><b>x</b></a>", Pos: 6, Kind: 'i', Obj: 't', Start: 6, End: 7, Ok: true},
+		{Line: "<a><b>x</b></a>", Pos: 6, Kind: 'a', Obj: 't', Start: 3, End: 11, Ok: true},
+		{Line: "<a><b>x</b></a>", Pos: 1, Kind: 'i', Obj: 't', Start: 3, End: 11, Ok: true},
+		{Line: "<a><br/>x</a>", Pos: 8, Kind: 'i', Obj: 't', Start: 3, End: 9, Ok: true},
+		{Line: "<b>x", Pos: 3, Kind: 'i', Obj: 't'},
+
+		{Line: "", Pos: 0, Kind: 'i', Obj: 'w'},
+		{Line: "", Pos: 0, Kind: 'a', Obj: '"'},
+		{Line: "", Pos: 0, Kind: 'i', Obj: '('},
+		{Line: "", Pos: 0, Kind: 'i', Obj: 't'},
+		{Line: "echo", Pos: 0, Kind: 'i', Obj: 'z'},
+	}
+
+	for _, test := range tests {
+		rl := NewInstance()
+		rl.line = []rune(test.Line)
+		rl.pos = test.Pos
+
+		start, end, ok := rl.viTextObject(test.Kind, test.Obj)
+		if ok != test.Ok {
+			t.Errorf("%c%c in %q at %d: expected ok to be %v, got %v", test.Kind, test.Obj, test.Line, test.Pos, test.Ok, ok)
+			continue
+		}
+		if ok && (start != test.Start || end != test.End) {
+			t.Errorf("%c%c in %q at %d: expected %d to %d, got %d to %d", test.Kind, test.Obj, test.Line, test.Pos, test.Start, test.End, start, end)
+		}
+	}
+}
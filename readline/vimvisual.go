@@ -0,0 +1,192 @@
+package readline
+
+import (
+	"strings"
+	"unicode"
+)
+
+// viVisualEnter starts visual mode, selecting from the cursor,
+// or the whole line for `V`.
+func (rl *Instance) viVisualEnter(line bool) {
+	rl.modeViMode = VimVisual
+	rl.viVisualLine = line
+	rl.viVisualFrom = rl.pos
+	rl.viIteration = ""
+	rl.viVisualAction()
+}
+
+func (rl *Instance) viVisualAction() {
+	if rl.viVisualLine {
+		rl.viAction(VimActionVisual, "line")
+	} else {
+		rl.viAction(VimActionVisual, "char")
+	}
+}
+
+func (rl *Instance) viVisualExit() {
+	rl.modeViMode = VimKeys
+	rl.viVisualLine = false
+	rl.viPending = 0
+}
+
+// viVisualRange returns where the selection starts and ends.
+// The character under the cursor is part of it.
+func (rl *Instance) viVisualRange() (start, end int) {
+	if rl.viVisualLine {
+		return 0, len(rl.line)
+	}
+
+	start, end = rl.viVisualFrom, rl.pos
+	if start > end {
+		start, end = end, start
+	}
+	end++
+	if end > len(rl.line) {
+		end = len(rl.line)
+	}
+	if start > end {
+		start = end
+	}
+	return
+}
+
+// viVisual - Apply a key in visual mode. Motions extend the selection,
+// and operators act on it and go back to normal mode.
+func (rl *Instance) viVisual(r rune) {
+	if rl.registers.registerSelectWait || r == '"' {
+		rl.vi(r)
+		return
+	}
+	if rl.viPending != 0 {
+		rl.viPendingKey(r, 0)
+		rl.viVisualClamp()
+		return
+	}
+
+	start, end := rl.viVisualRange()
+	switch r {
+	case 'i', 'a', 'f', 'F', 't', 'T':
+		rl.viPending = r
+
+	case 'o':
+		rl.viVisualFrom, rl.pos = rl.pos, rl.viVisualFrom
+
+	case 'v', 'V':
+		if (r == 'V') == rl.viVisualLine {
+			rl.viVisualExit()
+			return
+		}
+		rl.viVisualLine = r == 'V'
+		rl.viVisualAction()
+
+	case 'd', 'x':
+		rl.viVisualExit()
+		rl.viOperate('d', start, end)
+
+	case 'D', 'X':
+		rl.viVisualExit()
+		rl.viOperate('d', 0, len(rl.line))
+
+	case 'c', 's':
+		rl.viVisualExit()
+		rl.viOperate('c', start, end)
+
+	case 'C', 'S', 'R':
+		rl.viVisualExit()
+		rl.viOperate('c', 0, len(rl.line))
+
+	case 'y':
+		rl.viVisualExit()
+		rl.viOperate('y', start, end)
+
+	case 'Y':
+		rl.viVisualExit()
+		rl.viOperate('y', 0, len(rl.line))
+
+	case 'p', 'P':
+		// the selection is replaced with what's pasted
+		rl.viVisualExit()
+		register := string(rl.registers.currentRegister)
+		buffer := rl.pasteFromRegister()
+		rl.viAction(VimActionPaste, register, string(buffer))
+
+		line := append([]rune{}, rl.line[:start]...)
+		rl.line = append(line, rl.line[end:]...)
+		rl.pos = start
+		rl.insert(buffer)
+		if rl.pos > 0 {
+			rl.pos--
+		}
+
+	case '~', 'u', 'U':
+		rl.viVisualExit()
+		for i := start; i < end; i++ {
+			switch {
+			case r == 'u' || r == '~' && unicode.IsUpper(rl.line[i]):
+				rl.line[i] = unicode.ToLower(rl.line[i])
+			default:
+				rl.line[i] = unicode.ToUpper(rl.line[i])
+			}
+		}
+		rl.pos = start
+		rl.updateHelpers()
+
+	case 'h', 'l', 'w', 'W', 'b', 'B', 'e', 'E', '0', '^', '$', '%', '[', ']', ';', ',':
+		rl.vi(r)
+		rl.viVisualClamp()
+
+	default:
+		if r <= '9' && '0' <= r {
+			rl.viIteration += string(r)
+		}
+	}
+}
+
+// viVisualClamp keeps the cursor on a character of the line.
+func (rl *Instance) viVisualClamp() {
+	if rl.pos >= len(rl.line) && len(rl.line) > 0 {
+		rl.pos = len(rl.line) - 1
+	}
+}
+
+// highlightRange shows the characters of line from start to end in
+// reverse video. The line can have escape sequences in it (from syntax
+// highlighting), which aren't counted as characters.
+func highlightRange(line string, start, end int) string {
+	if start >= end {
+		return line
+	}
+
+	var buf strings.Builder
+	runes := []rune(line)
+	idx := 0
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '\x1b' && i+1 < len(runes) && runes[i+1] == '[' {
+			j := i + 1
+			for j+1 < len(runes) {
+				j++
+				if runes[j] >= 0x40 && runes[j] <= 0x7e {
+					break
+				}
+			}
+			buf.WriteString(string(runes[i : j+1]))
+			// a reset in the selection would end the highlight
+			if idx > start && idx < end {
+				buf.WriteString(seqInvert)
+			}
+			i = j
+			continue
+		}
+
+		if idx == start {
+			buf.WriteString(seqInvert)
+		}
+		buf.WriteRune(runes[i])
+		idx++
+		if idx == end {
+			buf.WriteString(seqInvertOff)
+		}
+	}
+
+	return buf.String()
+}
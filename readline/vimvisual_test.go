@@ -0,0 +1,88 @@
+package readline
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestViKeys(t *testing.T) {
+	type TestViKeysT struct {
+		Line     string
+		Pos      int
+		Keys     string
+		Expected string
+		Pos2     int
+	}
+
+	// <Esc> in Keys is the escape key, every other character a key
+	tests := []TestViKeysT{
+		// visual mode
+		{Line: "echo hello world", Pos: 6, Keys: "viwd", Expected: "echo  world", Pos2: 5},
+		{Line: "echo hello world", Pos: 0, Keys: "ved", Expected: " hello world", Pos2: 0},
+		{Line: "abcd", Pos: 2, Keys: "vhd", Expected: "ad", Pos2: 1},
+		{Line: "echo", Pos: 0, Keys: "vllcx<Esc>", Expected: "xo", Pos2: 0},
+		{Line: "f(a, b)", Pos: 3, Keys: "vi(d", Expected: "f()", Pos2: 2},
+		{Line: "echo hello", Pos: 3, Keys: "Vd", Expected: "", Pos2: 0},
+		{Line: "echo hello", Pos: 0, Keys: "vedu", Expected: "echo hello", Pos2: 3},
+		{Line: "echo hello", Pos: 0, Keys: "v<Esc>x", Expected: "cho hello", Pos2: 0},
+
+		// text objects with operators
+		{Line: `echo "a b"`, Pos: 0, Keys: `di"`, Expected: `echo ""`, Pos2: 6},
+		{Line: "f(a, g(b))", Pos: 7, Keys: "da(", Expected: "f(a, g)", Pos2: 6},
+		{Line: "one two", Pos: 5, Keys: "ciwx<Esc>", Expected: "one x", Pos2: 4},
+
+		// dot repeat
+		{Line: "aa bb cc", Pos: 0, Keys: "dw.", Expected: "cc", Pos2: 0},
+		{Line: "abcd", Pos: 0, Keys: "x2.", Expected: "d", Pos2: 0},
+		{Line: "abc abc abc", Pos: 0, Keys: "ciwx<Esc>w.", Expected: "x x abc", Pos2: 2},
+		{Line: "f(a) g(b)", Pos: 2, Keys: "ci(z<Esc>fb.", Expected: "f(z) g(z)", Pos2: 7},
+		// moving isn't a change to repeat
+		{Line: "abcd", Pos: 0, Keys: "xl.", Expected: "bd", Pos2: 1},
+
+		// f, t and repeating them with ; and ,
+		{Line: "a,b,c,d", Pos: 0, Keys: "f,;", Expected: "a,b,c,d", Pos2: 3},
+		{Line: "a,b,c,d", Pos: 0, Keys: "f,;,", Expected: "a,b,c,d", Pos2: 1},
+		{Line: "a,b,c,d", Pos: 0, Keys: "f,d;", Expected: "ac,d", Pos2: 1},
+		{Line: "a,b,c", Pos: 0, Keys: "t,;", Expected: "a,b,c", Pos2: 2},
+		{Line: "a,b,c", Pos: 4, Keys: "F,;", Expected: "a,b,c", Pos2: 1},
+		{Line: "a,b,c", Pos: 0, Keys: "2f,", Expected: "a,b,c", Pos2: 3},
+		{Line: "abc", Pos: 0, Keys: "fz", Expected: "abc", Pos2: 0},
+	}
+
+	for _, test := range tests {
+		rl := NewInstance()
+		rl.InputMode = Vim
+		rl.ViModeCallback = func(ViMode) {}
+
+		// type the line, then go to the position in normal mode
+		keys := splitKeys(test.Line + "<Esc>0" + strings.Repeat("l", test.Pos) + test.Keys)
+		readKeys(rl, keys...)
+
+		if string(rl.line) != test.Expected || rl.pos != test.Pos2 {
+			t.Errorf("%q in %q at %d: expected %q at %d, got %q at %d", test.Keys, test.Line, test.Pos, test.Expected, test.Pos2, string(rl.line), rl.pos)
+		}
+	}
+}
+
+func TestHighlightRange(t *testing.T) {
+	type TestHighlightRangeT struct {
+		Line     string
+		Start    int
+		End      int
+		Expected string
+	}
+
+	tests := []TestHighlightRangeT{
+		{Line: "echo hi", Start: 5, End: 7, Expected: "echo " + seqInvert + "hi" + seqInvertOff},
+		{Line: "echo hi", Start: 0, End: 1, Expected: seqInvert + "e" + seqInvertOff + "cho hi"},
+		{Line: "echo", Start: 2, End: 2, Expected: "echo"},
+		// escape sequences aren't characters, and the highlight goes on after a reset
+		{Line: "\x1b[32mecho\x1b[0m hi", Start: 2, End: 6, Expected: "\x1b[32mec" + seqInvert + "ho\x1b[0m" + seqInvert + " h" + seqInvertOff + "i"},
+	}
+
+	for _, test := range tests {
+		if got := highlightRange(test.Line, test.Start, test.End); got != test.Expected {
+			t.Errorf("%q from %d to %d: expected %q, got %q", test.Line, test.Start, test.End, test.Expected, got)
+		}
+	}
+}
@@ -1,9 +1,15 @@
-package main
+package hilbish
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"hilbish/util"
 
@@ -15,19 +21,23 @@ import (
 type lineReader struct {
 	rl *readline.Instance
 	fileHist *fileHistory
+	suggester *suggester
+	dirHist *dirHistory
+	sh *Shell
+	ignoreRegexps map[string]*regexp.Regexp
 }
-var hinter *rt.Closure
-var highlighter *rt.Closure
 
-func newLineReader(prompt string, noHist bool) *lineReader {
+func (sh *Shell) newLineReader(prompt string, noHist bool) *lineReader {
 	rl := readline.NewInstance()
 	lr := &lineReader{
 		rl: rl,
+		sh: sh,
+		ignoreRegexps: map[string]*regexp.Regexp{},
 	}
 
 	regexSearcher := rl.Searcher
 	rl.Searcher = func(needle string, haystack []string) []string {
-		fz, _ := util.DoString(l, "return hilbish.opts.fuzzy")
+		fz, _ := util.DoString(sh.runtime, "return hilbish.opts.fuzzy")
 		fuzz, ok := fz.TryBool()
 		if !fuzz || !ok {
 			return regexSearcher(needle, haystack)
@@ -46,9 +56,22 @@ func newLineReader(prompt string, noHist bool) *lineReader {
 	// we don't mind hilbish.read rl instances having completion,
 	// but it cant have shared history
 	if !noHist {
-		lr.fileHist = newFileHistory(defaultHistPath)
-		rl.SetHistoryCtrlR("History", &luaHistory{})
+		lr.fileHist = newFileHistory(sh.histPath)
+		lr.suggester = newSuggester(lr.fileHist)
+		lr.dirHist = newDirHistory(lr.fileHist)
+		rl.SetHistoryCtrlR("History", &luaHistory{sh})
+		rl.SetHistoryAltR("Directory history", lr.dirHist)
 		rl.HistoryAutoWrite = false
+		lr.bindFinders()
+
+		rl.ContinuePrompt = sh.multilinePrompt
+		rl.Incomplete = func(line []rune) bool {
+			if !sh.inputIncomplete(string(line)) {
+				return false
+			}
+			sh.hooks.Emit("multiline", nil)
+			return true
+		}
 	}
 	rl.ShowVimMode = false
 	rl.ViModeCallback = func(mode readline.ViMode) {
@@ -58,36 +81,48 @@ func newLineReader(prompt string, noHist bool) *lineReader {
 			case readline.VimInsert: modeStr = "insert"
 			case readline.VimDelete: modeStr = "delete"
 			case readline.VimReplaceOnce, readline.VimReplaceMany: modeStr = "replace"
+			case readline.VimVisual: modeStr = "visual"
 		}
-		setVimMode(modeStr)
+		sh.setVimMode(modeStr)
 	}
 	rl.ViActionCallback = func(action readline.ViAction, args []string) {
 		actionStr := ""
 		switch action {
 			case readline.VimActionPaste: actionStr = "paste"
 			case readline.VimActionYank: actionStr = "yank"
+			case readline.VimActionDelete: actionStr = "delete"
+			case readline.VimActionChange: actionStr = "change"
+			case readline.VimActionVisual: actionStr = "visual"
+			case readline.VimActionRepeat: actionStr = "repeat"
 		}
-		hooks.Emit("hilbish.vimAction", actionStr, args)
+		argsTbl := rt.NewTable()
+		for i, arg := range args {
+			argsTbl.Set(rt.IntValue(int64(i + 1)), rt.StringValue(arg))
+		}
+		sh.hooks.Emit("hilbish.vimAction", actionStr, rt.TableValue(argsTbl))
 	}
 	rl.HintText = func(line []rune, pos int) []rune {
-		hinter := hshMod.Get(rt.StringValue("hinter"))
-		retVal, err := rt.Call1(l.MainThread(), hinter,
-		rt.StringValue(string(line)), rt.IntValue(int64(pos)))
+		hinter := sh.hshMod.Get(rt.StringValue("hinter"))
+		term := rt.NewTerminationWith(sh.runtime.MainThread().CurrentCont(), 2, false)
+		err := rt.Call(sh.runtime.MainThread(), hinter,
+		[]rt.Value{rt.StringValue(string(line)), rt.IntValue(int64(pos))}, term)
 		if err != nil {
 			fmt.Println(err)
 			return []rune{}
 		}
 		
 		hintText := ""
-		if luaStr, ok := retVal.TryString(); ok {
+		if luaStr, ok := term.Get(0).TryString(); ok {
 			hintText = luaStr
 		}
+		// a hint that's only shown, like a signature
+		rl.HintInfo = term.Get(1) == rt.BoolValue(false)
 		
 		return []rune(hintText)
 	}
 	rl.SyntaxHighlighter = func(line []rune) string {
-		highlighter := hshMod.Get(rt.StringValue("highlighter"))
-		retVal, err := rt.Call1(l.MainThread(), highlighter,
+		highlighter := sh.hshMod.Get(rt.StringValue("highlighter"))
+		retVal, err := rt.Call1(sh.runtime.MainThread(), highlighter,
 		rt.StringValue(string(line)))
 		if err != nil {
 			fmt.Println(err)
@@ -101,11 +136,31 @@ func newLineReader(prompt string, noHist bool) *lineReader {
 		
 		return highlighted
 	}
+	rl.Paste = func(text []rune) ([]rune, bool) {
+		paste := sh.hshMod.Get(rt.StringValue("editor")).AsTable().Get(rt.StringValue("paste"))
+		retVal, err := rt.Call1(sh.runtime.MainThread(), paste,
+		rt.StringValue(string(text)))
+		if err != nil {
+			fmt.Println(err)
+			return text, true
+		}
+
+		pasted, ok := retVal.TryString()
+		if !ok {
+			return nil, false
+		}
+
+		return []rune(pasted), true
+	}
 	rl.TabCompleter = func(line []rune, pos int, _ readline.DelayedTabContext) (string, []*readline.CompletionGroup) {
-		term := rt.NewTerminationWith(l.MainThread().CurrentCont(), 2, false)
-		compHandle := hshMod.Get(rt.StringValue("completion")).AsTable().Get(rt.StringValue("handler"))
-		err := rt.Call(l.MainThread(), compHandle, []rt.Value{rt.StringValue(string(line)),
-		rt.IntValue(int64(pos))}, term)
+		term := rt.NewTerminationWith(sh.runtime.MainThread().CurrentCont(), 2, false)
+		compHandle := sh.hshMod.Get(rt.StringValue("completion")).AsTable().Get(rt.StringValue("handler"))
+		// the position is passed as a byte offset, like Lua strings are indexed
+		if pos > len(line) {
+			pos = len(line)
+		}
+		err := rt.Call(sh.runtime.MainThread(), compHandle, []rt.Value{rt.StringValue(string(line)),
+		rt.IntValue(int64(len(string(line[:pos]))))}, term)
 
 		var compGroups []*readline.CompletionGroup
 		if err != nil {
@@ -169,6 +224,12 @@ func newLineReader(prompt string, noHist bool) *lineReader {
 				}
 			})
 
+			// items with descriptions are keys of the table, which
+			// are in no order, so they're sorted
+			if len(itemDescriptions) == len(items) {
+				sort.Strings(items)
+			}
+
 			var dispType readline.TabDisplayType
 			switch luaCompType.AsString() {
 				case "grid": dispType = readline.TabDisplayGrid
@@ -193,7 +254,7 @@ func newLineReader(prompt string, noHist bool) *lineReader {
 }
 
 func (lr *lineReader) Read() (string, error) {
-	hooks.Emit("command.precmd", nil)
+	lr.sh.hooks.Emit("command.precmd", nil)
 	s, err := lr.rl.Readline()
 	// this is so dumb
 	if err == readline.EOF {
@@ -215,20 +276,149 @@ func (lr *lineReader) SetPrompt(p string) {
 		lr.rl.MultilinePrompt = ""
 		lr.rl.SetPrompt(p)
 	}
-	if initialized && !running {
+	if lr.sh.initialized && !lr.sh.running {
 		lr.rl.RefreshPromptInPlace("")
 	}
 }
 
 func (lr *lineReader) SetRightPrompt(p string) {
 	lr.rl.SetRightPrompt(p)
-	if initialized && !running {
+	if lr.sh.initialized && !lr.sh.running {
 		lr.rl.RefreshPromptInPlace("")
 	}
 }
 
 func (lr *lineReader) AddHistory(cmd string) {
-	lr.fileHist.Write(cmd)
+	lr.addHistory(lr.newHistoryEntry(cmd))
+}
+
+// addHistory adds entry to the history, unless the history opts say to ignore it.
+func (lr *lineReader) addHistory(entry historyEntry) error {
+	ignored, err := lr.historyIgnored(entry.Cmd)
+	if ignored || err != nil {
+		return err
+	}
+
+	lr.applyHistoryOpts()
+	if mode, _ := lr.sh.opt("historyIgnoreDups").TryString(); mode == "all" {
+		// only keep the newest
+		if err := lr.fileHist.removeCmd(entry.Cmd); err != nil {
+			return err
+		}
+	}
+
+	_, err = lr.fileHist.add(entry)
+	return err
+}
+
+// newHistoryEntry makes a history entry for cmd, with the info
+// of the last command that was run.
+func (lr *lineReader) newHistoryEntry(cmd string) historyEntry {
+	host, _ := os.Hostname()
+	exitCode := int(lr.sh.exitCode)
+
+	start, cwd := lr.sh.cmdStart, lr.sh.cmdCwd
+	if start.IsZero() {
+		start = time.Now()
+		cwd, _ = os.Getwd()
+	}
+
+	return historyEntry{
+		Cmd: cmd,
+		Time: start.Unix(),
+		Duration: lr.sh.cmdDuration.Milliseconds(),
+		Cwd: cwd,
+		ExitCode: &exitCode,
+		Session: lr.fileHist.session,
+		Host: host,
+	}
+}
+
+// syncHistory loads entries other sessions have added to the history,
+// depending on the historyShare opt.
+func (lr *lineReader) syncHistory() {
+	if lr.fileHist == nil {
+		return
+	}
+
+	lr.applyHistoryOpts()
+	lr.fileHist.refresh()
+
+	// scope Alt-R history to where we are now
+	cwd, _ := os.Getwd()
+	root := lr.dirHist.setDir(cwd)
+	lr.rl.SetHistoryAltR("History in " + util.AbbrevHome(root), lr.dirHist)
+}
+
+// applyHistoryOpts sets up the history file by the historyShare
+// and historySize opts.
+func (lr *lineReader) applyHistoryOpts() {
+	shared := true
+	if mode, ok := lr.sh.opt("historyShare").TryString(); ok {
+		shared = mode != "session"
+	}
+	lr.fileHist.setShared(shared)
+
+	size, _ := lr.sh.opt("historySize").TryInt()
+	lr.fileHist.mu.Lock()
+	lr.fileHist.maxSize = int(size)
+	lr.fileHist.mu.Unlock()
+}
+
+// historyIgnored returns whether cmd should not be added to the history,
+// going by the historyIgnoreDups and historyIgnore opts.
+func (lr *lineReader) historyIgnored(cmd string) (bool, error) {
+	dups := lr.sh.opt("historyIgnoreDups")
+	if mode, _ := dups.TryString(); mode == "consecutive" || dups == rt.BoolValue(true) {
+		if lr.fileHist.last() == cmd {
+			return true, nil
+		}
+	}
+
+	patterns, ok := lr.sh.opt("historyIgnore").TryTable()
+	if !ok {
+		return false, nil
+	}
+
+	var ignored bool
+	var err error
+	util.ForEach(patterns, func(_ rt.Value, v rt.Value) {
+		pattern, ok := v.TryString()
+		if !ok || ignored || err != nil {
+			return
+		}
+
+		re, ok := lr.ignoreRegexps[pattern]
+		if !ok {
+			re, err = regexp.Compile(pattern)
+			if err != nil {
+				err = fmt.Errorf("invalid pattern in hilbish.opts.historyIgnore: %w", err)
+				return
+			}
+			lr.ignoreRegexps[pattern] = re
+		}
+
+		ignored = re.MatchString(cmd)
+	})
+
+	return ignored, err
+}
+
+// suggest returns a command from history to suggest for line,
+// or an empty string if there is none.
+func (lr *lineReader) suggest(line string) string {
+	if lr.suggester == nil {
+		return ""
+	}
+
+	cwd, _ := os.Getwd()
+	return lr.suggester.suggest(line, cwd)
+}
+
+func (lr *lineReader) Close() {
+	if lr.fileHist != nil {
+		lr.fileHist.close()
+	}
 }
 
 func (lr *lineReader) ClearInput() {
@@ -246,11 +436,17 @@ func (lr *lineReader) Resize() {
 // method of saving history.
 func (lr *lineReader) Loader(rtm *rt.Runtime) *rt.Table {
 	lrLua := map[string]util.LuaExport{
-		"add": {lr.luaAddHistory, 1, false},
+		"add": {lr.luaAddHistory, 1, true},
 		"all": {lr.luaAllHistory, 0, false},
 		"clear": {lr.luaClearHistory, 0, false},
+		"delete": {lr.luaDeleteHistory, 1, false},
+		"entry": {lr.luaHistoryEntry, 1, false},
+		"forDir": {lr.luaHistoryForDir, 1, false},
 		"get": {lr.luaGetHistory, 1, false},
+		"import": {lr.luaImportHistory, 1, true},
+		"query": {lr.luaQueryHistory, 1, false},
 		"size": {lr.luaSize, 0, false},
+		"suggest": {lr.luaSuggestHistory, 1, false},
 	}
 
 	mod := rt.NewTable()
@@ -260,9 +456,14 @@ func (lr *lineReader) Loader(rtm *rt.Runtime) *rt.Table {
 }
 
 // #interface history
-// add(cmd)
-// Adds a command to the history.
+// add(cmd, info)
+// Adds a command to the history. Along with the command, the history
+// saves when and where it was run and how it went. Any of these can be
+// set in the `info` table, with the same fields as returned by `entry`.
+// Those not set are taken from the last command that was run,
+// along with the current directory, host and session.
 // #param cmd string
+// #param info table|nil
 func (lr *lineReader) luaAddHistory(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	if err := c.Check1Arg(); err != nil {
 		return nil, err
@@ -271,11 +472,128 @@ func (lr *lineReader) luaAddHistory(t *rt.Thread, c *rt.GoCont) (rt.Cont, error)
 	if err != nil {
 		return nil, err
 	}
-	lr.AddHistory(cmd)
+
+	entry := lr.newHistoryEntry(cmd)
+	if len(c.Etc()) != 0 && !c.Etc()[0].IsNil() {
+		info, ok := c.Etc()[0].TryTable()
+		if !ok {
+			return nil, errors.New("bad argument #2 to add (expected table, got " + c.Etc()[0].TypeName() + ")")
+		}
+
+		if v, ok := info.Get(rt.StringValue("time")).TryInt(); ok {
+			entry.Time = v
+		}
+		if v, ok := info.Get(rt.StringValue("duration")).TryInt(); ok {
+			entry.Duration = v
+		}
+		if v, ok := info.Get(rt.StringValue("cwd")).TryString(); ok {
+			entry.Cwd = v
+		}
+		if v, ok := info.Get(rt.StringValue("exitCode")).TryInt(); ok {
+			code := int(v)
+			entry.ExitCode = &code
+		}
+		if v, ok := info.Get(rt.StringValue("session")).TryString(); ok {
+			entry.Session = v
+		}
+		if v, ok := info.Get(rt.StringValue("host")).TryString(); ok {
+			entry.Host = v
+		}
+	}
+
+	if err := lr.addHistory(entry); err != nil {
+		return nil, err
+	}
 
 	return c.Next(), nil
 }
 
+// #interface history
+// entry(index) -> table
+// Retrieves a command from the history, along with its info, based on the `index`.
+// The returned table has these fields, which are nil if not known
+// (for example in history from older versions of Hilbish):
+// `cmd` (the command), `index`, `time` (unix time the command was run at),
+// `duration` (how long it ran for in milliseconds), `cwd`, `exitCode`,
+// `session` (an ID of the Hilbish instance it was run in) and `host`.
+// #param index number
+// #returns table
+func (lr *lineReader) luaHistoryEntry(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+	if err := c.Check1Arg(); err != nil {
+		return nil, err
+	}
+	idx, err := c.IntArg(0)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, ok := lr.fileHist.entry(int(idx))
+	if !ok {
+		return c.Next(), nil
+	}
+
+	return c.PushingNext1(t.Runtime, rt.TableValue(historyEntryTable(entry, int(idx)))), nil
+}
+
+// #interface history
+// query(filter) -> table
+// Returns the history entries (as returned by `entry`) that match `filter`,
+// from oldest to newest. The filter can have these fields:
+// `cwd` to only get commands run in a directory,
+// `exitCode` for commands which exited with a specific code,
+// `success` for commands which did (`true`) or did not (`false`) succeed,
+// and `since` and `before` (unix times) for commands run in a time range.
+// #param filter table
+// #returns table
+/*
+#example
+-- failed commands in the current directory in the past day
+local entries = hilbish.history.query {
+	cwd = hilbish.cwd(),
+	success = false,
+	since = os.time() - 60 * 60 * 24
+}
+for _, e in ipairs(entries) do
+	print(e.index, e.cmd, e.exitCode)
+end
+#example
+*/
+func (lr *lineReader) luaQueryHistory(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+	if err := c.Check1Arg(); err != nil {
+		return nil, err
+	}
+	filterTbl, err := c.TableArg(0)
+	if err != nil {
+		return nil, err
+	}
+
+	var filter historyFilter
+	if v, ok := filterTbl.Get(rt.StringValue("cwd")).TryString(); ok {
+		filter.cwd = filepath.Clean(util.ExpandHome(v))
+	}
+	if v, ok := filterTbl.Get(rt.StringValue("exitCode")).TryInt(); ok {
+		code := int(v)
+		filter.exitCode = &code
+	}
+	if v, ok := filterTbl.Get(rt.StringValue("success")).TryBool(); ok {
+		filter.success = &v
+	}
+	if v, ok := filterTbl.Get(rt.StringValue("since")).TryInt(); ok {
+		filter.since = v
+	}
+	if v, ok := filterTbl.Get(rt.StringValue("before")).TryInt(); ok {
+		filter.until = v
+	}
+
+	entries := rt.NewTable()
+	idxs, matches := lr.fileHist.query(filter)
+	for i, idx := range idxs {
+		entries.Set(rt.IntValue(int64(i + 1)), rt.TableValue(historyEntryTable(matches[i], idx)))
+	}
+
+	return c.PushingNext1(t.Runtime, rt.TableValue(entries)), nil
+}
+
 // #interface history
 // size() -> number
 // Returns the amount of commands in the history.
@@ -325,3 +643,128 @@ func (lr *lineReader) luaClearHistory(t *rt.Thread, c *rt.GoCont) (rt.Cont, erro
 	lr.fileHist.clear()
 	return c.Next(), nil
 }
+
+// #interface history
+// delete(index)
+// Removes the command at `index` from the history,
+// without affecting the rest of it.
+// #param index number
+func (lr *lineReader) luaDeleteHistory(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+	if err := c.Check1Arg(); err != nil {
+		return nil, err
+	}
+	idx, err := c.IntArg(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lr.fileHist.delete(int(idx)); err != nil {
+		return nil, err
+	}
+
+	return c.Next(), nil
+}
+
+// #interface history
+// import(path, format) -> number
+// Imports the history of another shell from the file at `path`.
+// The commands are added to the history in the order they were run,
+// skipping those which have already been imported.
+// `format` is the shell the history is from, which can be `bash`, `zsh`
+// or `fish`. If it isn't passed, it is guessed from the file.
+// Returns how many commands were imported.
+// #param path string
+// #param format string|nil
+// #returns number
+/*
+#example
+hilbish.history.import(hilbish.home .. '/.zsh_history')
+#example
+*/
+func (lr *lineReader) luaImportHistory(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+	if err := c.Check1Arg(); err != nil {
+		return nil, err
+	}
+	path, err := c.StringArg(0)
+	if err != nil {
+		return nil, err
+	}
+
+	var format string
+	if len(c.Etc()) != 0 && !c.Etc()[0].IsNil() {
+		var ok bool
+		format, ok = c.Etc()[0].TryString()
+		if !ok {
+			return nil, errors.New("bad argument #2 to import (expected string, got " + c.Etc()[0].TypeName() + ")")
+		}
+	}
+
+	entries, err := importHistory(util.ExpandHome(path), format)
+	if err != nil {
+		return nil, err
+	}
+
+	added, err := lr.fileHist.merge(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.PushingNext1(t.Runtime, rt.IntValue(int64(added))), nil
+}
+
+// #interface history
+// suggest(line) -> string
+// Returns a command from the history that starts with `line`, to suggest
+// to the user. Commands that were run in the current directory are preferred,
+// then ones that succeeded, then the most recent.
+// This is what the default `hilbish.hinter` uses.
+// Returns nil if there is no suggestion.
+// #param line string
+// #returns string
+func (lr *lineReader) luaSuggestHistory(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+	if err := c.Check1Arg(); err != nil {
+		return nil, err
+	}
+	line, err := c.StringArg(0)
+	if err != nil {
+		return nil, err
+	}
+
+	sugg := lr.suggest(line)
+	if sugg == "" {
+		return c.Next(), nil
+	}
+
+	return c.PushingNext1(t.Runtime, rt.StringValue(sugg)), nil
+}
+
+// #interface history
+// forDir(path) -> table
+// Returns the history entries (as returned by `entry`) of commands that
+// were run in the directory at `path`, or in directories inside of it,
+// from oldest to newest. If `path` is in a git repository, the history
+// of the whole repository is returned, like Alt-R shows.
+// #param path string
+// #returns table
+func (lr *lineReader) luaHistoryForDir(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+	if err := c.Check1Arg(); err != nil {
+		return nil, err
+	}
+	path, err := c.StringArg(0)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := filepath.Abs(util.ExpandHome(path))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := rt.NewTable()
+	idxs, matches := lr.fileHist.query(historyFilter{dir: historyRoot(dir)})
+	for i, idx := range idxs {
+		entries.Set(rt.IntValue(int64(i + 1)), rt.TableValue(historyEntryTable(matches[i], idx)))
+	}
+
+	return c.PushingNext1(t.Runtime, rt.TableValue(entries)), nil
+}
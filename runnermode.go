@@ -1,4 +1,4 @@
-package main
+package hilbish
 
 import (
 	"hilbish/util"
@@ -49,11 +49,12 @@ hilbish.runnerMode(function(input)
 end)
 ```
 */
-func runnerModeLoader(rtm *rt.Runtime) *rt.Table {
+func (sh *Shell) runnerModeLoader(rtm *rt.Runtime) *rt.Table {
 	exports := map[string]util.LuaExport{
-		"sh": {shRunner, 1, false},
-		"lua": {luaRunner, 1, false},
-		"setMode": {hlrunnerMode, 1, false},
+		"sh": {sh.shRunner, 1, false},
+		"lua": {sh.luaRunner, 1, false},
+		"incomplete": {sh.runnerIncomplete, 2, false},
+		"setMode": {sh.hlrunnerMode, 1, false},
 	}
 
 	mod := rt.NewTable()
@@ -76,7 +77,7 @@ func _runnerMode() {}
 // Runs a command in Hilbish's shell script interpreter.
 // This is the equivalent of using `source`.
 // #param cmd string
-func shRunner(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+func (sh *Shell) shRunner(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	if err := c.Check1Arg(); err != nil {
 		return nil, err
 	}
@@ -85,7 +86,7 @@ func shRunner(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 		return nil, err
 	}
 
-	_, exitCode, cont, err := execSh(aliases.Resolve(cmd))
+	_, exitCode, cont, err := sh.execSh(sh.aliases.Resolve(cmd))
 	var luaErr rt.Value = rt.NilValue
 	if err != nil {
 		luaErr = rt.StringValue(err.Error())
@@ -103,8 +104,12 @@ func shRunner(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 // lua(cmd)
 // Evaluates `cmd` as Lua input. This is the same as using `dofile`
 // or `load`, but is appropriated for the runner interface.
+// Unfinished Lua (like a function without its `end`) returns
+// `continue` as true, so Hilbish prompts for the rest of it.
+// An expression (like `1 + 2`) is run for its values, which are shown
+// with `hilbish.runner.echo` when the `luaEcho` opt is enabled.
 // #param cmd string
-func luaRunner(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+func (sh *Shell) luaRunner(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	if err := c.Check1Arg(); err != nil {
 		return nil, err
 	}
@@ -113,7 +118,7 @@ func luaRunner(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 		return nil, err
 	}
 
-	input, exitCode, err := handleLua(cmd)
+	input, exitCode, cont, err := sh.handleLua(cmd)
 	var luaErr rt.Value = rt.NilValue
 	if err != nil {
 		luaErr = rt.StringValue(err.Error())
@@ -121,7 +126,33 @@ func luaRunner(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	runnerRet := rt.NewTable()
 	runnerRet.Set(rt.StringValue("input"), rt.StringValue(input))
 	runnerRet.Set(rt.StringValue("exitCode"), rt.IntValue(int64(exitCode)))
+	runnerRet.Set(rt.StringValue("continue"), rt.BoolValue(cont))
 	runnerRet.Set(rt.StringValue("err"), luaErr)
 
 	return c.PushingNext(t.Runtime, rt.TableValue(runnerRet)), nil
 }
+
+// #interface runner
+// incomplete(input, mode) -> boolean
+// Returns whether `input` needs more lines before the builtin runner `mode`
+// (`hybrid`, `hybridRev`, `lua` or `sh`) can run it, like an unfinished
+// function or sh loop. In the hybrid modes, input is only incomplete if
+// it's unfinished in both languages.
+// #param input string
+// #param mode string
+// #returns boolean
+func (sh *Shell) runnerIncomplete(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
+	if err := c.CheckNArgs(2); err != nil {
+		return nil, err
+	}
+	input, err := c.StringArg(0)
+	if err != nil {
+		return nil, err
+	}
+	mode, err := c.StringArg(1)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.PushingNext1(t.Runtime, rt.BoolValue(sh.incomplete(input, mode))), nil
+}
@@ -0,0 +1,491 @@
+package hilbish
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"hilbish/golibs/bait"
+	"hilbish/golibs/commander"
+	"hilbish/moduleapi"
+	"hilbish/util"
+
+	rt "github.com/arnodel/golua/runtime"
+	"github.com/maxlandon/readline"
+	"golang.org/x/term"
+)
+
+var (
+	confDir string
+	userDataDir string
+	curuser *user.User
+
+	defaultConfPath string
+	defaultHistPath string
+)
+
+func init() {
+	curuser, _ = user.Current()
+	homedir := curuser.HomeDir
+	confDir, _ = os.UserConfigDir()
+	preloadPath = strings.Replace(preloadPath, "~", homedir, 1)
+	sampleConfPath = strings.Replace(sampleConfPath, "~", homedir, 1)
+
+	// i honestly dont know what directories to use for this
+	switch runtime.GOOS {
+	case "linux", "darwin":
+		userDataDir = getenv("XDG_DATA_HOME", curuser.HomeDir + "/.local/share")
+	default:
+		// this is fine on windows, dont know about others
+		userDataDir = confDir
+	}
+
+	if defaultConfDir == "" {
+		// we'll add *our* default if its empty (wont be if its changed comptime)
+		defaultConfDir = filepath.Join(confDir, "hilbish")
+	} else {
+		// else do ~ substitution
+		defaultConfDir = filepath.Join(util.ExpandHome(defaultConfDir), "hilbish")
+	}
+	defaultConfPath = filepath.Join(defaultConfDir, "init.lua")
+	if defaultHistDir == "" {
+		defaultHistDir = filepath.Join(userDataDir, "hilbish")
+	} else {
+		defaultHistDir = filepath.Join(util.ExpandHome(defaultHistDir), "hilbish")
+	}
+	defaultHistPath = filepath.Join(defaultHistDir, ".hilbish-history")
+}
+
+// Shell is an instance of Hilbish. Each Shell has its own Lua runtime,
+// line reader, hooks, commanders, aliases, jobs and timers, so multiple
+// can be used in the same process. They do share the process' environment
+// variables and working directory, however.
+/*
+A Shell can be used to embed Hilbish in another Go program:
+```go
+sh := hilbish.New(hilbish.WithInteractive(true),
+hilbish.WithCommand("status", func(args []string, in io.Reader, out, err io.Writer) uint8 {
+	io.WriteString(out, "all good!\n")
+	return 0
+}))
+defer sh.Close()
+
+sh.RunConfig(hilbish.DefaultConfigPath())
+sh.Interactive(context.Background())
+```
+*/
+type Shell struct {
+	runtime *rt.Runtime
+	lr *lineReader
+	hshMod *rt.Table
+
+	hooks *bait.Bait
+	cmds *commander.Commander
+	aliases *aliasModule
+	jobs *jobHandler
+	timers *timersModule
+	profile *startupProfile
+	luaCompletions map[string]rt.Callable
+	luaSigs map[string]luaSignature // function signatures, for hints
+	runnerMode rt.Value
+
+	prompt string
+	multilinePrompt string
+	histPath string
+	exitCode uint8
+	cmdStart time.Time // when the last command started
+	cmdDuration time.Duration // and how long it took
+	cmdCwd string // and where it was run
+
+	running bool // Is a command currently running
+	interactive bool
+	login bool // Are we the login shell?
+	noexecute bool // Should we run Lua or only report syntax errors
+	initialized bool
+
+	chunkCacheDir string
+	exited *ExitError // set when the exit command is run
+
+
+	// functions from options to run once the runtime is set up
+	setup []func(*Shell)
+}
+
+// Option changes how a Shell is set up. Options are passed to New.
+type Option func(*Shell)
+
+// WithInteractive sets whether the shell is interactive.
+// The user's config is only run for interactive shells.
+func WithInteractive(interactive bool) Option {
+	return func(sh *Shell) {
+		sh.interactive = interactive
+	}
+}
+
+// WithLogin sets whether the shell is a login shell.
+func WithLogin(login bool) Option {
+	return func(sh *Shell) {
+		sh.login = login
+	}
+}
+
+// WithNoExec makes the shell only report Lua syntax errors instead of running input.
+func WithNoExec(noexec bool) Option {
+	return func(sh *Shell) {
+		sh.noexecute = noexec
+	}
+}
+
+// WithHistory sets the file command history is saved to.
+// An empty path keeps history in memory only.
+func WithHistory(path string) Option {
+	return func(sh *Shell) {
+		sh.histPath = path
+	}
+}
+
+// WithChunkCache makes the shell cache the Lua chunks it compiles in dir,
+// so files that haven't changed don't have to be compiled again.
+// Chunks aren't cached by default.
+func WithChunkCache(dir string) Option {
+	return func(sh *Shell) {
+		sh.chunkCacheDir = dir
+	}
+}
+
+// WithCommand adds a commander to the shell. It is added after the
+// builtin commanders, so it can replace them.
+func WithCommand(name string, cmd moduleapi.Command) Option {
+	return func(sh *Shell) {
+		sh.setup = append(sh.setup, func(sh *Shell) {
+			sh.RegisterCommand(name, cmd)
+		})
+	}
+}
+
+// WithHook adds a handler for the hook named event, like `hilbish.init`.
+func WithHook(event string, handler func(...interface{})) Option {
+	return func(sh *Shell) {
+		sh.setup = append(sh.setup, func(sh *Shell) {
+			sh.hooks.On(event, handler)
+		})
+	}
+}
+
+// New creates a Shell and loads its Lua runtime, including the nature
+// prelude. It does not run the user's config, see RunConfig.
+func New(opts ...Option) *Shell {
+	sh := &Shell{
+		luaCompletions: map[string]rt.Callable{},
+		runnerMode: rt.StringValue("hybrid"),
+		multilinePrompt: "> ",
+		histPath: defaultHistPath,
+	}
+
+	for _, opt := range opts {
+		opt(sh)
+	}
+
+	sh.lr = sh.newLineReader("", false)
+	sh.luaInit()
+	sh.RegisterCommand("exit", sh.exitCmd)
+
+	for _, setup := range sh.setup {
+		setup(sh)
+	}
+	sh.setup = nil
+
+	return sh
+}
+
+// DefaultConfigPath returns the path to the user's config.
+func DefaultConfigPath() string {
+	return defaultConfPath
+}
+
+// DefaultChunkCacheDir returns the directory in the user's data dir
+// where Hilbish caches compiled Lua chunks.
+func DefaultChunkCacheDir() string {
+	return filepath.Join(userDataDir, "hilbish", "chunks")
+}
+
+// RunConfig runs the Lua config at path and throws the `hilbish.init` hook.
+// If path is the default config path and it does not exist, the sample
+// config is used. The config is not run if the shell isn't interactive.
+// Afterwards, the startup profile is written if WithStartupProfile was used.
+func (sh *Shell) RunConfig(path string) {
+	// If user's config doesn't exixt,
+	if _, err := os.Stat(defaultConfPath); os.IsNotExist(err) && path == defaultConfPath {
+		// Read default from current directory
+		// (this is assuming the current dir is Hilbish's git)
+		_, err := os.ReadFile(".hilbishrc.lua")
+		confpath := ".hilbishrc.lua"
+		if err != nil {
+			// If it wasnt found, go to the real sample conf
+			// (which is builtin if not installed)
+			confpath = sampleConfPath
+		}
+
+		sh.runConfig(confpath)
+	} else {
+		sh.runConfig(path)
+	}
+	sh.hooks.Emit("hilbish.init")
+
+	sh.profile.report()
+	sh.profile = nil
+}
+
+// RunInput runs input with the current runner, like interactive input,
+// and returns its exit code. Private input is not added to history.
+func (sh *Shell) RunInput(input string, private bool) uint8 {
+	sh.runInput(input, private)
+
+	return sh.exitCode
+}
+
+// RunFile runs the Lua script at path. The script can access the path
+// and args in the `args` global table, with the path at index 0.
+func (sh *Shell) RunFile(path string, args ...string) error {
+	luaArgs := rt.NewTable()
+	luaArgs.Set(rt.IntValue(0), rt.StringValue(path))
+	for i, arg := range args {
+		luaArgs.Set(rt.IntValue(int64(i + 1)), rt.StringValue(arg))
+	}
+
+	sh.runtime.GlobalEnv().Set(rt.StringValue("args"), rt.TableValue(luaArgs))
+
+	return util.DoFile(sh.runtime, path)
+}
+
+// Interactive prompts for and runs input until the user presses Ctrl-D,
+// or ctx is done. ctx is checked before every prompt, as the line reader
+// cannot be interrupted while it waits for input.
+// If the exit command is run, an ExitError with its exit code is returned.
+func (sh *Shell) Interactive(ctx context.Context) error {
+	sh.initialized = true
+	sh.exited = nil
+input:
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		sh.running = false
+
+		sh.lr.syncHistory()
+		input, err := sh.lr.Read()
+
+		if err == io.EOF {
+			// Exit if user presses ^D (ctrl + d)
+			sh.hooks.Emit("hilbish.exit")
+			return nil
+		}
+		if err != nil {
+			if err == readline.CtrlC {
+				fmt.Println("^C")
+				sh.hooks.Emit("hilbish.cancel")
+				continue
+			}
+
+			return err
+		}
+		var priv bool
+		if strings.HasPrefix(input, " ") && sh.opt("historyIgnoreSpace") != rt.BoolValue(false) {
+			priv = true
+		}
+
+		input = strings.TrimSpace(input)
+		if len(input) == 0 {
+			sh.running = true
+			sh.hooks.Emit("command.exit", 0)
+			continue
+		}
+
+		if strings.HasSuffix(input, "\\") {
+			for {
+				input, err = sh.continuePrompt(input)
+				if err != nil {
+					sh.running = true
+					sh.lr.SetPrompt(fmtPrompt(sh.prompt))
+					goto input // continue inside nested loop
+				}
+				if !strings.HasSuffix(input, "\\") {
+					break
+				}
+			}
+		}
+
+		sh.runInput(input, priv)
+		if sh.exited != nil {
+			return *sh.exited
+		}
+
+		termwidth, _, err := term.GetSize(0)
+		if err != nil {
+			continue
+		}
+		fmt.Printf("\u001b[7m∆\u001b[0m" + strings.Repeat(" ", termwidth - 1) + "\r")
+	}
+}
+
+// Close stops all running jobs. If the shell isn't interactive,
+// it also waits for all timers to finish.
+func (sh *Shell) Close() {
+	sh.jobs.stopAll()
+
+	// wait for all timers to finish before exiting.
+	// only do that when not interactive
+	if !sh.interactive {
+		sh.timers.wait()
+	}
+
+	sh.lr.Close()
+	util.EnableChunkCache(sh.runtime, "", "")
+}
+
+// ExitError is returned by Interactive when the exit command is run.
+type ExitError struct {
+	Code uint8 // the exit code passed to exit
+}
+
+func (e ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// Exited returns whether the exit command was run, and the exit code it
+// was passed. Programs which run input without Interactive should stop
+// running more of it once it has been.
+func (sh *Shell) Exited() (uint8, bool) {
+	if sh.exited == nil {
+		return 0, false
+	}
+
+	return sh.exited.Code, true
+}
+
+// exitCmd is the exit command. It stops the shell from running more input,
+// instead of exiting the process, so the shell can be closed properly.
+func (sh *Shell) exitCmd(args []string, in io.Reader, out, stderr io.Writer) uint8 {
+	var code uint8
+	if len(args) > 0 {
+		n, err := strconv.ParseUint(args[0], 10, 8)
+		if err != nil {
+			fmt.Fprintf(stderr, "exit: invalid exit code %q\n", args[0])
+			return 2
+		}
+		code = uint8(n)
+	}
+
+	sh.hooks.Emit("hilbish.exit")
+	sh.exited = &ExitError{Code: code}
+	return code
+}
+
+func (sh *Shell) continuePrompt(prev string) (string, error) {
+	sh.hooks.Emit("multiline", nil)
+	sh.lr.SetPrompt(sh.multilinePrompt)
+	cont, err := sh.lr.Read()
+	if err != nil {
+		return "", err
+	}
+	cont = strings.TrimSpace(cont)
+
+	// a backslash at the end continues the line, otherwise
+	// the input goes on to the next line
+	if strings.HasSuffix(prev, "\\") {
+		return strings.TrimSuffix(prev, "\\") + cont, nil
+	}
+	return prev + "\n" + cont, nil
+}
+
+// opt returns the value of an opt in hilbish.opts.
+func (sh *Shell) opt(name string) rt.Value {
+	opts, ok := sh.hshMod.Get(rt.StringValue("opts")).TryTable()
+	if !ok {
+		return rt.NilValue
+	}
+
+	return opts.Get(rt.StringValue(name))
+}
+
+// This semi cursed function formats our prompt (obviously)
+func fmtPrompt(prompt string) string {
+	host, _ := os.Hostname()
+	cwd, _ := os.Getwd()
+
+	cwd = util.AbbrevHome(cwd)
+	username := curuser.Username
+	// this will be baked into binary since GOOS is a constant
+	if runtime.GOOS == "windows" {
+		username = strings.Split(username, "\\")[1] // for some reason Username includes the hostname on windows
+	}
+
+	args := []string{
+		"d", cwd,
+		"D", filepath.Base(cwd),
+		"h", host,
+		"u", username,
+	}
+
+	for i, v := range args {
+		if i % 2 == 0 {
+			args[i] = "%" + v
+		}
+	}
+
+	r := strings.NewReplacer(args...)
+	nprompt := r.Replace(prompt)
+
+	return nprompt
+}
+
+func removeDupes(slice []string) []string {
+	all := make(map[string]bool)
+	newSlice := []string{}
+	for _, item := range slice {
+		if _, val := all[item]; !val {
+			all[item] = true
+			newSlice = append(newSlice, item)
+		}
+	}
+
+	return newSlice
+}
+
+func contains(s []string, e string) bool {
+	for _, a := range s {
+		if strings.ToLower(a) == strings.ToLower(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// Version returns the full version string of Hilbish.
+func Version() string {
+	v := strings.Builder{}
+
+	v.WriteString(ver)
+	if gitBranch != "" && gitBranch != "HEAD" {
+		v.WriteString("-" + gitBranch)
+	}
+
+	if gitCommit != "" {
+		v.WriteString("." + gitCommit)
+	}
+
+	v.WriteString(" (" + releaseName + ")")
+
+	return v.String()
+}
+
+func cut(slice []string, idx int) []string {
+	return append(slice[:idx], slice[idx + 1:]...)
+}
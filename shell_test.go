@@ -0,0 +1,87 @@
+package hilbish
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	rt "github.com/arnodel/golua/runtime"
+)
+
+func TestExitSavesSessionHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".hilbish-history")
+	sh := New(WithInteractive(true), WithHistory(path))
+	opts, ok := sh.hshMod.Get(rt.StringValue("opts")).TryTable()
+	if !ok {
+		opts = rt.NewTable()
+		sh.hshMod.Set(rt.StringValue("opts"), rt.TableValue(opts))
+	}
+	opts.Set(rt.StringValue("historyShare"), rt.StringValue("session"))
+
+	sh.lr.AddHistory("echo in this session")
+	if hist, _ := os.ReadFile(path); strings.Contains(string(hist), "echo in this session") {
+		t.Fatalf("expected the session's history to be written when closed, got %q", hist)
+	}
+
+	// exit stops the rest of the input, instead of the process
+	if code := sh.RunInput("exit 3; echo after exit", false); code != 3 {
+		t.Errorf("expected exit code 3, got %d", code)
+	}
+	if code, ok := sh.Exited(); !ok || code != 3 {
+		t.Errorf("expected the shell to have exited with 3, got %d (exited: %v)", code, ok)
+	}
+	sh.Close()
+
+	hist, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(hist), "echo in this session") {
+		t.Errorf("expected the session's history to be written, got %q", hist)
+	}
+}
+
+func TestShellsInOneProcess(t *testing.T) {
+	var ran []string
+	newShell := func(name string) *Shell {
+		return New(WithHistory(""), WithCommand("whoami", func(args []string, in io.Reader, out, err io.Writer) uint8 {
+			ran = append(ran, name)
+			return 0
+		}))
+	}
+
+	first, second := newShell("first"), newShell("second")
+	defer first.Close()
+	defer second.Close()
+
+	first.RunInput("x = 'first'", true)
+	second.RunInput("x = 'second'", true)
+	first.RunInput("whoami", true)
+	second.RunInput("whoami", true)
+
+	if !reflect.DeepEqual(ran, []string{"first", "second"}) {
+		t.Errorf("expected each shell to run its own command, got %q", ran)
+	}
+	for name, sh := range map[string]*Shell{"first": first, "second": second} {
+		if x, _ := sh.runtime.GlobalEnv().Get(rt.StringValue("x")).TryString(); x != name {
+			t.Errorf("expected x to be %q in the %s shell, got %q", name, name, x)
+		}
+	}
+
+	// exiting one shell leaves the other running
+	if code := first.RunInput("exit 2", true); code != 2 {
+		t.Errorf("expected exit code 2, got %d", code)
+	}
+	if _, ok := first.Exited(); !ok {
+		t.Error("expected the first shell to have exited")
+	}
+	if _, ok := second.Exited(); ok {
+		t.Error("expected the second shell not to have exited")
+	}
+	if code := second.RunInput("false", true); code != 1 {
+		t.Errorf("expected the second shell to still run input, got exit code %d", code)
+	}
+}
@@ -1,6 +1,6 @@
 // +build darwin linux
 
-package main
+package hilbish
 
 import (
 	"syscall"
@@ -8,18 +8,21 @@ import (
 	"os/signal"
 )
 
-func handleSignals() {
+// HandleSignals throws the signal hooks of the shell when Hilbish receives
+// signals. It blocks, so it should be run in a goroutine.
+// Only one Shell in a process should handle signals. It never exits the
+// process, that is left to the program (like on SIGTERM).
+func (sh *Shell) HandleSignals() {
 	c := make(chan os.Signal)
 	signal.Ignore(syscall.SIGTTOU, syscall.SIGTTIN, syscall.SIGTSTP)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGWINCH, syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGQUIT)
+	signal.Notify(c, os.Interrupt, syscall.SIGWINCH, syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGQUIT)
 
 	for s := range c {
 		switch s {
-		case os.Interrupt: hooks.Emit("signal.sigint")
-		case syscall.SIGTERM: exit(0)
-		case syscall.SIGWINCH: hooks.Emit("signal.resize")
-		case syscall.SIGUSR1: hooks.Emit("signal.sigusr1")
-		case syscall.SIGUSR2: hooks.Emit("signal.sigusr2")
+		case os.Interrupt: sh.hooks.Emit("signal.sigint")
+		case syscall.SIGWINCH: sh.hooks.Emit("signal.resize")
+		case syscall.SIGUSR1: sh.hooks.Emit("signal.sigusr1")
+		case syscall.SIGUSR2: sh.hooks.Emit("signal.sigusr2")
 		}
 	}
 }
@@ -1,22 +1,26 @@
 // +build windows
 
-package main
+package hilbish
 
 import (
 	"os"
 	"os/signal"
 )
 
-func handleSignals() {
+// HandleSignals throws the signal hooks of the shell when Hilbish receives
+// signals. It blocks, so it should be run in a goroutine.
+// Only one Shell in a process should handle signals. It never exits the
+// process, that is left to the program.
+func (sh *Shell) HandleSignals() {
 	c := make(chan os.Signal)
 	signal.Notify(c, os.Interrupt)
 
 	for s := range c {
 		switch s {
 		case os.Interrupt:
-			hooks.Emit("signal.sigint")
-			if !running && interactive {
-				lr.ClearInput()
+			sh.hooks.Emit("signal.sigint")
+			if !sh.running && sh.interactive {
+				sh.lr.ClearInput()
 			}
 		}
 	}
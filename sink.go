@@ -1,4 +1,4 @@
-package main
+package hilbish
 
 import (
 	"bufio"
@@ -37,7 +37,7 @@ func setupSinkType(rtm *rt.Runtime) {
 		"write": {luaSinkWrite, 2, false},
 		"writeln": {luaSinkWriteln, 2, false},
 	}
-	util.SetExports(l, sinkMethods, sinkFuncs)
+	util.SetExports(rtm, sinkMethods, sinkFuncs)
 
 	sinkIndex := func(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 		s, _ := sinkArg(c, 0)
@@ -64,7 +64,7 @@ func setupSinkType(rtm *rt.Runtime) {
 	}
 
 	sinkMeta.Set(rt.StringValue("__index"), rt.FunctionValue(rt.NewGoFunction(sinkIndex, "__index", 2, false)))
-	l.SetRegistry(sinkMetaKey, rt.TableValue(sinkMeta))
+	rtm.SetRegistry(sinkMetaKey, rt.TableValue(sinkMeta))
 }
 
 
@@ -212,11 +212,11 @@ func luaSinkAutoFlush(t *rt.Thread, c *rt.GoCont) (rt.Cont, error) {
 	return c.Next(), nil
 }
 
-func newSinkInput(r io.Reader) *sink {
+func newSinkInput(rtm *rt.Runtime, r io.Reader) *sink {
 	s := &sink{
 		reader: bufio.NewReader(r),
 	}
-	s.ud = sinkUserData(s)
+	s.ud = sinkUserData(rtm, s)
 
 	if f, ok := r.(*os.File); ok {
 		s.file = f
@@ -225,12 +225,12 @@ func newSinkInput(r io.Reader) *sink {
 	return s
 }
 
-func newSinkOutput(w io.Writer) *sink {
+func newSinkOutput(rtm *rt.Runtime, w io.Writer) *sink {
 	s := &sink{
 		writer: bufio.NewWriter(w),
 		autoFlush: true,
 	}
-	s.ud = sinkUserData(s)
+	s.ud = sinkUserData(rtm, s)
 
 	return s
 }
@@ -254,7 +254,7 @@ func valueToSink(val rt.Value) (*sink, bool) {
 	return s, ok
 }
 
-func sinkUserData(s *sink) *rt.UserData {
-	sinkMeta := l.Registry(sinkMetaKey)
+func sinkUserData(rtm *rt.Runtime, s *sink) *rt.UserData {
+	sinkMeta := rtm.Registry(sinkMetaKey)
 	return rt.NewUserData(s, sinkMeta.AsTable())
 }
@@ -0,0 +1,130 @@
+package hilbish
+
+import (
+	"sort"
+	"strings"
+)
+
+// suggester finds commands in the history to suggest as the user types.
+// Commands are kept sorted, so the ones starting with the input can
+// be found with a binary search.
+type suggester struct {
+	hist *fileHistory
+	cmds []*suggestion
+	byCmd map[string]*suggestion
+
+	// how many history entries have been indexed, and at which generation
+	indexed int
+	gen int
+}
+
+type suggestion struct {
+	cmd string
+	newest int // index of the newest time it was run
+	failed bool // whether it failed the newest time it was run
+	dirs map[string]int // newest index it was run at per directory
+}
+
+func newSuggester(hist *fileHistory) *suggester {
+	return &suggester{
+		hist: hist,
+		byCmd: map[string]*suggestion{},
+	}
+}
+
+// update indexes the entries added to the history since it was last called.
+func (s *suggester) update() {
+	entries, reset, gen := s.hist.since(s.indexed, s.gen)
+	if reset {
+		s.cmds = nil
+		s.byCmd = map[string]*suggestion{}
+		s.indexed = 0
+	}
+	s.gen = gen
+
+	var added []*suggestion
+	for i, entry := range entries {
+		idx := s.indexed + i
+		// hints can only be on one line
+		if entry.Cmd == "" || strings.Contains(entry.Cmd, "\n") {
+			continue
+		}
+
+		sugg := s.byCmd[entry.Cmd]
+		if sugg == nil {
+			sugg = &suggestion{
+				cmd: entry.Cmd,
+				dirs: map[string]int{},
+			}
+			s.byCmd[entry.Cmd] = sugg
+			added = append(added, sugg)
+		}
+
+		sugg.newest = idx
+		sugg.failed = entry.ExitCode != nil && *entry.ExitCode != 0
+		if entry.Cwd != "" {
+			sugg.dirs[entry.Cwd] = idx
+		}
+	}
+	s.indexed += len(entries)
+
+	if reset {
+		s.cmds = added
+		sort.Slice(s.cmds, func(i, j int) bool {
+			return s.cmds[i].cmd < s.cmds[j].cmd
+		})
+		return
+	}
+
+	for _, sugg := range added {
+		i := sort.Search(len(s.cmds), func(i int) bool {
+			return s.cmds[i].cmd >= sugg.cmd
+		})
+		s.cmds = append(s.cmds, nil)
+		copy(s.cmds[i + 1:], s.cmds[i:])
+		s.cmds[i] = sugg
+	}
+}
+
+// suggest returns the command from history to suggest for line,
+// or an empty string if there are none. Commands which were run in cwd
+// are preferred, then ones that succeeded, then the most recent.
+func (s *suggester) suggest(line, cwd string) string {
+	if strings.TrimSpace(line) == "" {
+		return ""
+	}
+	s.update()
+
+	start := sort.Search(len(s.cmds), func(i int) bool {
+		return s.cmds[i].cmd >= line
+	})
+
+	var best *suggestion
+	var bestScore, bestIdx int
+	for _, sugg := range s.cmds[start:] {
+		if !strings.HasPrefix(sugg.cmd, line) {
+			break
+		}
+		if sugg.cmd == line {
+			continue
+		}
+
+		score, idx := 0, sugg.newest
+		if dirIdx, ok := sugg.dirs[cwd]; ok {
+			score += 2
+			idx = dirIdx
+		}
+		if !sugg.failed {
+			score++
+		}
+
+		if best == nil || score > bestScore || (score == bestScore && idx > bestIdx) {
+			best, bestScore, bestIdx = sugg, score, idx
+		}
+	}
+
+	if best == nil {
+		return ""
+	}
+	return best.cmd
+}
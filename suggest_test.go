@@ -0,0 +1,115 @@
+package hilbish
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSuggest(t *testing.T) {
+	zero, one := 0, 1
+	type TestSuggestT struct {
+		Entries []historyEntry
+		Line string
+		Cwd string
+		Expected string
+	}
+
+	tests := []TestSuggestT{
+		{Entries: []historyEntry{{Cmd: "git status"}}, Line: "git s", Expected: "git status"},
+		{Entries: []historyEntry{{Cmd: "git status"}}, Line: "git p", Expected: ""},
+		{Entries: []historyEntry{{Cmd: "git status"}}, Line: " ", Expected: ""},
+		// the line itself isn't suggested
+		{Entries: []historyEntry{{Cmd: "git status"}}, Line: "git status", Expected: ""},
+		// multi-line commands can't be hints
+		{Entries: []historyEntry{{Cmd: "for i in a b\ndo echo $i\ndone"}}, Line: "for", Expected: ""},
+		// the most recent is preferred
+		{Entries: []historyEntry{{Cmd: "git push"}, {Cmd: "git pull"}}, Line: "git p", Expected: "git pull"},
+		{Entries: []historyEntry{{Cmd: "git pull"}, {Cmd: "git push"}, {Cmd: "git pull"}}, Line: "git p", Expected: "git pull"},
+		// then ones that succeeded
+		{
+			Entries: []historyEntry{{Cmd: "make test", ExitCode: &zero}, {Cmd: "make tset", ExitCode: &one}},
+			Line: "make t",
+			Expected: "make test",
+		},
+		{
+			// the newest run is what counts
+			Entries: []historyEntry{{Cmd: "make tset", ExitCode: &zero}, {Cmd: "make test", ExitCode: &zero}, {Cmd: "make test", ExitCode: &one}},
+			Line: "make t",
+			Expected: "make tset",
+		},
+		// then ones run in the current directory
+		{
+			Entries: []historyEntry{{Cmd: "go test ./...", Cwd: "/src/hilbish"}, {Cmd: "go test -v", Cwd: "/src/other"}},
+			Line: "go t",
+			Cwd: "/src/hilbish",
+			Expected: "go test ./...",
+		},
+		{
+			// even if they failed
+			Entries: []historyEntry{{Cmd: "go test ./...", Cwd: "/src/hilbish", ExitCode: &one}, {Cmd: "go test -v", Cwd: "/src/other", ExitCode: &zero}},
+			Line: "go t",
+			Cwd: "/src/hilbish",
+			Expected: "go test ./...",
+		},
+		{
+			// and the newest run in the directory counts
+			Entries: []historyEntry{{Cmd: "go test -v", Cwd: "/src/hilbish"}, {Cmd: "go test ./...", Cwd: "/src/hilbish"}, {Cmd: "go test -v", Cwd: "/src/other"}},
+			Line: "go t",
+			Cwd: "/src/hilbish",
+			Expected: "go test ./...",
+		},
+	}
+
+	for _, test := range tests {
+		h := newFileHistory(filepath.Join(t.TempDir(), ".hilbish-history"))
+		for _, entry := range test.Entries {
+			h.add(entry)
+		}
+
+		s := newSuggester(h)
+		if sugg := s.suggest(test.Line, test.Cwd); sugg != test.Expected {
+			t.Errorf("%q in %q: expected %q, got %q", test.Line, test.Cwd, test.Expected, sugg)
+		}
+		h.close()
+	}
+}
+
+func TestSuggestUpdates(t *testing.T) {
+	h := newFileHistory(filepath.Join(t.TempDir(), ".hilbish-history"))
+	defer h.close()
+	s := newSuggester(h)
+
+	h.add(historyEntry{Cmd: "ls -la"})
+	if sugg := s.suggest("ls", ""); sugg != "ls -la" {
+		t.Errorf("expected %q, got %q", "ls -la", sugg)
+	}
+
+	// commands added after it was indexed are suggested too
+	h.add(historyEntry{Cmd: "cd src"})
+	h.add(historyEntry{Cmd: "ls src"})
+	if sugg := s.suggest("ls", ""); sugg != "ls src" {
+		t.Errorf("expected %q after adding to history, got %q", "ls src", sugg)
+	}
+	if sugg := s.suggest("c", ""); sugg != "cd src" {
+		t.Errorf("expected %q after adding to history, got %q", "cd src", sugg)
+	}
+
+	// deleted commands aren't
+	if err := h.delete(2); err != nil {
+		t.Fatal(err)
+	}
+	if sugg := s.suggest("ls", ""); sugg != "ls -la" {
+		t.Errorf("expected %q after deleting from history, got %q", "ls -la", sugg)
+	}
+	if err := h.removeCmd("cd src"); err != nil {
+		t.Fatal(err)
+	}
+	if sugg := s.suggest("c", ""); sugg != "" {
+		t.Errorf("expected no suggestion after removing the command, got %q", sugg)
+	}
+
+	h.clear()
+	if sugg := s.suggest("ls", ""); sugg != "" {
+		t.Errorf("expected no suggestion after clearing history, got %q", sugg)
+	}
+}
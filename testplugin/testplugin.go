@@ -1,9 +1,58 @@
 package main
 
 import (
+	"fmt"
+	"io"
+	"strings"
+
+	"hilbish/moduleapi"
+
 	rt "github.com/arnodel/golua/runtime"
 )
 
-func Loader(rtm *rt.Runtime) rt.Value {
+func Loader(h moduleapi.Host) rt.Value {
+	h.RegisterCommand("testplugin", func(args []string, in io.Reader, out, err io.Writer) uint8 {
+		if len(args) == 0 {
+			fmt.Fprintln(err, "testplugin: expected an argument")
+			return 1
+		}
+
+		fmt.Fprintf(out, "hello %s!\n", strings.Join(args, " "))
+		return 0
+	})
+
+	h.RegisterCompleter("command.testplugin", func(query, ctx string, fields []string, comp moduleapi.CompletionContext) ([]moduleapi.CompletionGroup, string) {
+		// only the first argument is completed
+		if comp.Index != 1 {
+			return nil, query
+		}
+
+		return []moduleapi.CompletionGroup{
+			{
+				Type: "list",
+				Items: []string{"world", "jobs"},
+				Descriptions: map[string]string{
+					"world": "Greet the world",
+					"jobs": "Greet the amount of jobs",
+				},
+			},
+		}, query
+	})
+
+	h.RegisterRunner("testplugin", func(input string) moduleapi.RunnerResult {
+		code, err := h.Run("echo testplugin: " + input)
+		return moduleapi.RunnerResult{
+			Input: input,
+			ExitCode: code,
+			Err: err,
+		}
+	})
+
+	h.Hooks().On("job.done", func(...interface{}) {
+		if j := h.Jobs().Last(); j != nil {
+			fmt.Printf("testplugin: job %d (%s) exited with %d\n", j.ID(), j.Cmd(), j.ExitCode())
+		}
+	})
+
 	return rt.StringValue("hello world!")
 }
@@ -1,4 +1,4 @@
-package main
+package hilbish
 
 import (
 	"errors"
@@ -47,7 +47,7 @@ func (t *timer) start() error {
 		for {
 			select {
 			case <-t.ticker.C:
-				_, err := rt.Call1(l.MainThread(), rt.FunctionValue(t.fun))
+				_, err := rt.Call1(t.th.rtm.MainThread(), rt.FunctionValue(t.fun))
 				if err != nil {
 					fmt.Fprintln(os.Stderr, "Error in function:\n", err)
 					t.stop()
@@ -1,4 +1,4 @@
-package main
+package hilbish
 
 import (
 	"fmt"
@@ -10,7 +10,6 @@ import (
 	rt "github.com/arnodel/golua/runtime"
 )
 
-var timers *timersModule
 var timerMetaKey = rt.StringValue("hshtimer")
 
 type timersModule struct {
@@ -19,10 +18,12 @@ type timersModule struct {
 	timers map[int]*timer
 	latestID int
 	running int
+	rtm *rt.Runtime
 }
 
-func newTimersModule() *timersModule {
+func newTimersModule(rtm *rt.Runtime) *timersModule {
 	return &timersModule{
+		rtm: rtm,
 		timers: make(map[int]*timer),
 		latestID: 0,
 		mu: &sync.RWMutex{},
@@ -47,7 +48,7 @@ func (th *timersModule) create(typ timerType, dur time.Duration, fun *rt.Closure
 		th: th,
 		id: th.latestID,
 	}
-	t.ud = timerUserData(t)
+	t.ud = timerUserData(th.rtm, t)
 
 	th.timers[th.latestID] = t
 	
@@ -164,7 +165,7 @@ func (th *timersModule) loader(rtm *rt.Runtime) *rt.Table {
 	}
 
 	timerMeta.Set(rt.StringValue("__index"), rt.FunctionValue(rt.NewGoFunction(timerIndex, "__index", 2, false)))
-	l.SetRegistry(timerMetaKey, rt.TableValue(timerMeta))
+	rtm.SetRegistry(timerMetaKey, rt.TableValue(timerMeta))
 
 	thExports := map[string]util.LuaExport{
 		"create": {th.luaCreate, 3, false},
@@ -199,7 +200,7 @@ func valueToTimer(val rt.Value) (*timer, bool) {
 	return j, ok
 }
 
-func timerUserData(j *timer) *rt.UserData {
-	timerMeta := l.Registry(timerMetaKey)
+func timerUserData(rtm *rt.Runtime, j *timer) *rt.UserData {
+	timerMeta := rtm.Registry(timerMetaKey)
 	return rt.NewUserData(j, timerMeta.AsTable())
 }
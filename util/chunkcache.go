@@ -0,0 +1,166 @@
+package util
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	rt "github.com/arnodel/golua/runtime"
+)
+
+type chunkCacheConf struct {
+	dir string
+	version string
+}
+
+var chunkCaches = struct {
+	mu sync.RWMutex
+	confs map[*rt.Runtime]chunkCacheConf
+}{confs: map[*rt.Runtime]chunkCacheConf{}}
+
+// EnableChunkCache makes DoFile, LoadFile and LoadChunk save the chunks
+// compiled in rtm in dir, and reuse them instead of compiling the source
+// again. Cached chunks are keyed by version, which should change whenever
+// the Lua compiler might. An empty dir disables the cache.
+func EnableChunkCache(rtm *rt.Runtime, dir, version string) {
+	chunkCaches.mu.Lock()
+	defer chunkCaches.mu.Unlock()
+
+	if dir == "" {
+		delete(chunkCaches.confs, rtm)
+		return
+	}
+	chunkCaches.confs[rtm] = chunkCacheConf{dir: dir, version: version}
+}
+
+// LoadFile loads the Lua file at path as a function, and returns whether
+// it was loaded from the cache. The compiled chunk is cached by the path
+// and modification time of the file. Files of Lua bytecode are loaded
+// without the cache.
+func LoadFile(rtm *rt.Runtime, path string) (*rt.Closure, bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// precompiled files are loaded as they are, there's nothing to cache
+	if rt.HasMarshalPrefix(src) {
+		clos, err := rtm.LoadFromSourceOrCode(path, src, "b", rt.TableValue(rtm.GlobalEnv()), false)
+		return clos, false, err
+	}
+
+	if bytes.HasPrefix(src, []byte("#")) {
+		// shebang - skip that line but keep the line numbers right
+		if idx := bytes.IndexByte(src, '\n'); idx != -1 {
+			src = src[idx:]
+		} else {
+			src = nil
+		}
+	}
+
+	// relative paths can point to different files depending on the cwd
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	key := abs + ":" + strconv.FormatInt(info.ModTime().UnixNano(), 10) + ":" + strconv.FormatInt(info.Size(), 10)
+
+	return LoadChunk(rtm, path, key, src)
+}
+
+// LoadChunk loads Lua source code as a function, and returns whether
+// it was loaded from the cache. key identifies the contents of the
+// source (like a modification time or hash) in the cache.
+func LoadChunk(rtm *rt.Runtime, name, key string, src []byte) (*rt.Closure, bool, error) {
+	env := rt.TableValue(rtm.GlobalEnv())
+	cachePath := chunkCachePath(rtm, name, key)
+	if cachePath != "" {
+		if code, err := os.ReadFile(cachePath); err == nil && rt.HasMarshalPrefix(code) {
+			clos, err := rtm.LoadFromSourceOrCode(name, code, "b", env, false)
+			if err == nil {
+				return clos, true, nil
+			}
+			// the cached chunk is broken, so compile it again
+		}
+	}
+
+	clos, err := rtm.CompileAndLoadLuaChunk(name, src, env)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if cachePath != "" {
+		writeCachedChunk(rtm, cachePath, clos)
+	}
+
+	return clos, false, nil
+}
+
+// chunkCachePath returns where the chunk is cached. The file name starts
+// with a hash of the chunk's name, so old entries for it can be found
+// when it changes.
+func chunkCachePath(rtm *rt.Runtime, name, key string) string {
+	chunkCaches.mu.RLock()
+	conf, ok := chunkCaches.confs[rtm]
+	chunkCaches.mu.RUnlock()
+
+	if !ok {
+		return ""
+	}
+
+	nameSum := sha256.Sum256([]byte(name))
+	keySum := sha256.Sum256([]byte(key + "\x00" + conf.version))
+	return filepath.Join(conf.dir, hex.EncodeToString(nameSum[:8]) + "-" + hex.EncodeToString(keySum[:8]) + ".luac")
+}
+
+func writeCachedChunk(rtm *rt.Runtime, path string, clos *rt.Closure) {
+	var buf bytes.Buffer
+	code := rtm.RefactorCodeConsts(clos.Code)
+	if _, err := rt.MarshalConst(&buf, rt.CodeValue(code), 0); err != nil {
+		return
+	}
+
+	// failing to cache isn't an issue, the chunk will just be compiled again
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+
+	// write to a temporary file first, so another instance
+	// can't read a partially written chunk
+	f, err := os.CreateTemp(filepath.Dir(path), ".chunk-*")
+	if err != nil {
+		return
+	}
+
+	_, err = f.Write(buf.Bytes())
+	f.Close()
+	if err != nil {
+		os.Remove(f.Name())
+		return
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return
+	}
+
+	// the chunks cached before the source (or version) changed
+	// won't be used again
+	base := filepath.Base(path)
+	old, _ := filepath.Glob(filepath.Join(filepath.Dir(path), base[:strings.IndexByte(base, '-') + 1] + "*.luac"))
+	for _, o := range old {
+		if o != path {
+			os.Remove(o)
+		}
+	}
+}
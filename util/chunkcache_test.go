@@ -0,0 +1,141 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	rt "github.com/arnodel/golua/runtime"
+)
+
+// loadCached loads the file at path and checks what it returns,
+// and returns whether it was loaded from the cache.
+func loadCached(t *testing.T, rtm *rt.Runtime, path string, expected int64) bool {
+	t.Helper()
+	clos, cached, err := LoadFile(rtm, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ret, err := rt.Call1(rtm.MainThread(), rt.FunctionValue(clos))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := ret.TryInt(); n != expected {
+		t.Errorf("expected the chunk to return %d, got %v", expected, ret)
+	}
+
+	return cached
+}
+
+// cachedChunks returns the chunks in the cache dir.
+func cachedChunks(t *testing.T, dir string) []string {
+	t.Helper()
+	chunks, err := filepath.Glob(filepath.Join(dir, "*.luac"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return chunks
+}
+
+func TestLoadFileCache(t *testing.T) {
+	dir := t.TempDir()
+	cacheDir := filepath.Join(dir, "chunks")
+	path := filepath.Join(dir, "init.lua")
+	if err := os.WriteFile(path, []byte("return 1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rtm := rt.New(nil)
+	EnableChunkCache(rtm, cacheDir, "v1")
+
+	if loadCached(t, rtm, path, 1) {
+		t.Error("expected the first load to compile the file")
+	}
+	if !loadCached(t, rtm, path, 1) {
+		t.Error("expected the second load to come from the cache")
+	}
+
+	// a new modification time is a new entry
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if loadCached(t, rtm, path, 1) {
+		t.Error("expected the file to be compiled again after its modification time changed")
+	}
+
+	// as is a new size, even with the same modification time
+	if err := os.WriteFile(path, []byte("return 22"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if loadCached(t, rtm, path, 22) {
+		t.Error("expected the file to be compiled again after its size changed")
+	}
+	if !loadCached(t, rtm, path, 22) {
+		t.Error("expected the changed file to come from the cache")
+	}
+
+	// and a new version
+	EnableChunkCache(rtm, cacheDir, "v2")
+	if loadCached(t, rtm, path, 22) {
+		t.Error("expected the file to be compiled again for another version")
+	}
+
+	// old entries are removed
+	if chunks := cachedChunks(t, cacheDir); len(chunks) != 1 {
+		t.Errorf("expected only the newest chunk to be cached, got %q", chunks)
+	}
+
+	// without the cache, nothing is cached
+	EnableChunkCache(rtm, "", "")
+	if loadCached(t, rtm, path, 22) {
+		t.Error("expected the file to be compiled with the cache disabled")
+	}
+}
+
+func TestLoadFileCorruptCache(t *testing.T) {
+	dir := t.TempDir()
+	cacheDir := filepath.Join(dir, "chunks")
+	path := filepath.Join(dir, "init.lua")
+	if err := os.WriteFile(path, []byte("return 1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rtm := rt.New(nil)
+	EnableChunkCache(rtm, cacheDir, "v1")
+	loadCached(t, rtm, path, 1)
+
+	chunks := cachedChunks(t, cacheDir)
+	if len(chunks) != 1 {
+		t.Fatalf("expected a chunk to be cached, got %q", chunks)
+	}
+	good, err := os.ReadFile(chunks[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	corruptions := map[string][]byte{
+		"not bytecode": []byte("garbage"),
+		"truncated": good[:len(good) / 2],
+		"empty": {},
+	}
+	for name, corrupt := range corruptions {
+		if err := os.WriteFile(chunks[0], corrupt, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if loadCached(t, rtm, path, 1) {
+			t.Errorf("%s: expected the file to be compiled again", name)
+		}
+		// and cached properly again
+		if !loadCached(t, rtm, path, 1) {
+			t.Errorf("%s: expected the file to be cached again", name)
+		}
+	}
+}
@@ -1,10 +1,7 @@
 package util
 
 import (
-	"bufio"
-	"io"
 	"strings"
-	"os"
 	"os/user"
 
 	rt "github.com/arnodel/golua/runtime"
@@ -38,48 +35,19 @@ func DoString(rtm *rt.Runtime, code string) (rt.Value, error) {
 
 // DoFile runs the contents of the file in the Lua runtime.
 func DoFile(rtm *rt.Runtime, path string) error {
-	f, err := os.Open(path)
-	defer f.Close()
-
-	if err != nil {
-		return err
-	}
-	
-	reader := bufio.NewReader(f)
-	c, err := reader.ReadByte()
-	if err != nil && err != io.EOF {
-		return err
-	}
-
-	// unread so a char won't be missing
-	err = reader.UnreadByte()
+	clos, _, err := LoadFile(rtm, path)
 	if err != nil {
 		return err
 	}
 
-	var buf []byte
-	if c == byte('#') {
-		// shebang - skip that line
-		_, err := reader.ReadBytes('\n')
-		if err != nil && err != io.EOF {
-			return err
-		}
-		buf = []byte{'\n'}
-	}
-
-	for {
-		line, err := reader.ReadBytes('\n')
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return err
-		}
-		
-		buf = append(buf, line...)
-	}
+	_, err = rt.Call1(rtm.MainThread(), rt.FunctionValue(clos))
+	return err
+}
 
-	clos, err := rtm.LoadFromSourceOrCode(path, buf, "bt", rt.TableValue(rtm.GlobalEnv()), false)
+// DoChunk runs a chunk of Lua source or bytecode in the runtime.
+// The name is used as the chunk name in errors.
+func DoChunk(rtm *rt.Runtime, name string, code []byte) error {
+	clos, err := rtm.LoadFromSourceOrCode(name, code, "bt", rt.TableValue(rtm.GlobalEnv()), false)
 	if clos != nil {
 		_, err = rt.Call1(rtm.MainThread(), rt.FunctionValue(clos))
 	}
@@ -1,12 +1,9 @@
-package main
+package hilbish
 
 // String vars that are free to be changed at compile time
 var (
 	defaultHistDir = ""
 	commonRequirePaths = "';./libs/?/init.lua;./?/init.lua;./?/?.lua'"
-
-	prompt string
-	multilinePrompt = "> "
 )
 
 // Version info
@@ -17,13 +14,3 @@ var (
 	gitCommit string
 	gitBranch string
 )
-
-// Flags
-var (
-	running bool // Is a command currently running
-	interactive bool
-	login bool // Are we the login shell?
-	noexecute bool // Should we run Lua or only report syntax errors
-	initialized bool
-)
-
@@ -1,6 +1,6 @@
 // +build linux
 
-package main
+package hilbish
 
 // String vars that are free to be changed at compile time
 var (